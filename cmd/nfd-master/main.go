@@ -19,9 +19,13 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/docopt/docopt-go"
 	master "sigs.k8s.io/node-feature-discovery/pkg/nfd-master"
@@ -51,6 +55,15 @@ func main() {
 		log.Fatalf("Failed to initialize NfdMaster instance: %v", err)
 	}
 
+	// Gracefully stop the server on termination signals
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-sigs
+		log.Printf("received signal %q, shutting down", sig)
+		instance.Stop()
+	}()
+
 	if err = instance.Run(); err != nil {
 		log.Fatalf("ERROR: %v", err)
 	}
@@ -63,10 +76,21 @@ func argsParse(argv []string) (master.Args, error) {
 	usage := fmt.Sprintf(`%s.
 
   Usage:
-  %s [--prune] [--no-publish] [--label-whitelist=<pattern>] [--port=<port>]
+  %s [--prune | --check-consistency] [--no-publish] [--label-whitelist=<pattern>] [--port=<port>]
      [--ca-file=<path>] [--cert-file=<path>] [--key-file=<path>]
      [--verify-node-name] [--extra-label-ns=<list>] [--resource-labels=<list>]
-     [--kubeconfig=<path>]
+     [--kubeconfig=<path>] [--config=<path>] [--drain-timeout=<duration>]
+     [--metrics=<port>] [--dedup-window=<duration>]
+     [--enable-pprof] [--pprof-port=<port>] [--audit-log=<path>]
+     [--audit-webhook=<url>] [--defer-resource-removal] [--enable-taints]
+     [--bulk-concurrency=<num>] [--bulk-node-timeout=<duration>]
+     [--bulk-timeout=<duration>] [--extra-annotation-ns=<list>]
+     [--listen-address=<ip>] [--listen-family=<family>]
+     [--label-ns=<ns>] [--annotation-ns=<ns>] [--verbosity=<level>]
+     [--max-connection-age=<duration>] [--node-cache]
+     [--coalesce-window=<duration>] [--health-port=<port>]
+     [--resync-interval=<duration>] [--state-namespace=<ns>]
+     [--compression-level=<level>]
   %s -h | --help
   %s --version
 
@@ -75,10 +99,33 @@ func argsParse(argv []string) (master.Args, error) {
   --version                       Output version and exit.
   --prune                         Prune all NFD related attributes from all nodes
                                   of the cluster and exit.
+  --check-consistency             Check that the features recorded in the
+                                  annotations of all nodes of the cluster are
+                                  present in the node objects and exit.
+  --bulk-concurrency=<num>        Number of nodes processed in parallel in
+                                  operations targeting all nodes of the
+                                  cluster, e.g. --prune. [Default: 1]
+  --bulk-node-timeout=<duration>  Timeout for processing one node in operations
+                                  targeting all nodes. Zero disables the
+                                  timeout. [Default: 30s]
+  --bulk-timeout=<duration>       Deadline for operations targeting all nodes.
+                                  Zero disables the deadline. [Default: 0s]
   --kubeconfig=<path>             Kubeconfig to use [Default: ]
                                   of the cluster and exit.
+  --config=<path>                 Config file to use. [Default: ]
   --port=<port>                   Port on which to listen for connections.
                                   [Default: 8080]
+  --listen-address=<ip>           IP address on which to listen for
+                                  connections. Empty means all addresses of
+                                  the address family. [Default: ]
+  --listen-family=<family>        Address family to listen on, one of dual,
+                                  ipv4 or ipv6. [Default: dual]
+  --compression-level=<level>     Gzip compression level (1-9) of replies to
+                                  compressed requests. Zero means the default
+                                  level. [Default: 0]
+  --max-connection-age=<duration> Max age of client connections after which
+                                  clients are asked to reconnect. Zero means
+                                  no limit. [Default: 0s]
   --ca-file=<path>                Root certificate for verifying connections
                                   [Default: ]
   --cert-file=<path>              Certificate used for authenticating connections
@@ -89,6 +136,48 @@ func argsParse(argv []string) (master.Args, error) {
                                   certificate. Only has effect when TLS authentication
                                   has been enabled.
   --no-publish                    Do not publish feature labels
+  --node-cache                    Serve node objects from a local cache kept
+                                  up-to-date by watching the API server.
+  --resync-interval=<duration>    Interval of re-applying the annotations of
+                                  the master node. Zero disables resync.
+                                  [Default: 60s]
+  --state-namespace=<ns>          Namespace in which to store the last accepted
+                                  features of each node in ConfigMaps. Empty
+                                  disables storing the state. [Default: ]
+  --verbosity=<level>             Verbosity of request logging: 0 logs failed
+                                  requests only, 1 also successful requests
+                                  and label changes and 2 also the content of
+                                  the requests.
+                                  [Default: 1]
+  --coalesce-window=<duration>    Time window in which successive labeling
+                                  requests from a node are coalesced into one
+                                  node update. Zero disables coalescing.
+                                  [Default: 0s]
+  --dedup-window=<duration>       Time window in which identical labeling
+                                  requests from a node are not re-applied.
+                                  Zero disables duplicate suppression.
+                                  [Default: 0s]
+  --enable-pprof                  Serve net/http/pprof profiling endpoints on
+                                  localhost.
+  --pprof-port=<port>             Localhost port of the pprof endpoints.
+                                  [Default: 6060]
+  --audit-log=<path>              File to write an audit log of all changes made
+                                  to node objects, in JSON lines format.
+                                  [Default: ]
+  --audit-webhook=<url>           HTTP(S) endpoint to which audit records are
+                                  POSTed as JSON. [Default: ]
+  --defer-resource-removal        Do not remove extended resources that are
+                                  still requested by pods running on the node.
+  --enable-taints                 Apply node taints requested by nfd-worker.
+  --health-port=<port>            Port on which to serve the /healthz and
+                                  /readyz HTTP endpoints. Non-positive value
+                                  disables the endpoints. [Default: 0]
+  --metrics=<port>                Port on which to expose Prometheus metrics.
+                                  Non-positive value disables metrics.
+                                  [Default: 0]
+  --drain-timeout=<duration>      Time to wait for in-flight requests to finish
+                                  when shutting down. Zero value waits
+                                  indefinitely. [Default: 30s]
   --label-whitelist=<pattern>     Regular expression to filter label names to
                                   publish to the Kubernetes API server.
                                   NB: the label namespace is omitted i.e. the filter
@@ -97,7 +186,13 @@ func argsParse(argv []string) (master.Args, error) {
   --extra-label-ns=<list>         Comma separated list of allowed extra label namespaces
                                   [Default: ]
   --resource-labels=<list>        Comma separated list of labels to be exposed as extended resources.
-                                  [Default: ]`,
+                                  [Default: ]
+  --extra-annotation-ns=<list>    Comma separated list of allowed extra feature
+                                  annotation namespaces [Default: ]
+  --label-ns=<ns>                 Default namespace of feature labels.
+                                  [Default: feature.node.kubernetes.io]
+  --annotation-ns=<ns>            Namespace of NFD-related annotations.
+                                  [Default: nfd.node.kubernetes.io]`,
 		ProgramName,
 		ProgramName,
 		ProgramName,
@@ -109,23 +204,92 @@ func argsParse(argv []string) (master.Args, error) {
 
 	// Parse argument values as usable types.
 	var err error
+	args.AnnotationNs = arguments["--annotation-ns"].(string)
+	args.AuditLog = arguments["--audit-log"].(string)
+	args.AuditWebhook = arguments["--audit-webhook"].(string)
+	args.BulkConcurrency, err = strconv.Atoi(arguments["--bulk-concurrency"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --bulk-concurrency defined: %s", err)
+	}
+	args.BulkNodeTimeout, err = time.ParseDuration(arguments["--bulk-node-timeout"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --bulk-node-timeout specified: %s", err.Error())
+	}
+	args.BulkTimeout, err = time.ParseDuration(arguments["--bulk-timeout"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --bulk-timeout specified: %s", err.Error())
+	}
 	args.CaFile = arguments["--ca-file"].(string)
 	args.CertFile = arguments["--cert-file"].(string)
+	args.ConfigFile = arguments["--config"].(string)
 	args.KeyFile = arguments["--key-file"].(string)
 	args.NoPublish = arguments["--no-publish"].(bool)
+	args.NodeCache = arguments["--node-cache"].(bool)
+	args.LabelNs = arguments["--label-ns"].(string)
+	args.ListenAddress = arguments["--listen-address"].(string)
+	args.ListenFamily = arguments["--listen-family"].(string)
+	args.MaxConnectionAge, err = time.ParseDuration(arguments["--max-connection-age"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --max-connection-age specified: %s", err.Error())
+	}
 	args.Port, err = strconv.Atoi(arguments["--port"].(string))
 	if err != nil {
 		return args, fmt.Errorf("invalid --port defined: %s", err)
 	}
+	args.EnablePprof = arguments["--enable-pprof"].(bool)
+	args.PprofPort, err = strconv.Atoi(arguments["--pprof-port"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --pprof-port defined: %s", err)
+	}
+	args.HealthPort, err = strconv.Atoi(arguments["--health-port"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --health-port defined: %s", err)
+	}
+	args.MetricsPort, err = strconv.Atoi(arguments["--metrics"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --metrics port defined: %s", err)
+	}
 	args.LabelWhiteList, err = regexp.Compile(arguments["--label-whitelist"].(string))
 	if err != nil {
 		return args, fmt.Errorf("error parsing whitelist regex (%s): %s", arguments["--label-whitelist"], err)
 	}
 	args.VerifyNodeName = arguments["--verify-node-name"].(bool)
+	args.CompressionLevel, err = strconv.Atoi(arguments["--compression-level"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --compression-level defined: %s", err)
+	}
+	args.Verbosity, err = strconv.Atoi(arguments["--verbosity"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --verbosity defined: %s", err)
+	}
 	args.ExtraLabelNs = strings.Split(arguments["--extra-label-ns"].(string), ",")
+	args.ExtraAnnotationNs = strings.Split(arguments["--extra-annotation-ns"].(string), ",")
 	args.ResourceLabels = strings.Split(arguments["--resource-labels"].(string), ",")
 	args.Prune = arguments["--prune"].(bool)
+	args.CheckConsistency = arguments["--check-consistency"].(bool)
 	args.Kubeconfig = arguments["--kubeconfig"].(string)
+	args.StateNamespace = arguments["--state-namespace"].(string)
+	args.ResyncInterval, err = time.ParseDuration(arguments["--resync-interval"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --resync-interval specified: %s", err.Error())
+	}
+	args.CoalesceWindow, err = time.ParseDuration(arguments["--coalesce-window"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --coalesce-window specified: %s", err.Error())
+	}
+	args.DedupWindow, err = time.ParseDuration(arguments["--dedup-window"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --dedup-window specified: %s", err.Error())
+	}
+	args.DeferResourceRemoval = arguments["--defer-resource-removal"].(bool)
+	args.EnableTaints = arguments["--enable-taints"].(bool)
+	args.DrainTimeout, err = time.ParseDuration(arguments["--drain-timeout"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --drain-timeout specified: %s", err.Error())
+	}
+	if args.DrainTimeout < 0 {
+		return args, fmt.Errorf("invalid --drain-timeout specified, must not be negative: %s", args.DrainTimeout)
+	}
 
 	return args, nil
 }
@@ -18,6 +18,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -42,6 +43,66 @@ func TestArgsParse(t *testing.T) {
 				So(args.KeyFile, ShouldEqual, "key")
 				So(args.CaFile, ShouldEqual, "ca")
 				So(args.LabelWhiteList.String(), ShouldResemble, ".*rdt.*")
+				So(args.DrainTimeout, ShouldEqual, 30*time.Second)
+				So(args.BulkConcurrency, ShouldEqual, 1)
+				So(args.Verbosity, ShouldEqual, 1)
+				So(args.BulkNodeTimeout, ShouldEqual, 30*time.Second)
+				So(args.ResyncInterval, ShouldEqual, 60*time.Second)
+				So(args.StateNamespace, ShouldEqual, "")
+				So(args.CompressionLevel, ShouldEqual, 0)
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When invalid --drain-timeout is defined", func() {
+			_, err := argsParse([]string{"--drain-timeout=10"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When negative --drain-timeout is defined", func() {
+			_, err := argsParse([]string{"--drain-timeout=-1s"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When invalid --compression-level is defined", func() {
+			_, err := argsParse([]string{"--compression-level=high"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When invalid --resync-interval is defined", func() {
+			_, err := argsParse([]string{"--resync-interval=10"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When --check-consistency is defined", func() {
+			args, err := argsParse([]string{"--check-consistency"})
+			Convey("Argument parsing should succeed and args set to correct values", func() {
+				So(args.CheckConsistency, ShouldBeTrue)
+				So(args.Prune, ShouldBeFalse)
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When invalid --bulk-concurrency is defined", func() {
+			_, err := argsParse([]string{"--bulk-concurrency=a"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When --listen-address and --listen-family are defined", func() {
+			args, err := argsParse([]string{"--listen-address=::1", "--listen-family=ipv6"})
+			Convey("Argument parsing should succeed and args set to correct values", func() {
+				So(args.ListenAddress, ShouldEqual, "::1")
+				So(args.ListenFamily, ShouldEqual, "ipv6")
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When --max-connection-age is defined", func() {
+			args, err := argsParse([]string{"--max-connection-age=10m"})
+			Convey("Argument parsing should succeed and args set to correct values", func() {
+				So(args.MaxConnectionAge, ShouldEqual, 10*time.Minute)
 				So(err, ShouldBeNil)
 			})
 		})
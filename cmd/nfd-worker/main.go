@@ -17,12 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"flag"
 	"fmt"
-	"log"
+	"net"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docopt/docopt-go"
+	"k8s.io/klog"
 	worker "sigs.k8s.io/node-feature-discovery/pkg/nfd-worker"
 	"sigs.k8s.io/node-feature-discovery/pkg/version"
 )
@@ -30,28 +34,45 @@ import (
 const (
 	// ProgramName is the canonical name of this program
 	ProgramName = "nfd-worker"
+
+	// ExitDiscoveryFailed is the exit status used in one-shot mode when
+	// feature discovery failed for some of the enabled sources
+	ExitDiscoveryFailed = 2
 )
 
 func main() {
-	// Assert that the version is known
-	if version.Undefined() {
-		log.Printf("WARNING: version not set! Set -ldflags \"-X sigs.k8s.io/node-feature-discovery/pkg/version.version=`git describe --tags --dirty --always`\" during build or run.")
-	}
-
 	// Parse command-line arguments.
 	args, err := argsParse(nil)
 	if err != nil {
-		log.Fatalf("failed to parse command line: %v", err)
+		klog.Fatalf("failed to parse command line: %v", err)
+	}
+
+	// Configure logging
+	klogFlags := flag.NewFlagSet("klog", flag.ExitOnError)
+	klog.InitFlags(klogFlags)
+	if err := klogFlags.Set("v", strconv.Itoa(args.Verbosity)); err != nil {
+		klog.Fatalf("failed to set verbosity: %v", err)
+	}
+	defer klog.Flush()
+
+	// Assert that the version is known
+	if version.Undefined() {
+		klog.Warningf("version not set! Set -ldflags \"-X sigs.k8s.io/node-feature-discovery/pkg/version.version=`git describe --tags --dirty --always`\" during build or run.")
 	}
 
 	// Get new NfdWorker instance
 	instance, err := worker.NewNfdWorker(args)
 	if err != nil {
-		log.Fatalf("Failed to initialize NfdWorker instance: %v", err)
+		klog.Fatalf("Failed to initialize NfdWorker instance: %v", err)
 	}
 
 	if err = instance.Run(); err != nil {
-		log.Fatalf("ERROR: %v", err)
+		if _, ok := err.(*worker.DiscoveryError); ok {
+			klog.Errorf("%v", err)
+			klog.Flush()
+			os.Exit(ExitDiscoveryFailed)
+		}
+		klog.Fatalf("%v", err)
 	}
 }
 
@@ -62,10 +83,13 @@ func argsParse(argv []string) (worker.Args, error) {
 	usage := fmt.Sprintf(`%s.
 
   Usage:
-  %s [--no-publish] [--sources=<sources>] [--label-whitelist=<pattern>]
+  %s [--no-publish | --get-labels] [--output=<path>] [--sources=<sources>]
+     [--label-whitelist=<pattern>]
      [--oneshot | --sleep-interval=<seconds>] [--config=<path>]
      [--options=<config>] [--server=<server>] [--server-name-override=<name>]
      [--ca-file=<path>] [--cert-file=<path>] [--key-file=<path>]
+     [--low-memory] [--compression=<algorithm>] [--verbosity=<level>]
+     [--source-verbosity=<list>] [--max-retry-interval=<duration>]
   %s -h | --help
   %s --version
 
@@ -90,19 +114,42 @@ func argsParse(argv []string) (worker.Args, error) {
   --server-name-override=<name> Name (CN) expect from server certificate, useful
                               in testing
                               [Default: ]
+  --compression=<algorithm>   Compression of requests sent to nfd-master, one
+                              of none or gzip. [Default: none]
   --sources=<sources>         Comma separated list of feature sources.
-                              [Default: cpu,custom,iommu,kernel,local,memory,network,pci,storage,system,usb]
+                              [Default: cpu,custom,gpu,iommu,kernel,local,memory,network,pci,power,storage,system,usb,virt]
   --no-publish                Do not publish discovered features to the
-                              cluster-local Kubernetes API server.
+                              cluster-local Kubernetes API server. The
+                              discovered features are written to stdout as
+                              JSON instead.
+  --get-labels                Do not run feature discovery but query the
+                              features currently published for this node from
+                              nfd-master, write them to stdout as JSON and exit.
+  --output=<path>             Write the discovered features to a file instead
+                              of stdout in --no-publish and --get-labels mode.
+                              [Default: ]
   --label-whitelist=<pattern> Regular expression to filter label names to
                               publish to the Kubernetes API server.
                               NB: the label namespace is omitted i.e. the filter
                               is only applied to the name part after '/'.
                               [Default: ]
-  --oneshot                   Label once and exit.
+  --low-memory                Run with a reduced memory footprint, disabling
+                              the heavyweight custom, pci and usb sources.
+  --max-retry-interval=<duration> Maximum delay between retries of failed
+                              connections and requests to nfd-master.
+                              [Default: 5m]
+  --oneshot                   Label once and exit. The exit status is 2 if
+                              feature discovery failed for some sources.
   --sleep-interval=<seconds>  Time to sleep between re-labeling. Non-positive
                               value implies no re-labeling (i.e. infinite
-                              sleep). [Default: 60s]`,
+                              sleep). [Default: 60s]
+  --verbosity=<level>         Log verbosity: 0 logs errors, warnings and
+                              the most important events only, 1 also the
+                              discovered features and messages of the feature
+                              sources. [Default: 0]
+  --source-verbosity=<list>   Comma separated list of <source>=<level> pairs,
+                              overriding --verbosity for the given feature
+                              sources. [Default: ]`,
 		ProgramName,
 		ProgramName,
 		ProgramName,
@@ -117,17 +164,51 @@ func argsParse(argv []string) (worker.Args, error) {
 	args.CaFile = arguments["--ca-file"].(string)
 	args.CertFile = arguments["--cert-file"].(string)
 	args.ConfigFile = arguments["--config"].(string)
+	args.GetLabels = arguments["--get-labels"].(bool)
 	args.KeyFile = arguments["--key-file"].(string)
 	args.NoPublish = arguments["--no-publish"].(bool)
+	args.Output = arguments["--output"].(string)
 	args.Options = arguments["--options"].(string)
 	args.Server = arguments["--server"].(string)
+	if _, _, err := net.SplitHostPort(args.Server); err != nil {
+		return args, fmt.Errorf("invalid --server specified, IPv6 addresses must be enclosed in brackets, e.g. [::1]:8080: %v", err)
+	}
 	args.ServerNameOverride = arguments["--server-name-override"].(string)
+	args.Compression = arguments["--compression"].(string)
+	if args.Compression != "none" && args.Compression != "gzip" {
+		return args, fmt.Errorf("invalid --compression specified, must be one of none or gzip: %q", args.Compression)
+	}
 	args.Sources = strings.Split(arguments["--sources"].(string), ",")
 	args.LabelWhiteList = arguments["--label-whitelist"].(string)
+	args.LowMemory = arguments["--low-memory"].(bool)
+	args.MaxRetryInterval, err = time.ParseDuration(arguments["--max-retry-interval"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --max-retry-interval specified: %s", err.Error())
+	}
+	if args.MaxRetryInterval < time.Second {
+		return args, fmt.Errorf("invalid --max-retry-interval specified, must be at least 1s: %s", args.MaxRetryInterval)
+	}
 	args.Oneshot = arguments["--oneshot"].(bool)
 	args.SleepInterval, err = time.ParseDuration(arguments["--sleep-interval"].(string))
 	if err != nil {
 		return args, fmt.Errorf("invalid --sleep-interval specified: %s", err.Error())
 	}
+	args.Verbosity, err = strconv.Atoi(arguments["--verbosity"].(string))
+	if err != nil {
+		return args, fmt.Errorf("invalid --verbosity specified: %s", err)
+	}
+	args.SourceVerbosity = map[string]int{}
+	if list := arguments["--source-verbosity"].(string); list != "" {
+		for _, item := range strings.Split(list, ",") {
+			split := strings.SplitN(item, "=", 2)
+			if len(split) != 2 {
+				return args, fmt.Errorf("invalid --source-verbosity specified, expected <source>=<level>: %q", item)
+			}
+			args.SourceVerbosity[split[0]], err = strconv.Atoi(split[1])
+			if err != nil {
+				return args, fmt.Errorf("invalid --source-verbosity specified: %s", err)
+			}
+		}
+	}
 	return args, nil
 }
@@ -23,7 +23,7 @@ import (
 	. "github.com/smartystreets/goconvey/convey"
 )
 
-var allSources = []string{"cpu", "custom", "iommu", "kernel", "local", "memory", "network", "pci", "storage", "system", "usb"}
+var allSources = []string{"cpu", "custom", "gpu", "iommu", "kernel", "local", "memory", "network", "pci", "power", "storage", "system", "usb", "virt"}
 
 func TestArgsParse(t *testing.T) {
 	Convey("When parsing command line arguments", t, func() {
@@ -40,6 +40,27 @@ func TestArgsParse(t *testing.T) {
 			})
 		})
 
+		Convey("When --get-labels flag is passed", func() {
+			args, err := argsParse([]string{"--get-labels", "--output=/tmp/features.json"})
+
+			Convey("args.GetLabels is set", func() {
+				So(err, ShouldBeNil)
+				So(args.GetLabels, ShouldBeTrue)
+				So(args.NoPublish, ShouldBeFalse)
+				So(args.Output, ShouldEqual, "/tmp/features.json")
+			})
+		})
+
+		Convey("When --no-publish and --output flags are passed", func() {
+			args, err := argsParse([]string{"--no-publish", "--output=/tmp/features.json"})
+
+			Convey("args.Output is set to the given path", func() {
+				So(err, ShouldBeNil)
+				So(args.NoPublish, ShouldBeTrue)
+				So(args.Output, ShouldEqual, "/tmp/features.json")
+			})
+		})
+
 		Convey("When --sources flag is passed and set to some values, --sleep-inteval is specified", func() {
 			args, err := argsParse([]string{"--sources=fake1,fake2,fake3", "--sleep-interval=30s"})
 
@@ -77,5 +98,62 @@ func TestArgsParse(t *testing.T) {
 				So(err, ShouldBeNil)
 			})
 		})
+		Convey("When an IPv6 --server address is specified", func() {
+			args, err := argsParse([]string{"--server=[fd00::1]:8080"})
+			Convey("Argument parsing should succeed", func() {
+				So(args.Server, ShouldEqual, "[fd00::1]:8080")
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When an IPv6 --server address is specified without brackets", func() {
+			_, err := argsParse([]string{"--server=fd00::1:8080"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+		Convey("When --compression is specified", func() {
+			args, err := argsParse([]string{"--compression=gzip"})
+			Convey("Argument parsing should succeed", func() {
+				So(args.Compression, ShouldEqual, "gzip")
+				So(err, ShouldBeNil)
+			})
+		})
+		Convey("When invalid --compression is specified", func() {
+			_, err := argsParse([]string{"--compression=lz4"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When --max-retry-interval is specified", func() {
+			args, err := argsParse([]string{"--max-retry-interval=2m"})
+			Convey("The interval should be parsed", func() {
+				So(err, ShouldBeNil)
+				So(args.MaxRetryInterval, ShouldEqual, 2*time.Minute)
+			})
+		})
+
+		Convey("When too short --max-retry-interval is specified", func() {
+			_, err := argsParse([]string{"--max-retry-interval=100ms"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When --verbosity and --source-verbosity are specified", func() {
+			args, err := argsParse([]string{"--verbosity=1", "--source-verbosity=custom=2,local=0"})
+			Convey("The verbosity levels should be parsed", func() {
+				So(err, ShouldBeNil)
+				So(args.Verbosity, ShouldEqual, 1)
+				So(args.SourceVerbosity, ShouldResemble, map[string]int{"custom": 2, "local": 0})
+			})
+		})
+
+		Convey("When invalid --source-verbosity is specified", func() {
+			_, err := argsParse([]string{"--source-verbosity=custom"})
+			Convey("argsParse should fail", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
 	})
 }
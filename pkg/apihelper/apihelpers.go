@@ -32,9 +32,20 @@ type APIHelpers interface {
 	// GetNodes returns all the nodes in the cluster
 	GetNodes(*k8sclient.Clientset) (*api.NodeList, error)
 
+	// GetPods returns all the pods running on the given node
+	GetPods(*k8sclient.Clientset, string) (*api.PodList, error)
+
 	// UpdateNode updates the node via the API server using a client.
 	UpdateNode(*k8sclient.Clientset, *api.Node) error
 
 	// PatchStatus updates the node status via the API server using a client.
 	PatchStatus(*k8sclient.Clientset, string, interface{}) error
+
+	// UpdateConfigMapData merges data into a ConfigMap in the given
+	// namespace, creating the ConfigMap if it does not exist.
+	UpdateConfigMapData(*k8sclient.Clientset, string, string, map[string]string) error
+
+	// DeleteConfigMap deletes a ConfigMap in the given namespace. Deleting a
+	// non-existent ConfigMap is not an error.
+	DeleteConfigMap(*k8sclient.Clientset, string, string) error
 }
@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apihelper
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	api "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/informers"
+	k8sclient "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Implements APIHelpers, serving node objects from a local cache that is kept
+// up-to-date by a shared informer. Node updates are sent as patches computed
+// against the cached node object.
+type CachedK8sHelpers struct {
+	K8sHelpers
+	nodeInformer cache.SharedIndexInformer
+	nodeLister   corelisters.NodeLister
+}
+
+// NewCachedK8sHelpers creates a new instance of CachedK8sHelpers and waits
+// until the node cache has been populated. The cache is kept up-to-date until
+// stopCh is closed.
+func NewCachedK8sHelpers(h K8sHelpers, stopCh <-chan struct{}) (*CachedK8sHelpers, error) {
+	cli, err := h.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	return newCachedK8sHelpers(h, cli, stopCh)
+}
+
+func newCachedK8sHelpers(h K8sHelpers, cli k8sclient.Interface, stopCh <-chan struct{}) (*CachedK8sHelpers, error) {
+	factory := informers.NewSharedInformerFactory(cli, 0)
+	nodeInformer := factory.Core().V1().Nodes()
+	nodeLister := nodeInformer.Lister()
+
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, nodeInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to sync node cache")
+	}
+
+	return &CachedK8sHelpers{K8sHelpers: h, nodeInformer: nodeInformer.Informer(), nodeLister: nodeLister}, nil
+}
+
+// AddNodeEventHandler registers a handler that is notified about changes of
+// the node objects in the cache
+func (h *CachedK8sHelpers) AddNodeEventHandler(handler cache.ResourceEventHandler) {
+	h.nodeInformer.AddEventHandler(handler)
+}
+
+func (h *CachedK8sHelpers) GetNode(cli *k8sclient.Clientset, nodeName string) (*api.Node, error) {
+	return h.getNode(cli, nodeName)
+}
+
+func (h *CachedK8sHelpers) UpdateNode(c *k8sclient.Clientset, n *api.Node) error {
+	return h.updateNode(c, n)
+}
+
+func (h *CachedK8sHelpers) getNode(cli k8sclient.Interface, nodeName string) (*api.Node, error) {
+	node, err := h.nodeLister.Get(nodeName)
+	if err != nil {
+		// The node may have been created after the last cache update
+		return cli.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	}
+
+	// Objects in the cache must not be modified
+	return node.DeepCopy(), nil
+}
+
+func (h *CachedK8sHelpers) updateNode(c k8sclient.Interface, n *api.Node) error {
+	// Fall back to a full update (with conflict detection) if the node was
+	// not read from the cache, or, the cache has changed since
+	orig, err := h.nodeLister.Get(n.Name)
+	if err != nil || orig.ResourceVersion != n.ResourceVersion {
+		_, err := c.CoreV1().Nodes().Update(n)
+		return err
+	}
+
+	// The cache may lag behind the apiserver. Labels and annotations are
+	// merged by key, but the list of taints is replaced as a whole, which
+	// could drop taints added by others in the meantime. Make the patch
+	// conditional on the resource version in that case, so that the update
+	// fails with a conflict instead.
+	if !reflect.DeepEqual(orig.Spec.Taints, n.Spec.Taints) {
+		orig = orig.DeepCopy()
+		orig.ResourceVersion = ""
+	}
+
+	origData, err := json.Marshal(orig)
+	if err != nil {
+		return err
+	}
+	newData, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(origData, newData, api.Node{})
+	if err != nil {
+		return err
+	}
+	if string(patch) == "{}" {
+		return nil
+	}
+
+	_, err = c.CoreV1().Nodes().Patch(n.Name, types.StrategicMergePatchType, patch)
+	return err
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package apihelper
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	api "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestNode(name, resourceVersion string) *api.Node {
+	return &api.Node{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            name,
+			ResourceVersion: resourceVersion,
+			Labels:          map[string]string{"foo": "bar"},
+		},
+	}
+}
+
+// nodeWriteVerbs returns the verbs of the write actions on node objects
+// recorded by the fake clientset
+func nodeWriteVerbs(cli *fake.Clientset) []string {
+	verbs := []string{}
+	for _, a := range cli.Actions() {
+		if a.GetResource().Resource != "nodes" {
+			continue
+		}
+		switch a.(type) {
+		case k8stesting.PatchAction, k8stesting.UpdateAction:
+			verbs = append(verbs, a.GetVerb())
+		}
+	}
+	return verbs
+}
+
+// nodePatch returns the last patch of a node object recorded by the fake
+// clientset
+func nodePatch(cli *fake.Clientset) string {
+	patch := ""
+	for _, a := range cli.Actions() {
+		if p, ok := a.(k8stesting.PatchAction); ok && a.GetResource().Resource == "nodes" {
+			patch = string(p.GetPatch())
+		}
+	}
+	return patch
+}
+
+func TestCachedK8sHelpers(t *testing.T) {
+	Convey("When using the cached API helpers", t, func() {
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+
+		cli := fake.NewSimpleClientset(newTestNode("node-1", "1"))
+		h, err := newCachedK8sHelpers(K8sHelpers{}, cli, stopCh)
+		So(err, ShouldBeNil)
+
+		Convey("When getting a node that is in the cache", func() {
+			node, err := h.getNode(cli, "node-1")
+
+			Convey("A copy of the cached object should be returned", func() {
+				So(err, ShouldBeNil)
+				So(node.Labels, ShouldResemble, map[string]string{"foo": "bar"})
+
+				node.Labels["foo"] = "modified"
+				cached, err := h.nodeLister.Get("node-1")
+				So(err, ShouldBeNil)
+				So(cached.Labels["foo"], ShouldEqual, "bar")
+			})
+		})
+
+		Convey("When getting a node that is not in the cache", func() {
+			// The node only exists in the apiserver, not in the cache
+			live := fake.NewSimpleClientset(newTestNode("node-2", "1"))
+			node, err := h.getNode(live, "node-2")
+
+			Convey("The node should be read from the apiserver", func() {
+				So(err, ShouldBeNil)
+				So(node.Name, ShouldEqual, "node-2")
+			})
+		})
+
+		Convey("When getting a node that does not exist", func() {
+			_, err := h.getNode(cli, "node-3")
+
+			Convey("An error should be returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When updating a node that has not changed since it was read", func() {
+			node, err := h.getNode(cli, "node-1")
+			So(err, ShouldBeNil)
+			cli.ClearActions()
+
+			node.Labels["new"] = "label"
+			err = h.updateNode(cli, node)
+
+			Convey("The node should be patched", func() {
+				So(err, ShouldBeNil)
+				So(nodeWriteVerbs(cli), ShouldResemble, []string{"patch"})
+				So(nodePatch(cli), ShouldNotContainSubstring, "resourceVersion")
+
+				updated, err := cli.CoreV1().Nodes().Get("node-1", meta_v1.GetOptions{})
+				So(err, ShouldBeNil)
+				So(updated.Labels, ShouldResemble, map[string]string{"foo": "bar", "new": "label"})
+			})
+		})
+
+		Convey("When changing the taints of a node", func() {
+			node, err := h.getNode(cli, "node-1")
+			So(err, ShouldBeNil)
+			cli.ClearActions()
+
+			node.Spec.Taints = []api.Taint{{Key: "foo", Value: "bar", Effect: api.TaintEffectNoSchedule}}
+			err = h.updateNode(cli, node)
+
+			Convey("The patch should be conditional on the resource version", func() {
+				So(err, ShouldBeNil)
+				So(nodeWriteVerbs(cli), ShouldResemble, []string{"patch"})
+				So(nodePatch(cli), ShouldContainSubstring, `"resourceVersion":"1"`)
+			})
+		})
+
+		Convey("When updating a node without changes", func() {
+			node, err := h.getNode(cli, "node-1")
+			So(err, ShouldBeNil)
+			cli.ClearActions()
+
+			err = h.updateNode(cli, node)
+
+			Convey("Nothing should be sent to the apiserver", func() {
+				So(err, ShouldBeNil)
+				So(nodeWriteVerbs(cli), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When updating a node whose resource version does not match the cache", func() {
+			node := newTestNode("node-1", "1")
+			node.ResourceVersion = "0"
+			node.Labels["new"] = "label"
+			cli.ClearActions()
+
+			err := h.updateNode(cli, node)
+
+			Convey("The node should be updated instead of patched", func() {
+				So(err, ShouldBeNil)
+				So(nodeWriteVerbs(cli), ShouldResemble, []string{"update"})
+			})
+		})
+	})
+}
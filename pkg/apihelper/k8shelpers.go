@@ -20,7 +20,9 @@ import (
 	"encoding/json"
 
 	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
 	k8sclient "k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
@@ -67,6 +69,11 @@ func (h K8sHelpers) GetNodes(cli *k8sclient.Clientset) (*api.NodeList, error) {
 	return cli.CoreV1().Nodes().List(meta_v1.ListOptions{})
 }
 
+func (h K8sHelpers) GetPods(cli *k8sclient.Clientset, nodeName string) (*api.PodList, error) {
+	selector := fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
+	return cli.CoreV1().Pods(meta_v1.NamespaceAll).List(meta_v1.ListOptions{FieldSelector: selector})
+}
+
 func (h K8sHelpers) UpdateNode(c *k8sclient.Clientset, n *api.Node) error {
 	// Send the updated node to the apiserver.
 	_, err := c.CoreV1().Nodes().Update(n)
@@ -86,3 +93,34 @@ func (h K8sHelpers) PatchStatus(c *k8sclient.Clientset, nodeName string, marshal
 
 	return err
 }
+
+func (h K8sHelpers) UpdateConfigMapData(c *k8sclient.Clientset, namespace, name string, data map[string]string) error {
+	cm, err := c.CoreV1().ConfigMaps(namespace).Get(name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &api.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       data,
+		}
+		_, err = c.CoreV1().ConfigMaps(namespace).Create(cm)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, len(data))
+	}
+	for k, v := range data {
+		cm.Data[k] = v
+	}
+	_, err = c.CoreV1().ConfigMaps(namespace).Update(cm)
+	return err
+}
+
+func (h K8sHelpers) DeleteConfigMap(c *k8sclient.Clientset, namespace, name string) error {
+	err := c.CoreV1().ConfigMaps(namespace).Delete(name, &meta_v1.DeleteOptions{})
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
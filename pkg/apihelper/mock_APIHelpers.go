@@ -16,6 +16,20 @@ type MockAPIHelpers struct {
 	mock.Mock
 }
 
+// DeleteConfigMap provides a mock function with given fields: _a0, _a1, _a2
+func (_m *MockAPIHelpers) DeleteConfigMap(_a0 *kubernetes.Clientset, _a1 string, _a2 string) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*kubernetes.Clientset, string, string) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetClient provides a mock function with given fields:
 func (_m *MockAPIHelpers) GetClient() (*kubernetes.Clientset, error) {
 	ret := _m.Called()
@@ -85,6 +99,29 @@ func (_m *MockAPIHelpers) GetNodes(_a0 *kubernetes.Clientset) (*v1.NodeList, err
 	return r0, r1
 }
 
+// GetPods provides a mock function with given fields: _a0, _a1
+func (_m *MockAPIHelpers) GetPods(_a0 *kubernetes.Clientset, _a1 string) (*v1.PodList, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *v1.PodList
+	if rf, ok := ret.Get(0).(func(*kubernetes.Clientset, string) *v1.PodList); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*v1.PodList)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(*kubernetes.Clientset, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PatchStatus provides a mock function with given fields: _a0, _a1, _a2
 func (_m *MockAPIHelpers) PatchStatus(_a0 *kubernetes.Clientset, _a1 string, _a2 interface{}) error {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -99,6 +136,20 @@ func (_m *MockAPIHelpers) PatchStatus(_a0 *kubernetes.Clientset, _a1 string, _a2
 	return r0
 }
 
+// UpdateConfigMapData provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *MockAPIHelpers) UpdateConfigMapData(_a0 *kubernetes.Clientset, _a1 string, _a2 string, _a3 map[string]string) error {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*kubernetes.Clientset, string, string, map[string]string) error); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateNode provides a mock function with given fields: _a0, _a1
 func (_m *MockAPIHelpers) UpdateNode(_a0 *kubernetes.Clientset, _a1 *v1.Node) error {
 	ret := _m.Called(_a0, _a1)
@@ -0,0 +1,40 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package labeler
+
+// Capabilities of nfd-master, advertised in the reply of GetCapabilities.
+// Masters not implementing GetCapabilities have none of the capabilities.
+const (
+	// CapabilityFeatureAnnotations means that the annotations of
+	// SetLabelsRequest are published
+	CapabilityFeatureAnnotations = "feature-annotations"
+	// CapabilityRejections means that rejected features are reported in
+	// SetLabelsReply
+	CapabilityRejections = "rejections"
+	// CapabilityGetLabels means that the GetLabels request is supported
+	CapabilityGetLabels = "get-labels"
+	// CapabilityWatchFeatures means that the WatchFeatures request is
+	// supported
+	CapabilityWatchFeatures = "watch-features"
+	// CapabilityExtendedResources means that the extended resources of
+	// SetLabelsRequest are published
+	CapabilityExtendedResources = "extended-resources"
+	// CapabilityTaints means that the taints of SetLabelsRequest are
+	// understood. Taints are applied only if enabled in nfd-master, otherwise
+	// they are rejected.
+	CapabilityTaints = "taints"
+)
@@ -27,6 +27,9 @@ type SetLabelsRequest struct {
 	NfdVersion           string            `protobuf:"bytes,1,opt,name=nfd_version,json=nfdVersion" json:"nfd_version,omitempty"`
 	NodeName             string            `protobuf:"bytes,2,opt,name=node_name,json=nodeName" json:"node_name,omitempty"`
 	Labels               map[string]string `protobuf:"bytes,3,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations          map[string]string `protobuf:"bytes,4,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExtendedResources    map[string]string `protobuf:"bytes,5,rep,name=extended_resources,json=extendedResources" json:"extended_resources,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Taints               []*Taint          `protobuf:"bytes,6,rep,name=taints" json:"taints,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
 	XXX_unrecognized     []byte            `json:"-"`
 	XXX_sizecache        int32             `json:"-"`
@@ -36,7 +39,7 @@ func (m *SetLabelsRequest) Reset()         { *m = SetLabelsRequest{} }
 func (m *SetLabelsRequest) String() string { return proto.CompactTextString(m) }
 func (*SetLabelsRequest) ProtoMessage()    {}
 func (*SetLabelsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_labeler_06d914ce56652184, []int{0}
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{0}
 }
 func (m *SetLabelsRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SetLabelsRequest.Unmarshal(m, b)
@@ -77,17 +80,93 @@ func (m *SetLabelsRequest) GetLabels() map[string]string {
 	return nil
 }
 
-type SetLabelsReply struct {
+func (m *SetLabelsRequest) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *SetLabelsRequest) GetExtendedResources() map[string]string {
+	if m != nil {
+		return m.ExtendedResources
+	}
+	return nil
+}
+
+func (m *SetLabelsRequest) GetTaints() []*Taint {
+	if m != nil {
+		return m.Taints
+	}
+	return nil
+}
+
+type Taint struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value                string   `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	Effect               string   `protobuf:"bytes,3,opt,name=effect" json:"effect,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
 }
 
+func (m *Taint) Reset()         { *m = Taint{} }
+func (m *Taint) String() string { return proto.CompactTextString(m) }
+func (*Taint) ProtoMessage()    {}
+func (*Taint) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{1}
+}
+func (m *Taint) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Taint.Unmarshal(m, b)
+}
+func (m *Taint) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Taint.Marshal(b, m, deterministic)
+}
+func (dst *Taint) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Taint.Merge(dst, src)
+}
+func (m *Taint) XXX_Size() int {
+	return xxx_messageInfo_Taint.Size(m)
+}
+func (m *Taint) XXX_DiscardUnknown() {
+	xxx_messageInfo_Taint.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Taint proto.InternalMessageInfo
+
+func (m *Taint) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Taint) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+func (m *Taint) GetEffect() string {
+	if m != nil {
+		return m.Effect
+	}
+	return ""
+}
+
+type SetLabelsReply struct {
+	Rejected             []*Rejection `protobuf:"bytes,1,rep,name=rejected" json:"rejected,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
 func (m *SetLabelsReply) Reset()         { *m = SetLabelsReply{} }
 func (m *SetLabelsReply) String() string { return proto.CompactTextString(m) }
 func (*SetLabelsReply) ProtoMessage()    {}
 func (*SetLabelsReply) Descriptor() ([]byte, []int) {
-	return fileDescriptor_labeler_06d914ce56652184, []int{1}
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{2}
 }
 func (m *SetLabelsReply) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SetLabelsReply.Unmarshal(m, b)
@@ -107,10 +186,344 @@ func (m *SetLabelsReply) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_SetLabelsReply proto.InternalMessageInfo
 
+func (m *SetLabelsReply) GetRejected() []*Rejection {
+	if m != nil {
+		return m.Rejected
+	}
+	return nil
+}
+
+type Rejection struct {
+	Kind                 string   `protobuf:"bytes,1,opt,name=kind" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Reason               string   `protobuf:"bytes,3,opt,name=reason" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Rejection) Reset()         { *m = Rejection{} }
+func (m *Rejection) String() string { return proto.CompactTextString(m) }
+func (*Rejection) ProtoMessage()    {}
+func (*Rejection) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{3}
+}
+func (m *Rejection) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Rejection.Unmarshal(m, b)
+}
+func (m *Rejection) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Rejection.Marshal(b, m, deterministic)
+}
+func (dst *Rejection) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Rejection.Merge(dst, src)
+}
+func (m *Rejection) XXX_Size() int {
+	return xxx_messageInfo_Rejection.Size(m)
+}
+func (m *Rejection) XXX_DiscardUnknown() {
+	xxx_messageInfo_Rejection.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Rejection proto.InternalMessageInfo
+
+func (m *Rejection) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Rejection) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Rejection) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+type GetLabelsRequest struct {
+	NodeName             string   `protobuf:"bytes,1,opt,name=node_name,json=nodeName" json:"node_name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetLabelsRequest) Reset()         { *m = GetLabelsRequest{} }
+func (m *GetLabelsRequest) String() string { return proto.CompactTextString(m) }
+func (*GetLabelsRequest) ProtoMessage()    {}
+func (*GetLabelsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{4}
+}
+func (m *GetLabelsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLabelsRequest.Unmarshal(m, b)
+}
+func (m *GetLabelsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLabelsRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetLabelsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLabelsRequest.Merge(dst, src)
+}
+func (m *GetLabelsRequest) XXX_Size() int {
+	return xxx_messageInfo_GetLabelsRequest.Size(m)
+}
+func (m *GetLabelsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLabelsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLabelsRequest proto.InternalMessageInfo
+
+func (m *GetLabelsRequest) GetNodeName() string {
+	if m != nil {
+		return m.NodeName
+	}
+	return ""
+}
+
+type GetLabelsReply struct {
+	Labels               map[string]string `protobuf:"bytes,1,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Annotations          map[string]string `protobuf:"bytes,2,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	ExtendedResources    map[string]string `protobuf:"bytes,3,rep,name=extended_resources,json=extendedResources" json:"extended_resources,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *GetLabelsReply) Reset()         { *m = GetLabelsReply{} }
+func (m *GetLabelsReply) String() string { return proto.CompactTextString(m) }
+func (*GetLabelsReply) ProtoMessage()    {}
+func (*GetLabelsReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{5}
+}
+func (m *GetLabelsReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetLabelsReply.Unmarshal(m, b)
+}
+func (m *GetLabelsReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetLabelsReply.Marshal(b, m, deterministic)
+}
+func (dst *GetLabelsReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetLabelsReply.Merge(dst, src)
+}
+func (m *GetLabelsReply) XXX_Size() int {
+	return xxx_messageInfo_GetLabelsReply.Size(m)
+}
+func (m *GetLabelsReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetLabelsReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetLabelsReply proto.InternalMessageInfo
+
+func (m *GetLabelsReply) GetLabels() map[string]string {
+	if m != nil {
+		return m.Labels
+	}
+	return nil
+}
+
+func (m *GetLabelsReply) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *GetLabelsReply) GetExtendedResources() map[string]string {
+	if m != nil {
+		return m.ExtendedResources
+	}
+	return nil
+}
+
+type GetCapabilitiesRequest struct {
+	NfdVersion           string   `protobuf:"bytes,1,opt,name=nfd_version,json=nfdVersion" json:"nfd_version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetCapabilitiesRequest) Reset()         { *m = GetCapabilitiesRequest{} }
+func (m *GetCapabilitiesRequest) String() string { return proto.CompactTextString(m) }
+func (*GetCapabilitiesRequest) ProtoMessage()    {}
+func (*GetCapabilitiesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{6}
+}
+func (m *GetCapabilitiesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCapabilitiesRequest.Unmarshal(m, b)
+}
+func (m *GetCapabilitiesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCapabilitiesRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetCapabilitiesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCapabilitiesRequest.Merge(dst, src)
+}
+func (m *GetCapabilitiesRequest) XXX_Size() int {
+	return xxx_messageInfo_GetCapabilitiesRequest.Size(m)
+}
+func (m *GetCapabilitiesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCapabilitiesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCapabilitiesRequest proto.InternalMessageInfo
+
+func (m *GetCapabilitiesRequest) GetNfdVersion() string {
+	if m != nil {
+		return m.NfdVersion
+	}
+	return ""
+}
+
+type GetCapabilitiesReply struct {
+	NfdVersion           string   `protobuf:"bytes,1,opt,name=nfd_version,json=nfdVersion" json:"nfd_version,omitempty"`
+	Capabilities         []string `protobuf:"bytes,2,rep,name=capabilities" json:"capabilities,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetCapabilitiesReply) Reset()         { *m = GetCapabilitiesReply{} }
+func (m *GetCapabilitiesReply) String() string { return proto.CompactTextString(m) }
+func (*GetCapabilitiesReply) ProtoMessage()    {}
+func (*GetCapabilitiesReply) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{7}
+}
+func (m *GetCapabilitiesReply) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCapabilitiesReply.Unmarshal(m, b)
+}
+func (m *GetCapabilitiesReply) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCapabilitiesReply.Marshal(b, m, deterministic)
+}
+func (dst *GetCapabilitiesReply) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCapabilitiesReply.Merge(dst, src)
+}
+func (m *GetCapabilitiesReply) XXX_Size() int {
+	return xxx_messageInfo_GetCapabilitiesReply.Size(m)
+}
+func (m *GetCapabilitiesReply) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCapabilitiesReply.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCapabilitiesReply proto.InternalMessageInfo
+
+func (m *GetCapabilitiesReply) GetNfdVersion() string {
+	if m != nil {
+		return m.NfdVersion
+	}
+	return ""
+}
+
+func (m *GetCapabilitiesReply) GetCapabilities() []string {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+type WatchFeaturesRequest struct {
+	NodeName             string   `protobuf:"bytes,1,opt,name=node_name,json=nodeName" json:"node_name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchFeaturesRequest) Reset()         { *m = WatchFeaturesRequest{} }
+func (m *WatchFeaturesRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchFeaturesRequest) ProtoMessage()    {}
+func (*WatchFeaturesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{8}
+}
+func (m *WatchFeaturesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchFeaturesRequest.Unmarshal(m, b)
+}
+func (m *WatchFeaturesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchFeaturesRequest.Marshal(b, m, deterministic)
+}
+func (dst *WatchFeaturesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchFeaturesRequest.Merge(dst, src)
+}
+func (m *WatchFeaturesRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchFeaturesRequest.Size(m)
+}
+func (m *WatchFeaturesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchFeaturesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchFeaturesRequest proto.InternalMessageInfo
+
+func (m *WatchFeaturesRequest) GetNodeName() string {
+	if m != nil {
+		return m.NodeName
+	}
+	return ""
+}
+
+type FeatureEvent struct {
+	NodeName             string   `protobuf:"bytes,1,opt,name=node_name,json=nodeName" json:"node_name,omitempty"`
+	Reason               string   `protobuf:"bytes,2,opt,name=reason" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FeatureEvent) Reset()         { *m = FeatureEvent{} }
+func (m *FeatureEvent) String() string { return proto.CompactTextString(m) }
+func (*FeatureEvent) ProtoMessage()    {}
+func (*FeatureEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_labeler_2cdfccb14747e2e9, []int{9}
+}
+func (m *FeatureEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FeatureEvent.Unmarshal(m, b)
+}
+func (m *FeatureEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FeatureEvent.Marshal(b, m, deterministic)
+}
+func (dst *FeatureEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FeatureEvent.Merge(dst, src)
+}
+func (m *FeatureEvent) XXX_Size() int {
+	return xxx_messageInfo_FeatureEvent.Size(m)
+}
+func (m *FeatureEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_FeatureEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FeatureEvent proto.InternalMessageInfo
+
+func (m *FeatureEvent) GetNodeName() string {
+	if m != nil {
+		return m.NodeName
+	}
+	return ""
+}
+
+func (m *FeatureEvent) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*SetLabelsRequest)(nil), "labeler.SetLabelsRequest")
 	proto.RegisterMapType((map[string]string)(nil), "labeler.SetLabelsRequest.LabelsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "labeler.SetLabelsRequest.AnnotationsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "labeler.SetLabelsRequest.ExtendedResourcesEntry")
+	proto.RegisterType((*Taint)(nil), "labeler.Taint")
 	proto.RegisterType((*SetLabelsReply)(nil), "labeler.SetLabelsReply")
+	proto.RegisterType((*Rejection)(nil), "labeler.Rejection")
+	proto.RegisterType((*GetLabelsRequest)(nil), "labeler.GetLabelsRequest")
+	proto.RegisterType((*GetLabelsReply)(nil), "labeler.GetLabelsReply")
+	proto.RegisterMapType((map[string]string)(nil), "labeler.GetLabelsReply.LabelsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "labeler.GetLabelsReply.AnnotationsEntry")
+	proto.RegisterMapType((map[string]string)(nil), "labeler.GetLabelsReply.ExtendedResourcesEntry")
+	proto.RegisterType((*GetCapabilitiesRequest)(nil), "labeler.GetCapabilitiesRequest")
+	proto.RegisterType((*GetCapabilitiesReply)(nil), "labeler.GetCapabilitiesReply")
+	proto.RegisterType((*WatchFeaturesRequest)(nil), "labeler.WatchFeaturesRequest")
+	proto.RegisterType((*FeatureEvent)(nil), "labeler.FeatureEvent")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -125,6 +538,9 @@ const _ = grpc.SupportPackageIsVersion4
 
 type LabelerClient interface {
 	SetLabels(ctx context.Context, in *SetLabelsRequest, opts ...grpc.CallOption) (*SetLabelsReply, error)
+	GetLabels(ctx context.Context, in *GetLabelsRequest, opts ...grpc.CallOption) (*GetLabelsReply, error)
+	GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*GetCapabilitiesReply, error)
+	WatchFeatures(ctx context.Context, in *WatchFeaturesRequest, opts ...grpc.CallOption) (Labeler_WatchFeaturesClient, error)
 }
 
 type labelerClient struct {
@@ -144,10 +560,63 @@ func (c *labelerClient) SetLabels(ctx context.Context, in *SetLabelsRequest, opt
 	return out, nil
 }
 
+func (c *labelerClient) GetLabels(ctx context.Context, in *GetLabelsRequest, opts ...grpc.CallOption) (*GetLabelsReply, error) {
+	out := new(GetLabelsReply)
+	err := grpc.Invoke(ctx, "/labeler.Labeler/GetLabels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *labelerClient) GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*GetCapabilitiesReply, error) {
+	out := new(GetCapabilitiesReply)
+	err := grpc.Invoke(ctx, "/labeler.Labeler/GetCapabilities", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *labelerClient) WatchFeatures(ctx context.Context, in *WatchFeaturesRequest, opts ...grpc.CallOption) (Labeler_WatchFeaturesClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Labeler_serviceDesc.Streams[0], c.cc, "/labeler.Labeler/WatchFeatures", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &labelerWatchFeaturesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Labeler_WatchFeaturesClient interface {
+	Recv() (*FeatureEvent, error)
+	grpc.ClientStream
+}
+
+type labelerWatchFeaturesClient struct {
+	grpc.ClientStream
+}
+
+func (x *labelerWatchFeaturesClient) Recv() (*FeatureEvent, error) {
+	m := new(FeatureEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Labeler service
 
 type LabelerServer interface {
 	SetLabels(context.Context, *SetLabelsRequest) (*SetLabelsReply, error)
+	GetLabels(context.Context, *GetLabelsRequest) (*GetLabelsReply, error)
+	GetCapabilities(context.Context, *GetCapabilitiesRequest) (*GetCapabilitiesReply, error)
+	WatchFeatures(*WatchFeaturesRequest, Labeler_WatchFeaturesServer) error
 }
 
 func RegisterLabelerServer(s *grpc.Server, srv LabelerServer) {
@@ -172,6 +641,63 @@ func _Labeler_SetLabels_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Labeler_GetLabels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLabelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LabelerServer).GetLabels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/labeler.Labeler/GetLabels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LabelerServer).GetLabels(ctx, req.(*GetLabelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Labeler_GetCapabilities_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCapabilitiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LabelerServer).GetCapabilities(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/labeler.Labeler/GetCapabilities",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LabelerServer).GetCapabilities(ctx, req.(*GetCapabilitiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Labeler_WatchFeatures_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchFeaturesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LabelerServer).WatchFeatures(m, &labelerWatchFeaturesServer{stream})
+}
+
+type Labeler_WatchFeaturesServer interface {
+	Send(*FeatureEvent) error
+	grpc.ServerStream
+}
+
+type labelerWatchFeaturesServer struct {
+	grpc.ServerStream
+}
+
+func (x *labelerWatchFeaturesServer) Send(m *FeatureEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Labeler_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "labeler.Labeler",
 	HandlerType: (*LabelerServer)(nil),
@@ -180,27 +706,63 @@ var _Labeler_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SetLabels",
 			Handler:    _Labeler_SetLabels_Handler,
 		},
+		{
+			MethodName: "GetLabels",
+			Handler:    _Labeler_GetLabels_Handler,
+		},
+		{
+			MethodName: "GetCapabilities",
+			Handler:    _Labeler_GetCapabilities_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchFeatures",
+			Handler:       _Labeler_WatchFeatures_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "labeler.proto",
 }
 
-func init() { proto.RegisterFile("labeler.proto", fileDescriptor_labeler_06d914ce56652184) }
-
-var fileDescriptor_labeler_06d914ce56652184 = []byte{
-	// 220 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0xcd, 0x49, 0x4c, 0x4a,
-	0xcd, 0x49, 0x2d, 0xd2, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x87, 0x72, 0x95, 0x4e, 0x31,
-	0x72, 0x09, 0x04, 0xa7, 0x96, 0xf8, 0x80, 0xb8, 0xc5, 0x41, 0xa9, 0x85, 0xa5, 0xa9, 0xc5, 0x25,
-	0x42, 0xf2, 0x5c, 0xdc, 0x79, 0x69, 0x29, 0xf1, 0x65, 0xa9, 0x45, 0xc5, 0x99, 0xf9, 0x79, 0x12,
-	0x8c, 0x0a, 0x8c, 0x1a, 0x9c, 0x41, 0x5c, 0x79, 0x69, 0x29, 0x61, 0x10, 0x11, 0x21, 0x69, 0x2e,
-	0xce, 0xbc, 0xfc, 0x94, 0xd4, 0xf8, 0xbc, 0xc4, 0xdc, 0x54, 0x09, 0x26, 0xb0, 0x34, 0x07, 0x48,
-	0xc0, 0x2f, 0x31, 0x37, 0x55, 0xc8, 0x96, 0x8b, 0x0d, 0x6c, 0x7a, 0xb1, 0x04, 0xb3, 0x02, 0xb3,
-	0x06, 0xb7, 0x91, 0xaa, 0x1e, 0xcc, 0x6e, 0x74, 0x8b, 0xf4, 0x20, 0x3c, 0xd7, 0xbc, 0x92, 0xa2,
-	0xca, 0x20, 0xa8, 0x26, 0x29, 0x4b, 0x2e, 0x6e, 0x24, 0x61, 0x21, 0x01, 0x2e, 0xe6, 0xec, 0xd4,
-	0x4a, 0xa8, 0x1b, 0x40, 0x4c, 0x21, 0x11, 0x2e, 0xd6, 0xb2, 0xc4, 0x9c, 0x52, 0x98, 0xc5, 0x10,
-	0x8e, 0x15, 0x93, 0x05, 0xa3, 0x92, 0x00, 0x17, 0x1f, 0x92, 0x15, 0x05, 0x39, 0x95, 0x46, 0x3e,
-	0x5c, 0xec, 0x3e, 0x10, 0xcb, 0x85, 0x1c, 0xb9, 0x38, 0xe1, 0x92, 0x42, 0x92, 0x38, 0xdd, 0x24,
-	0x25, 0x8e, 0x4d, 0xaa, 0x20, 0xa7, 0x52, 0x89, 0x21, 0x89, 0x0d, 0x1c, 0x78, 0xc6, 0x80, 0x00,
-	0x00, 0x00, 0xff, 0xff, 0x2f, 0x68, 0x0f, 0x06, 0x4d, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("labeler.proto", fileDescriptor_labeler_2cdfccb14747e2e9) }
+
+var fileDescriptor_labeler_2cdfccb14747e2e9 = []byte{
+	// 576 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xd4, 0x55, 0x4d, 0x6f, 0xd3, 0x40,
+	0x10, 0xad, 0x93, 0x26, 0x6d, 0x26, 0x6d, 0x08, 0xa3, 0x10, 0x4c, 0x10, 0x6a, 0xb5, 0x08, 0x14,
+	0x71, 0x08, 0x55, 0x7b, 0xa1, 0x20, 0x10, 0x55, 0x09, 0x16, 0x10, 0x71, 0x70, 0x11, 0x1c, 0x10,
+	0x8a, 0x36, 0xf6, 0x44, 0x98, 0xba, 0xeb, 0x60, 0x6f, 0x22, 0xf2, 0x8b, 0x38, 0x72, 0xe6, 0xdf,
+	0x21, 0xaf, 0x1d, 0xd7, 0x36, 0x76, 0x49, 0x8f, 0xdc, 0x76, 0x3e, 0xde, 0xcb, 0xcc, 0xdb, 0xb7,
+	0x0e, 0xec, 0xba, 0x7c, 0x42, 0x2e, 0xf9, 0x83, 0x99, 0xef, 0x49, 0x0f, 0xb7, 0xe2, 0x90, 0xfd,
+	0xdc, 0x84, 0xf6, 0x19, 0xc9, 0x51, 0x18, 0x06, 0x26, 0x7d, 0x9f, 0x53, 0x20, 0x71, 0x0f, 0x9a,
+	0x62, 0x6a, 0x8f, 0x17, 0xe4, 0x07, 0x8e, 0x27, 0x74, 0x6d, 0x5f, 0xeb, 0x37, 0x4c, 0x10, 0x53,
+	0xfb, 0x63, 0x94, 0xc1, 0xbb, 0xd0, 0x10, 0x9e, 0x4d, 0x63, 0xc1, 0x2f, 0x48, 0xaf, 0xa8, 0xf2,
+	0x76, 0x98, 0x78, 0xcf, 0x2f, 0x08, 0x9f, 0x43, 0x5d, 0xb1, 0x07, 0x7a, 0x75, 0xbf, 0xda, 0x6f,
+	0x1e, 0x3e, 0x18, 0xac, 0x7e, 0x3b, 0xff, 0x43, 0x83, 0x28, 0x1a, 0x0a, 0xe9, 0x2f, 0xcd, 0x18,
+	0x84, 0x23, 0x68, 0x72, 0x21, 0x3c, 0xc9, 0xa5, 0xe3, 0x89, 0x40, 0xdf, 0x54, 0x1c, 0x8f, 0xca,
+	0x39, 0x4e, 0x2e, 0x9b, 0x23, 0xa2, 0x34, 0x1c, 0xc7, 0x80, 0xf4, 0x43, 0x92, 0xb0, 0xc9, 0x1e,
+	0xfb, 0x14, 0x78, 0x73, 0xdf, 0xa2, 0x40, 0xaf, 0x29, 0xd2, 0x83, 0x72, 0xd2, 0x61, 0x8c, 0x31,
+	0x57, 0x90, 0x88, 0xfa, 0x26, 0xe5, 0xf3, 0xf8, 0x10, 0xea, 0x92, 0x3b, 0x42, 0x06, 0x7a, 0x5d,
+	0x91, 0xb6, 0x12, 0xd2, 0x0f, 0x61, 0xda, 0x8c, 0xab, 0xbd, 0x63, 0x68, 0xa6, 0xb6, 0xc5, 0x36,
+	0x54, 0xcf, 0x69, 0x19, 0x4b, 0x1b, 0x1e, 0xb1, 0x03, 0xb5, 0x05, 0x77, 0xe7, 0x2b, 0x3d, 0xa3,
+	0xe0, 0x69, 0xe5, 0x89, 0xd6, 0x7b, 0x01, 0xed, 0xfc, 0x92, 0xd7, 0xc2, 0xbf, 0x82, 0x6e, 0xf1,
+	0x3e, 0xd7, 0x61, 0x61, 0x06, 0xd4, 0xd4, 0x46, 0xeb, 0x82, 0xb0, 0x0b, 0x75, 0x9a, 0x4e, 0xc9,
+	0x92, 0x7a, 0x55, 0xa5, 0xe3, 0x88, 0xbd, 0x84, 0x56, 0x4a, 0xef, 0x99, 0xbb, 0xc4, 0x01, 0x6c,
+	0xfb, 0xf4, 0x8d, 0x2c, 0x49, 0xb6, 0xae, 0x29, 0x15, 0x31, 0x51, 0xd1, 0x54, 0x05, 0xc7, 0x13,
+	0x66, 0xd2, 0xc3, 0xde, 0x41, 0x23, 0x49, 0x23, 0xc2, 0xe6, 0xb9, 0x23, 0xec, 0x78, 0x1e, 0x75,
+	0x0e, 0x73, 0x29, 0x6b, 0xaa, 0x73, 0x38, 0x8e, 0x4f, 0x3c, 0xf0, 0xc4, 0x6a, 0x9c, 0x28, 0x62,
+	0x8f, 0xa1, 0x6d, 0xe4, 0x1f, 0x40, 0xc6, 0xdf, 0x5a, 0xd6, 0xdf, 0xec, 0x77, 0x15, 0x5a, 0x46,
+	0x76, 0x81, 0x67, 0x89, 0xe5, 0xa3, 0xf1, 0xef, 0x27, 0xe3, 0x67, 0x1b, 0x0b, 0x0d, 0xff, 0x36,
+	0x6b, 0xf8, 0x8a, 0x62, 0xe8, 0x97, 0x31, 0x5c, 0x6d, 0xf7, 0x2f, 0x85, 0x76, 0x8f, 0xde, 0xe1,
+	0xa0, 0x8c, 0x72, 0x6d, 0xb3, 0xff, 0xff, 0x26, 0x3e, 0x86, 0xae, 0x41, 0xf2, 0x94, 0xcf, 0xf8,
+	0xc4, 0x71, 0x1d, 0xe9, 0xd0, 0xda, 0xdf, 0x3c, 0xf6, 0x19, 0x3a, 0x7f, 0x41, 0xc3, 0xbb, 0xff,
+	0x17, 0x10, 0x19, 0xec, 0x58, 0x29, 0x94, 0xba, 0xe0, 0x86, 0x99, 0xc9, 0xb1, 0x23, 0xe8, 0x7c,
+	0xe2, 0xd2, 0xfa, 0xfa, 0x9a, 0xb8, 0x9c, 0xfb, 0xb4, 0x9e, 0x11, 0x4f, 0x61, 0x27, 0xee, 0x1f,
+	0x2e, 0x48, 0x5c, 0xdd, 0x9c, 0xb2, 0x7f, 0x25, 0x6d, 0xff, 0xc3, 0x5f, 0x15, 0xd8, 0x1a, 0x45,
+	0xbe, 0xc0, 0x13, 0x68, 0x24, 0x2f, 0x13, 0xef, 0x94, 0x7e, 0x1d, 0x7b, 0xb7, 0x8b, 0x4a, 0x33,
+	0x77, 0xc9, 0x36, 0x42, 0x0a, 0xa3, 0x80, 0xc2, 0x28, 0xa7, 0x30, 0xf2, 0x14, 0x67, 0x70, 0x23,
+	0x27, 0x34, 0xee, 0xa5, 0xbb, 0x0b, 0x6e, 0xaf, 0x77, 0xaf, 0xbc, 0x21, 0x22, 0x7d, 0x03, 0xbb,
+	0x19, 0x81, 0xf1, 0x12, 0x51, 0x24, 0x7c, 0xef, 0x56, 0x52, 0x4e, 0x4b, 0xcc, 0x36, 0x0e, 0xb4,
+	0x49, 0x5d, 0xfd, 0x85, 0x1e, 0xfd, 0x19, 0x00, 0x2a, 0xfc, 0x41, 0x03, 0x53, 0x07, 0x00, 0x00,
 }
@@ -17,6 +17,66 @@ type MockLabelerClient struct {
 	mock.Mock
 }
 
+// GetCapabilities provides a mock function with given fields: ctx, in, opts
+func (_m *MockLabelerClient) GetCapabilities(ctx context.Context, in *GetCapabilitiesRequest, opts ...grpc.CallOption) (*GetCapabilitiesReply, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *GetCapabilitiesReply
+	if rf, ok := ret.Get(0).(func(context.Context, *GetCapabilitiesRequest, ...grpc.CallOption) *GetCapabilitiesReply); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetCapabilitiesReply)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *GetCapabilitiesRequest, ...grpc.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetLabels provides a mock function with given fields: ctx, in, opts
+func (_m *MockLabelerClient) GetLabels(ctx context.Context, in *GetLabelsRequest, opts ...grpc.CallOption) (*GetLabelsReply, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *GetLabelsReply
+	if rf, ok := ret.Get(0).(func(context.Context, *GetLabelsRequest, ...grpc.CallOption) *GetLabelsReply); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetLabelsReply)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *GetLabelsRequest, ...grpc.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // SetLabels provides a mock function with given fields: ctx, in, opts
 func (_m *MockLabelerClient) SetLabels(ctx context.Context, in *SetLabelsRequest, opts ...grpc.CallOption) (*SetLabelsReply, error) {
 	_va := make([]interface{}, len(opts))
@@ -46,3 +106,33 @@ func (_m *MockLabelerClient) SetLabels(ctx context.Context, in *SetLabelsRequest
 
 	return r0, r1
 }
+
+// WatchFeatures provides a mock function with given fields: ctx, in, opts
+func (_m *MockLabelerClient) WatchFeatures(ctx context.Context, in *WatchFeaturesRequest, opts ...grpc.CallOption) (Labeler_WatchFeaturesClient, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 Labeler_WatchFeaturesClient
+	if rf, ok := ret.Get(0).(func(context.Context, *WatchFeaturesRequest, ...grpc.CallOption) Labeler_WatchFeaturesClient); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(Labeler_WatchFeaturesClient)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *WatchFeaturesRequest, ...grpc.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Max number of audit records queued for sending to the webhook
+const auditWebhookQueueLen = 1000
+
+// auditRecord describes the changes made to one node object
+type auditRecord struct {
+	Timestamp         string                      `json:"timestamp"`
+	Node              string                      `json:"node"`
+	Requester         string                      `json:"requester"`
+	LabelsAdded       map[string]string           `json:"labelsAdded,omitempty"`
+	LabelsRemoved     map[string]string           `json:"labelsRemoved,omitempty"`
+	LabelsChanged     map[string]auditValueChange `json:"labelsChanged,omitempty"`
+	TaintsAdded       []string                    `json:"taintsAdded,omitempty"`
+	TaintsRemoved     []string                    `json:"taintsRemoved,omitempty"`
+	ExtendedResources []statusOp                  `json:"extendedResources,omitempty"`
+}
+
+// auditValueChange holds the old and new value of a changed label
+type auditValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// auditLog records all changes made to node objects as JSON lines into a
+// file and/or to a webhook
+type auditLog struct {
+	sync.Mutex
+	clock   clock.Clock
+	file    io.WriteCloser
+	webhook string
+	queue   chan []byte
+}
+
+// newAuditLog creates a new audit log. Nil is returned if neither the file
+// nor the webhook is specified.
+func newAuditLog(path, webhook string, c clock.Clock) (*auditLog, error) {
+	if path == "" && webhook == "" {
+		return nil, nil
+	}
+
+	a := &auditLog{clock: c, webhook: webhook}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %v", err)
+		}
+		a.file = f
+	}
+	if webhook != "" {
+		a.queue = make(chan []byte, auditWebhookQueueLen)
+		go a.runWebhookSender()
+	}
+	return a, nil
+}
+
+// record writes an audit record of the changes made to a node. Nothing is
+// recorded if there are no changes.
+func (a *auditLog) record(node, requester string, changes nodeChanges) {
+	if a == nil || changes.empty() {
+		return
+	}
+
+	r := auditRecord{
+		Timestamp:         a.clock.Now().UTC().Format(time.RFC3339),
+		Node:              node,
+		Requester:         requester,
+		TaintsAdded:       changes.taints.added,
+		TaintsRemoved:     changes.taints.removed,
+		ExtendedResources: changes.statusOps,
+	}
+	if len(changes.labels.added) > 0 {
+		r.LabelsAdded = make(map[string]string, len(changes.labels.added))
+		for _, l := range changes.labels.added {
+			r.LabelsAdded[l] = changes.newLabels[l]
+		}
+	}
+	if len(changes.labels.removed) > 0 {
+		r.LabelsRemoved = make(map[string]string, len(changes.labels.removed))
+		for _, l := range changes.labels.removed {
+			r.LabelsRemoved[l] = changes.oldLabels[l]
+		}
+	}
+	if len(changes.labels.changed) > 0 {
+		r.LabelsChanged = make(map[string]auditValueChange, len(changes.labels.changed))
+		for _, l := range changes.labels.changed {
+			r.LabelsChanged[l] = auditValueChange{Old: changes.oldLabels[l], New: changes.newLabels[l]}
+		}
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		stderrLogger.Printf("failed to serialize audit record: %v", err)
+		return
+	}
+
+	if a.file != nil {
+		a.Lock()
+		_, err := a.file.Write(append(data, '\n'))
+		a.Unlock()
+		if err != nil {
+			stderrLogger.Printf("failed to write audit log: %v", err)
+		}
+	}
+	if a.queue != nil {
+		select {
+		case a.queue <- data:
+		default:
+			stderrLogger.Printf("audit webhook queue full, dropping audit record of node %q", node)
+		}
+	}
+}
+
+// runWebhookSender sends queued audit records to the webhook, in order
+func (a *auditLog) runWebhookSender() {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for data := range a.queue {
+		resp, err := client.Post(a.webhook, "application/json", bytes.NewReader(data))
+		if err != nil {
+			stderrLogger.Printf("failed to send audit record: %v", err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			stderrLogger.Printf("failed to send audit record: webhook returned %s", resp.Status)
+		}
+	}
+}
+
+// requesterIdentity returns the identity of the gRPC client, i.e. the CN of
+// the verified TLS client certificate or the network address of the client
+func requesterIdentity(c context.Context) string {
+	client, ok := peer.FromContext(c)
+	if !ok {
+		return ""
+	}
+	if tlsAuth, ok := client.AuthInfo.(credentials.TLSInfo); ok {
+		if len(tlsAuth.State.VerifiedChains) > 0 && len(tlsAuth.State.VerifiedChains[0]) > 0 {
+			return tlsAuth.State.VerifiedChains[0][0].Subject.CommonName
+		}
+	}
+	return client.Addr.String()
+}
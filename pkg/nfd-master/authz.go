@@ -0,0 +1,144 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"crypto/x509"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// clientCertificate returns the verified TLS certificate of the client, or,
+// nil if the client was not authenticated with a certificate
+func clientCertificate(c context.Context) *x509.Certificate {
+	client, ok := peer.FromContext(c)
+	if !ok {
+		return nil
+	}
+	tlsAuth, ok := client.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	if len(tlsAuth.State.VerifiedChains) == 0 || len(tlsAuth.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return tlsAuth.State.VerifiedChains[0][0]
+}
+
+// matches returns true if the client certificate matches the rule
+func (r *ClientNamespaceRule) matches(cert *x509.Certificate) bool {
+	return certMatches(cert, r.OrganizationalUnit, r.URI)
+}
+
+// certMatches returns true if the certificate has the given OU in its subject
+// and the given URI SAN. Empty attributes are not checked.
+func certMatches(cert *x509.Certificate, organizationalUnit, uri string) bool {
+	if cert == nil {
+		return false
+	}
+	if organizationalUnit != "" {
+		found := false
+		for _, ou := range cert.Subject.OrganizationalUnit {
+			if ou == organizationalUnit {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if uri != "" {
+		found := false
+		for _, u := range cert.URIs {
+			if u.String() == uri {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// deniedNamespaces returns the set of namespaces the client is not allowed
+// to publish to. Namespaces listed in the rules are restricted to the clients
+// matching (one of) the rules listing them. Other namespaces are not affected.
+func deniedNamespaces(rules []ClientNamespaceRule, cert *x509.Certificate) map[string]bool {
+	denied := map[string]bool{}
+	allowed := map[string]bool{}
+	for i := range rules {
+		r := &rules[i]
+		m := r.matches(cert)
+		for _, ns := range r.Namespaces {
+			if m {
+				allowed[ns] = true
+			} else {
+				denied[ns] = true
+			}
+		}
+	}
+	for ns := range allowed {
+		delete(denied, ns)
+	}
+	return denied
+}
+
+// filterDeniedNamespaces removes entries in denied namespaces from a set of
+// labels or annotations (as specified by kind)
+func filterDeniedNamespaces(items map[string]string, denied map[string]bool, kind string, rej *rejections) {
+	for name := range items {
+		split := strings.SplitN(name, "/", 2)
+		if len(split) == 2 && denied[split[0]] {
+			rej.add(kind, name, "client is not authorized to publish in namespace '%s'", split[0])
+			delete(items, name)
+		}
+	}
+}
+
+// certMatchesNode returns true if the client certificate is valid for the
+// node. The CN of the certificate must either be equal to the node name, or,
+// the node name must be extracted from the CN or a DNS SAN with one of the
+// patterns. The node name is taken from the capture group named "nodename",
+// or, the first capture group of the pattern.
+func certMatchesNode(cert *x509.Certificate, nodeName string, patterns []*regexp.Regexp) bool {
+	if cert.Subject.CommonName == nodeName {
+		return true
+	}
+
+	identities := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, re := range patterns {
+		idx := 1
+		for i, name := range re.SubexpNames() {
+			if name == "nodename" {
+				idx = i
+			}
+		}
+		for _, id := range identities {
+			if m := re.FindStringSubmatch(id); m != nil && m[idx] == nodeName {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// bulkOptions control operations done on all nodes of the cluster
+type bulkOptions struct {
+	// Number of nodes processed in parallel
+	concurrency int
+	// Max time to wait for the operation on one node to finish, zero means
+	// no timeout
+	nodeTimeout time.Duration
+	// Deadline for the whole operation, zero means no deadline
+	timeout time.Duration
+}
+
+// runBulk runs an operation on a set of nodes, in parallel. Nodes that have
+// not been started when the overall deadline expires are skipped. An error
+// listing all failed nodes is returned.
+func runBulk(operation string, nodes []string, opts bulkOptions, c clock.Clock, fn func(node string) error) error {
+	concurrency := opts.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	bulkOperationNodes.WithLabelValues(operation).Set(float64(len(nodes)))
+
+	var deadline <-chan time.Time
+	if opts.timeout > 0 {
+		deadline = c.After(opts.timeout)
+	}
+
+	var (
+		mutex  sync.Mutex
+		failed = map[string]string{}
+		wg     sync.WaitGroup
+	)
+	fail := func(node, reason, result string) {
+		mutex.Lock()
+		failed[node] = reason
+		mutex.Unlock()
+		bulkOperationNodesProcessed.WithLabelValues(operation, result).Inc()
+	}
+
+	work := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for node := range work {
+				timedOut, err := runWithTimeout(func() error { return fn(node) }, opts.nodeTimeout, c)
+				switch {
+				case timedOut:
+					stderrLogger.Printf("%s of node %q timed out after %v", operation, node, opts.nodeTimeout)
+					fail(node, "timed out", "timeout")
+				case err != nil:
+					stderrLogger.Printf("%s of node %q failed: %v", operation, node, err)
+					fail(node, err.Error(), "failure")
+				default:
+					bulkOperationNodesProcessed.WithLabelValues(operation, "success").Inc()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i, node := range nodes {
+		select {
+		case work <- node:
+		case <-deadline:
+			stderrLogger.Printf("%s deadline of %v exceeded, skipping %d nodes", operation, opts.timeout, len(nodes)-i)
+			for _, n := range nodes[i:] {
+				fail(n, "deadline exceeded", "skipped")
+			}
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if len(failed) > 0 {
+		msgs := make([]string, 0, len(failed))
+		for node, reason := range failed {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", node, reason))
+		}
+		sort.Strings(msgs)
+		return fmt.Errorf("%s failed for %d out of %d nodes: %s", operation, len(failed), len(nodes), strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// runWithTimeout runs a function and waits for it to finish, at most for the
+// given time. The function is left running in the background if it times
+// out.
+func runWithTimeout(fn func() error, timeout time.Duration, c clock.Clock) (bool, error) {
+	if timeout <= 0 {
+		return false, fn()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-c.After(timeout):
+		return true, nil
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// nodeUpdate is the desired state of the NFD-managed properties of a node
+type nodeUpdate struct {
+	requester         string
+	owner             string
+	labels            Labels
+	annotations       Annotations
+	extendedResources ExtendedResources
+}
+
+// pendingUpdate is a node update waiting to be applied
+type pendingUpdate struct {
+	nodeUpdate
+	done chan struct{}
+	err  error
+}
+
+// updateKey identifies the features of one owner of a node
+type updateKey struct {
+	node  string
+	owner string
+}
+
+// updateQueue coalesces successive updates of a node. An update is delayed
+// for a short time window and any updates of the same node (and owner)
+// arriving within the window replace it, so that only the latest one gets
+// applied. Updates of one node are applied one at a time.
+type updateQueue struct {
+	sync.Mutex
+	clock   clock.Clock
+	window  time.Duration
+	apply   func(node string, u nodeUpdate) error
+	pending map[updateKey]*pendingUpdate
+	// Completion of the update of each node currently being applied
+	active map[string]chan struct{}
+}
+
+func newUpdateQueue(c clock.Clock, window time.Duration, apply func(string, nodeUpdate) error) *updateQueue {
+	return &updateQueue{
+		clock:   c,
+		window:  window,
+		apply:   apply,
+		pending: make(map[updateKey]*pendingUpdate),
+		active:  make(map[string]chan struct{}),
+	}
+}
+
+// update queues an update of a node and waits until it has been applied,
+// possibly coalesced with other updates of the node. The result of the
+// (coalesced) update is returned.
+func (q *updateQueue) update(node string, u nodeUpdate) error {
+	key := updateKey{node, u.owner}
+
+	q.Lock()
+	p, ok := q.pending[key]
+	if ok {
+		coalescedUpdates.Inc()
+	} else {
+		p = &pendingUpdate{done: make(chan struct{})}
+		q.pending[key] = p
+		go q.process(key, p)
+	}
+	p.nodeUpdate = u
+	q.Unlock()
+
+	<-p.done
+	return p.err
+}
+
+// process applies a pending update after the coalescing window
+func (q *updateQueue) process(key updateKey, p *pendingUpdate) {
+	<-q.clock.After(q.window)
+	node := key.node
+
+	q.Lock()
+	delete(q.pending, key)
+	prev := q.active[node]
+	q.active[node] = p.done
+	u := p.nodeUpdate
+	q.Unlock()
+
+	// Wait for the previous update of the node to finish
+	if prev != nil {
+		<-prev
+	}
+
+	p.err = q.apply(node, u)
+
+	q.Lock()
+	if q.active[node] == p.done {
+		delete(q.active, node)
+	}
+	q.Unlock()
+	close(p.done)
+}
@@ -0,0 +1,324 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// NFDConfig contains the configuration settings of nfd-master
+type NFDConfig struct {
+	ClientNamespaces    []ClientNamespaceRule `json:"clientNamespaces,omitempty"`
+	DeprecatedLabels    map[string]string     `json:"deprecatedLabels,omitempty"`
+	LabelOwners         []LabelOwnerRule      `json:"labelOwners,omitempty"`
+	LabelSchemas        []LabelSchema         `json:"labelSchemas,omitempty"`
+	LabelTransforms     LabelTransforms       `json:"labelTransforms,omitempty"`
+	LabelValueRules     []LabelValueRule      `json:"labelValueRules,omitempty"`
+	LabelWhiteList      []string              `json:"labelWhiteList,omitempty"`
+	LegacyLabelPrefixes []string              `json:"legacyLabelPrefixes"`
+	NamespaceWhiteLists map[string][]string   `json:"namespaceWhiteLists,omitempty"`
+	NodeNamePatterns    []string              `json:"nodeNamePatterns,omitempty"`
+
+	labelWhiteList      []*regexp.Regexp
+	namespaceWhiteLists map[string][]*regexp.Regexp
+	nodeNameRegexps     []*regexp.Regexp
+}
+
+// defaultLegacyLabelPrefixes are the prefixes of labels created by ancient
+// versions of NFD, removed from nodes unless configured otherwise
+var defaultLegacyLabelPrefixes = []string{
+	"node.alpha.kubernetes-incubator.io/nfd",
+	"node.alpha.kubernetes-incubator.io/node-feature-discovery",
+}
+
+// legacyLabelPrefixes returns the prefixes of legacy labels to be removed.
+// The built-in prefixes are used unless the setting is specified in the
+// configuration.
+func (c *NFDConfig) legacyLabelPrefixes() []string {
+	if c.LegacyLabelPrefixes == nil {
+		return defaultLegacyLabelPrefixes
+	}
+	return c.LegacyLabelPrefixes
+}
+
+// ClientNamespaceRule restricts label namespaces to clients whose TLS
+// certificate matches the rule. All given certificate attributes must match.
+type ClientNamespaceRule struct {
+	// OrganizationalUnit is matched against the OU attributes of the client
+	// certificate subject
+	OrganizationalUnit string `json:"organizationalUnit,omitempty"`
+	// URI is matched against the URI SANs of the client certificate
+	URI string `json:"uri,omitempty"`
+	// Namespaces that only the matching clients are allowed to publish to
+	Namespaces []string `json:"namespaces"`
+}
+
+// LabelOwnerRule assigns the features published by clients whose TLS
+// certificate matches the rule to a named owner. Features of different owners
+// are tracked separately so that updates from one owner never remove the
+// features of another. All given certificate attributes must match.
+type LabelOwnerRule struct {
+	// Name of the owner, used as a suffix of NFD-related annotations
+	Name string `json:"name"`
+	// OrganizationalUnit is matched against the OU attributes of the client
+	// certificate subject
+	OrganizationalUnit string `json:"organizationalUnit,omitempty"`
+	// URI is matched against the URI SANs of the client certificate
+	URI string `json:"uri,omitempty"`
+}
+
+// LabelSchema declares the allowed values of feature labels. All given
+// constraints must be satisfied.
+type LabelSchema struct {
+	// Label is a regular expression matched against the label name, after
+	// label transforms have been applied. An empty pattern matches all labels.
+	Label string `json:"label,omitempty"`
+	// Regexp that the value must match
+	Regexp string `json:"regexp,omitempty"`
+	// Enum is a list of allowed values
+	Enum []string `json:"enum,omitempty"`
+	// Integer requires the value to be an integer within the given range
+	Integer *IntegerRange `json:"integer,omitempty"`
+
+	labelRegexp *regexp.Regexp
+	valueRegexp *regexp.Regexp
+}
+
+// IntegerRange is an inclusive range of integers. Missing limits are not
+// checked.
+type IntegerRange struct {
+	Min *int64 `json:"min,omitempty"`
+	Max *int64 `json:"max,omitempty"`
+}
+
+// LabelTransforms describes renaming of incoming feature labels
+type LabelTransforms struct {
+	// KeyRewrites are applied in order on the full label name, as sent by
+	// nfd-worker
+	KeyRewrites []KeyRewrite `json:"keyRewrites,omitempty"`
+	// NamespaceRemap maps label namespaces to new namespaces. The default
+	// namespace is referred to by its name, i.e. feature.node.kubernetes.io
+	// unless changed with Args.LabelNs.
+	NamespaceRemap map[string]string `json:"namespaceRemap,omitempty"`
+}
+
+// KeyRewrite is a regexp based rewrite rule for label names
+type KeyRewrite struct {
+	Regexp      string `json:"regexp"`
+	Replacement string `json:"replacement"`
+
+	regexp *regexp.Regexp
+}
+
+// LabelValueRule describes a transformation applied to the value of matching
+// feature labels before they are published. The transformations are applied
+// in the order lowercase, regexp replacement and value mapping.
+type LabelValueRule struct {
+	// Label is a regular expression matched against the label name as sent
+	// by nfd-worker. An empty pattern matches all labels.
+	Label string `json:"label,omitempty"`
+	// Lowercase converts the value to lower case
+	Lowercase bool `json:"lowercase,omitempty"`
+	// Regexp is replaced by Replacement in the value
+	Regexp      string `json:"regexp,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	// Map is a table of exact value replacements
+	Map map[string]string `json:"map,omitempty"`
+
+	labelRegexp *regexp.Regexp
+	valueRegexp *regexp.Regexp
+}
+
+// loadConfig reads and parses the nfd-master configuration file. An empty
+// path results in the default (empty) configuration.
+func loadConfig(path string) (NFDConfig, error) {
+	c := NFDConfig{}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return c, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	if err := c.compile(); err != nil {
+		return c, fmt.Errorf("invalid config file %q: %v", path, err)
+	}
+
+	return c, nil
+}
+
+// compile pre-compiles all regular expressions of the configuration
+func (c *NFDConfig) compile() error {
+	c.nodeNameRegexps = make([]*regexp.Regexp, len(c.NodeNamePatterns))
+	for i, p := range c.NodeNamePatterns {
+		// The patterns are used for authorization, always require a full
+		// match of the identity
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern in nodeNamePatterns: %v", err)
+		}
+		if re.NumSubexp() == 0 {
+			return fmt.Errorf("pattern %q in nodeNamePatterns has no capture group for the node name", p)
+		}
+		c.nodeNameRegexps[i] = re
+	}
+	for _, r := range c.ClientNamespaces {
+		if r.OrganizationalUnit == "" && r.URI == "" {
+			return fmt.Errorf("clientNamespaces rule must specify organizationalUnit or uri")
+		}
+		if len(r.Namespaces) == 0 {
+			return fmt.Errorf("clientNamespaces rule must specify at least one namespace")
+		}
+	}
+	var err error
+	if c.labelWhiteList, err = compileRegexps(c.LabelWhiteList); err != nil {
+		return fmt.Errorf("invalid pattern in labelWhiteList: %v", err)
+	}
+	c.namespaceWhiteLists = make(map[string][]*regexp.Regexp, len(c.NamespaceWhiteLists))
+	for ns, patterns := range c.NamespaceWhiteLists {
+		if c.namespaceWhiteLists[ns], err = compileRegexps(patterns); err != nil {
+			return fmt.Errorf("invalid pattern in namespaceWhiteLists of %q: %v", ns, err)
+		}
+	}
+	owners := map[string]bool{}
+	for _, r := range c.LabelOwners {
+		if r.OrganizationalUnit == "" && r.URI == "" {
+			return fmt.Errorf("labelOwners rule must specify organizationalUnit or uri")
+		}
+		if errs := validation.IsDNS1123Label(r.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid owner name %q in labelOwners: %s", r.Name, strings.Join(errs, "; "))
+		}
+		if len(r.Name) > maxOwnerNameLength {
+			return fmt.Errorf("invalid owner name %q in labelOwners: must be no more than %d characters", r.Name, maxOwnerNameLength)
+		}
+		if owners[r.Name] {
+			return fmt.Errorf("duplicate owner name %q in labelOwners", r.Name)
+		}
+		owners[r.Name] = true
+	}
+	for i := range c.LabelTransforms.KeyRewrites {
+		r := &c.LabelTransforms.KeyRewrites[i]
+		var err error
+		if r.regexp, err = regexp.Compile(r.Regexp); err != nil {
+			return fmt.Errorf("invalid pattern in keyRewrites: %v", err)
+		}
+	}
+	for i := range c.LabelSchemas {
+		r := &c.LabelSchemas[i]
+		var err error
+		if r.labelRegexp, err = regexp.Compile(r.Label); err != nil {
+			return fmt.Errorf("invalid label pattern in labelSchemas: %v", err)
+		}
+		if r.Regexp != "" {
+			if r.valueRegexp, err = regexp.Compile(r.Regexp); err != nil {
+				return fmt.Errorf("invalid value pattern in labelSchemas: %v", err)
+			}
+		}
+	}
+	for i := range c.LabelValueRules {
+		r := &c.LabelValueRules[i]
+		var err error
+		if r.labelRegexp, err = regexp.Compile(r.Label); err != nil {
+			return fmt.Errorf("invalid label pattern in labelValueRules: %v", err)
+		}
+		if r.Regexp != "" {
+			if r.valueRegexp, err = regexp.Compile(r.Regexp); err != nil {
+				return fmt.Errorf("invalid value pattern in labelValueRules: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply applies the rule on a label value
+func (r *LabelValueRule) apply(value string) string {
+	if r.Lowercase {
+		value = strings.ToLower(value)
+	}
+	if r.valueRegexp != nil {
+		value = r.valueRegexp.ReplaceAllString(value, r.Replacement)
+	}
+	if v, ok := r.Map[value]; ok {
+		value = v
+	}
+	return value
+}
+
+// applyLabelValueRules rewrites label values according to the given rules.
+// Labels whose value becomes invalid are dropped.
+func applyLabelValueRules(labels Labels, rules []LabelValueRule) {
+	for name, value := range labels {
+		newValue := value
+		for i := range rules {
+			if rules[i].labelRegexp == nil || rules[i].labelRegexp.MatchString(name) {
+				newValue = rules[i].apply(newValue)
+			}
+		}
+		if newValue == value {
+			continue
+		}
+
+		if errs := validation.IsValidLabelValue(newValue); len(errs) > 0 {
+			stderrLogger.Printf("Ignoring label %s: invalid value %q after rewrite: %s", name, newValue, errs)
+			delete(labels, name)
+			continue
+		}
+		labels[name] = newValue
+	}
+}
+
+// applyDeprecatedLabels publishes deprecated label names alongside their
+// replacements. The deprecated map is keyed by the old label name and the
+// values are the new names. A warning is logged if the worker still generates
+// a deprecated label.
+func applyDeprecatedLabels(labels Labels, deprecated map[string]string) {
+	for oldName, newName := range deprecated {
+		if value, ok := labels[oldName]; ok {
+			stderrLogger.Printf("WARNING: deprecated label %q is still being generated, it is replaced by %q", oldName, newName)
+			if _, ok := labels[newName]; !ok {
+				labels[newName] = value
+			}
+		} else if value, ok := labels[newName]; ok {
+			labels[oldName] = value
+		}
+	}
+}
+
+// compileRegexps compiles a list of regular expressions
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	ret := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = re
+	}
+	return ret, nil
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+)
+
+// checkConsistency verifies that the features recorded in the NFD-related
+// annotations of all nodes of the cluster are present in the node objects.
+// Nothing is modified. An error listing the inconsistent nodes is returned.
+func (m *nfdMaster) checkConsistency() error {
+	cli, err := m.apihelper.GetClient()
+	if err != nil {
+		return err
+	}
+
+	nodes, err := m.apihelper.GetNodes(cli)
+	if err != nil {
+		return err
+	}
+
+	nodeNames := make([]string, len(nodes.Items))
+	for i, node := range nodes.Items {
+		nodeNames[i] = node.Name
+	}
+
+	return runBulk("consistency-check", nodeNames, m.bulkOptions(), m.clock, func(nodeName string) error {
+		node, err := m.apihelper.GetNode(cli, nodeName)
+		if err != nil {
+			return err
+		}
+		if issues := nodeInconsistencies(node, m.ns.annotation); len(issues) > 0 {
+			return fmt.Errorf("%s", strings.Join(issues, "; "))
+		}
+		if m.args.Verbosity >= 1 {
+			stdoutLogger.Printf("node %q is consistent", nodeName)
+		}
+		return nil
+	})
+}
+
+// nodeInconsistencies returns a description of the features that are listed
+// in the NFD-related annotations (in the given annotation namespace) of a
+// node but missing from the node object, e.g. removed by someone else.
+func nodeInconsistencies(n *api.Node, annotationNs string) []string {
+	var issues []string
+
+	for _, owner := range append([]string{""}, featureOwners(n, annotationNs)...) {
+		ns := recordedLabelNs(n, annotationNs, owner)
+		for _, l := range splitList(n.Annotations[annotationNs+ownerAnnotation("feature-labels", owner)]) {
+			if _, ok := n.Labels[addNs(l, ns)]; !ok {
+				issues = append(issues, fmt.Sprintf("label %q missing", addNs(l, ns)))
+			}
+		}
+		for _, a := range splitList(n.Annotations[annotationNs+ownerAnnotation("feature-annotations", owner)]) {
+			if _, ok := n.Annotations[a]; !ok {
+				issues = append(issues, fmt.Sprintf("annotation %q missing", a))
+			}
+		}
+	}
+
+	// Extended resources and taints are only managed for the default owner
+	for _, r := range splitList(n.Annotations[annotationNs+"extended-resources"]) {
+		name := addNs(r, recordedLabelNs(n, annotationNs, ""))
+		if _, ok := n.Status.Capacity[api.ResourceName(name)]; !ok {
+			issues = append(issues, fmt.Sprintf("extended resource %q missing", name))
+		}
+	}
+	if t, ok := n.Annotations[annotationNs+"taints"]; ok {
+		for _, taint := range parseTaints(t) {
+			if !containsTaint(n.Spec.Taints, taint, false) {
+				issues = append(issues, fmt.Sprintf("taint %q missing", taint.ToString()))
+			}
+		}
+	}
+
+	return issues
+}
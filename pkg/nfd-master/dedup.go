@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// updateCache tracks the content of the last successful update of each node.
+// It is used for suppressing duplicate node updates within a time window.
+type updateCache struct {
+	sync.Mutex
+	clock   clock.Clock
+	window  time.Duration
+	entries map[string]updateCacheEntry
+}
+
+type updateCacheEntry struct {
+	digest    [sha256.Size]byte
+	timestamp time.Time
+}
+
+func newUpdateCache(c clock.Clock, window time.Duration) *updateCache {
+	return &updateCache{clock: c, window: window, entries: make(map[string]updateCacheEntry)}
+}
+
+// updateDigest calculates a digest over the content of a node update
+func updateDigest(labels Labels, annotations Annotations, extendedResources ExtendedResources) [sha256.Size]byte {
+	// Map keys are sorted by encoding/json so the result is deterministic
+	data, _ := json.Marshal(struct {
+		L Labels
+		A Annotations
+		E ExtendedResources
+	}{labels, annotations, extendedResources})
+	return sha256.Sum256(data)
+}
+
+// isDuplicate returns true if an identical update for the node was
+// successfully done within the time window
+func (c *updateCache) isDuplicate(node string, labels Labels, annotations Annotations, extendedResources ExtendedResources) bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[node]
+	if !ok || c.clock.Since(e.timestamp) >= c.window {
+		return false
+	}
+	return e.digest == updateDigest(labels, annotations, extendedResources)
+}
+
+// store records a successful update of a node
+func (c *updateCache) store(node string, labels Labels, annotations Annotations, extendedResources ExtendedResources) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries[node] = updateCacheEntry{
+		digest:    updateDigest(labels, annotations, extendedResources),
+		timestamp: c.clock.Now(),
+	}
+}
+
+// forget drops the update recorded for a node
+func (c *updateCache) forget(node string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.entries, node)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Timeout of the API server connectivity check
+const apiServerCheckTimeout = 5 * time.Second
+
+// runHealthServer serves the /healthz (liveness) and /readyz (readiness)
+// endpoints. It only returns in case of an error.
+func (m *nfdMaster) runHealthServer(port int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.healthzHandler)
+	mux.HandleFunc("/readyz", m.readyzHandler)
+	stdoutLogger.Printf("health server serving on port: %d", port)
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
+
+// healthzHandler reports that the process is alive. It does not depend on the
+// state of the gRPC or API server so that a master still starting up, e.g.
+// syncing its node cache, is not restarted.
+func (m *nfdMaster) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyzHandler reports whether the gRPC server is serving and the API server
+// is reachable
+func (m *nfdMaster) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if err := m.checkServing(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if !m.args.NoPublish {
+		if err := m.checkAPIServer(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// checkServing returns an error if the gRPC server is not (yet or anymore)
+// serving
+func (m *nfdMaster) checkServing() error {
+	select {
+	case <-m.stop:
+		return fmt.Errorf("gRPC server is stopping")
+	default:
+	}
+	select {
+	case <-m.ready:
+	default:
+		return fmt.Errorf("gRPC server not ready")
+	}
+	return nil
+}
+
+// checkAPIServer checks the connectivity to the API server, with a timeout
+func (m *nfdMaster) checkAPIServer() error {
+	errChan := make(chan error, 1)
+	go func() { errChan <- m.apiServerCheck() }()
+
+	select {
+	case err := <-errChan:
+		if err != nil {
+			return fmt.Errorf("API server not reachable: %v", err)
+		}
+		return nil
+	case <-m.clock.After(apiServerCheckTimeout):
+		return fmt.Errorf("API server not reachable: timed out after %v", apiServerCheckTimeout)
+	}
+}
+
+// apiServerVersionCheck queries the version of the API server
+func (m *nfdMaster) apiServerVersionCheck() error {
+	cli, err := m.apihelper.GetClient()
+	if err != nil {
+		return err
+	}
+	_, err = cli.Discovery().ServerVersion()
+	return err
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"k8s.io/apimachinery/pkg/util/clock"
+	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
+)
+
+// Metadata key of the request ID
+const requestIDMetadataKey = "x-request-id"
+
+type requestIDContextKey struct{}
+
+// Request IDs consist of a per-process prefix and a counter
+var (
+	requestIDPrefix = strconv.FormatInt(time.Now().UnixNano(), 36)
+	requestCounter  uint64
+)
+
+// chainUnaryInterceptors creates one interceptor from many. The first
+// interceptor is the outermost one.
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// chainStreamInterceptors is the stream counterpart of chainUnaryInterceptors
+func chainStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], chained
+			chained = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return chained(srv, ss)
+	}
+}
+
+// newRequestID returns the ID of a request, taken from the request metadata
+// if the client provided one, otherwise a new one is generated
+func newRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDMetadataKey); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return fmt.Sprintf("%s-%d", requestIDPrefix, atomic.AddUint64(&requestCounter, 1))
+}
+
+// requestIDInterceptor attaches a request ID to the context of the request.
+// The ID is taken from the request metadata if the client provided one,
+// otherwise a new one is generated. The ID is also sent back to the client
+// in the response header.
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := newRequestID(ctx)
+
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadataKey, id)); err != nil {
+		stderrLogger.Printf("[%s] failed to set response header: %v", id, err)
+	}
+
+	return handler(context.WithValue(ctx, requestIDContextKey{}, id), req)
+}
+
+// requestIDStreamInterceptor is the stream counterpart of
+// requestIDInterceptor
+func requestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := newRequestID(ss.Context())
+
+	if err := ss.SetHeader(metadata.Pairs(requestIDMetadataKey, id)); err != nil {
+		stderrLogger.Printf("[%s] failed to set response header: %v", id, err)
+	}
+
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), requestIDContextKey{}, id)})
+}
+
+// contextServerStream is a server stream with a replaced context
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestID returns the ID of the request, or, an empty string if none has
+// been assigned
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// newLoggingInterceptor returns an interceptor that logs the peer address,
+// node name, duration and outcome of requests. Failed requests are always
+// logged. Successful requests are logged with verbosity 1 or higher, and,
+// the request content with verbosity 2 or higher.
+func newLoggingInterceptor(verbosity int, c clock.Clock) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		id := requestID(ctx)
+
+		addr := peerAddress(ctx)
+		node := requestNodeName(req)
+		if r, ok := req.(*pb.SetLabelsRequest); ok && verbosity >= 2 {
+			stdoutLogger.Printf("[%s] REQUEST Node: %s NFD-version: %s Labels: %s Annotations: %s", id, r.NodeName, r.NfdVersion, r.Labels, r.Annotations)
+		}
+
+		start := c.Now()
+		resp, err := handler(ctx, req)
+		duration := c.Since(start)
+
+		if err != nil {
+			stderrLogger.Printf("[%s] %s from %s (node %q) failed after %v: %v", id, info.FullMethod, addr, node, duration, err)
+		} else if verbosity >= 1 {
+			stdoutLogger.Printf("[%s] %s from %s (node %q) succeeded in %v", id, info.FullMethod, addr, node, duration)
+		}
+		return resp, err
+	}
+}
+
+// newLoggingStreamInterceptor is the stream counterpart of
+// newLoggingInterceptor. Streams are logged when they end.
+func newLoggingStreamInterceptor(verbosity int, c clock.Clock) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		id := requestID(ss.Context())
+		addr := peerAddress(ss.Context())
+		stream := &loggedServerStream{ServerStream: ss}
+
+		start := c.Now()
+		err := handler(srv, stream)
+		duration := c.Since(start)
+
+		if err != nil {
+			stderrLogger.Printf("[%s] %s from %s (node %q) failed after %v: %v", id, info.FullMethod, addr, stream.node, duration, err)
+		} else if verbosity >= 1 {
+			stdoutLogger.Printf("[%s] %s from %s (node %q) ended after %v", id, info.FullMethod, addr, stream.node, duration)
+		}
+		return err
+	}
+}
+
+// loggedServerStream is a server stream recording the node name of the
+// received request
+type loggedServerStream struct {
+	grpc.ServerStream
+	node string
+}
+
+func (s *loggedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.node = requestNodeName(m)
+	}
+	return err
+}
+
+// peerAddress returns the address of the client of a request
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
+
+// requestNodeName returns the name of the node a request is about, or, an
+// empty string if not known
+func requestNodeName(req interface{}) string {
+	switch r := req.(type) {
+	case *pb.SetLabelsRequest:
+		return r.NodeName
+	case *pb.GetLabelsRequest:
+		return r.NodeName
+	case *pb.WatchFeaturesRequest:
+		return r.NodeName
+	}
+	return ""
+}
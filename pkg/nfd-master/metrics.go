@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Prometheus metrics exported by nfd-master
+var (
+	nodeUpdateFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nfd_master_node_update_failures_total",
+		Help: "Number of failed node object updates, partitioned by reason (conflict or error).",
+	}, []string{"reason"})
+	extendedResourcePatchFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_extended_resource_patch_failures_total",
+		Help: "Number of failed node status patches for updating extended resources.",
+	})
+	labelsAdded = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_labels_added_total",
+		Help: "Number of feature labels added to nodes.",
+	})
+	labelsRemoved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_labels_removed_total",
+		Help: "Number of feature labels removed from nodes.",
+	})
+	labelsChanged = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_labels_changed_total",
+		Help: "Number of feature labels whose value was changed.",
+	})
+	deferredResourceRemovals = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_deferred_extended_resource_removals_total",
+		Help: "Number of times the removal of an extended resource was deferred because it was still in use.",
+	})
+	bulkOperationNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfd_master_bulk_operation_nodes",
+		Help: "Number of nodes targeted by the latest bulk operation (e.g. prune).",
+	}, []string{"operation"})
+	bulkOperationNodesProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "nfd_master_bulk_operation_nodes_processed_total",
+		Help: "Number of nodes processed by bulk operations, partitioned by result (success, failure, timeout or skipped).",
+	}, []string{"operation", "result"})
+	coalescedUpdates = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nfd_master_coalesced_updates_total",
+		Help: "Number of node updates coalesced with a later update of the same node.",
+	})
+	nodeLastSuccessfulUpdate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nfd_master_node_last_successful_update_timestamp_seconds",
+		Help: "Unix timestamp of the last successful update of the node object.",
+	}, []string{"node"})
+)
+
+func init() {
+	prometheus.MustRegister(nodeUpdateFailures,
+		extendedResourcePatchFailures,
+		labelsAdded,
+		labelsRemoved,
+		labelsChanged,
+		deferredResourceRemovals,
+		bulkOperationNodes,
+		bulkOperationNodesProcessed,
+		coalescedUpdates,
+		nodeLastSuccessfulUpdate)
+}
+
+// updateFailureReason returns the reason used in the node update failure
+// metric for an error
+func updateFailureReason(err error) string {
+	if errors.IsConflict(err) {
+		return "conflict"
+	}
+	return "error"
+}
+
+// forgetNodeMetrics drops the per-node metrics of a node that no longer
+// exists or is no longer managed by nfd-master
+func forgetNodeMetrics(nodeName string) {
+	nodeLastSuccessfulUpdate.DeleteLabelValues(nodeName)
+}
+
+// runMetricsServer serves the Prometheus metrics endpoint. It only returns
+// in case of an error.
+func runMetricsServer(port int) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	stdoutLogger.Printf("metrics server serving on port: %d", port)
+	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+}
+
+// runPprofServer serves the net/http/pprof profiling endpoints on localhost.
+// It only returns in case of an error.
+func runPprofServer(port int) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	addr := fmt.Sprintf("localhost:%d", port)
+	stdoutLogger.Printf("pprof server serving on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
@@ -17,19 +17,35 @@ limitations under the License.
 package nfdmaster
 
 import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
 	"github.com/vektra/errors"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 	api "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	k8sclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"sigs.k8s.io/node-feature-discovery/pkg/apihelper"
 	"sigs.k8s.io/node-feature-discovery/pkg/labeler"
 	"sigs.k8s.io/node-feature-discovery/pkg/version"
@@ -37,8 +53,12 @@ import (
 
 const (
 	mockNodeName = "mock-node"
+	mockNodeUID  = "mock-node-uid"
 )
 
+// defaultNs are the default label and annotation namespaces
+var defaultNs = namespaces{label: LabelNs, annotation: AnnotationNs}
+
 func init() {
 	nodeName = mockNodeName
 }
@@ -46,6 +66,7 @@ func init() {
 func newMockNode() *api.Node {
 	n := api.Node{}
 	n.Name = mockNodeName
+	n.UID = mockNodeUID
 	n.Labels = map[string]string{}
 	n.Annotations = map[string]string{}
 	n.Status.Capacity = api.ResourceList{}
@@ -65,6 +86,7 @@ func TestUpdateNodeFeatures(t *testing.T) {
 		fakeAnnotations["feature-labels"] = strings.Join(fakeFeatureLabelNames, ",")
 
 		mockAPIHelper := new(apihelper.MockAPIHelpers)
+		mockServer := &labelerServer{ns: defaultNs, apiHelper: mockAPIHelper}
 		mockClient := &k8sclient.Clientset{}
 		// Mock node with old features
 		mockNode := newMockNode()
@@ -76,7 +98,7 @@ func TestUpdateNodeFeatures(t *testing.T) {
 			mockAPIHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil).Once()
 			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(nil).Once()
 			mockAPIHelper.On("PatchStatus", mockClient, mockNodeName, mock.Anything).Return(nil).Twice()
-			err := updateNodeFeatures(mockAPIHelper, mockNodeName, fakeFeatureLabels, fakeAnnotations, fakeExtResources)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", fakeFeatureLabels, fakeAnnotations, fakeExtResources)
 
 			Convey("Error is nil", func() {
 				So(err, ShouldBeNil)
@@ -86,17 +108,18 @@ func TestUpdateNodeFeatures(t *testing.T) {
 				for k, v := range fakeFeatureLabels {
 					So(mockNode.Labels[LabelNs+k], ShouldEqual, v)
 				}
-				So(len(mockNode.Annotations), ShouldEqual, len(fakeAnnotations))
+				So(len(mockNode.Annotations), ShouldEqual, len(fakeAnnotations)+1)
 				for k, v := range fakeAnnotations {
 					So(mockNode.Annotations[AnnotationNs+k], ShouldEqual, v)
 				}
+				So(mockNode.Annotations[AnnotationNs+"node-uid"], ShouldEqual, mockNodeUID)
 			})
 		})
 
 		Convey("When I fail to update the node with feature labels", func() {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(nil, expectedError)
-			err := updateNodeFeatures(mockAPIHelper, mockNodeName, fakeFeatureLabels, fakeAnnotations, fakeExtResources)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", fakeFeatureLabels, fakeAnnotations, fakeExtResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -106,7 +129,7 @@ func TestUpdateNodeFeatures(t *testing.T) {
 		Convey("When I fail to get a mock client while updating feature labels", func() {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(nil, expectedError)
-			err := updateNodeFeatures(mockAPIHelper, mockNodeName, fakeFeatureLabels, fakeAnnotations, fakeExtResources)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", fakeFeatureLabels, fakeAnnotations, fakeExtResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -117,7 +140,7 @@ func TestUpdateNodeFeatures(t *testing.T) {
 			expectedError := errors.New("fake error")
 			mockAPIHelper.On("GetClient").Return(mockClient, nil)
 			mockAPIHelper.On("GetNode", mockClient, mockNodeName).Return(nil, expectedError).Once()
-			err := updateNodeFeatures(mockAPIHelper, mockNodeName, fakeFeatureLabels, fakeAnnotations, fakeExtResources)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", fakeFeatureLabels, fakeAnnotations, fakeExtResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -126,16 +149,86 @@ func TestUpdateNodeFeatures(t *testing.T) {
 
 		Convey("When I fail to update a mock node while updating feature labels", func() {
 			expectedError := errors.New("fake error")
+			mockServer.published = newPublishedFeatures()
 			mockAPIHelper.On("GetClient").Return(mockClient, nil)
 			mockAPIHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil).Once()
 			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(expectedError).Once()
-			err := updateNodeFeatures(mockAPIHelper, mockNodeName, fakeFeatureLabels, fakeAnnotations, fakeExtResources)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", fakeFeatureLabels, fakeAnnotations, fakeExtResources)
 
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
 			})
+			Convey("The features should not be recorded as published", func() {
+				So(mockServer.published.nodes, ShouldNotContainKey, mockNodeName)
+			})
+		})
+
+		Convey("When the node has been re-created", func() {
+			mockNode.Labels["vendor.com/feature-1"] = "true"
+			mockNode.Annotations[AnnotationNs+"feature-labels.vendor-a"] = "vendor.com/feature-1"
+			mockNode.Annotations[AnnotationNs+"node-uid"] = "old-uid"
+			mockAPIHelper.On("GetClient").Return(mockClient, nil)
+			mockAPIHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil).Once()
+			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(nil).Once()
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", Labels{"feature-1": "true"}, Annotations{"feature-labels": "feature-1"}, ExtendedResources{})
+
+			Convey("Stale features of all owners should be removed", func() {
+				So(err, ShouldBeNil)
+				So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-1": "true"})
+				So(mockNode.Annotations, ShouldResemble, map[string]string{AnnotationNs + "feature-labels": "feature-1", AnnotationNs + "node-uid": mockNodeUID})
+			})
 		})
 
+		Convey("When the node has been re-created from scratch", func() {
+			mockServer.args.StateNamespace = "nfd"
+			mockServer.published = newPublishedFeatures()
+			mockServer.published.store(mockNodeName, "old-uid", &labeler.GetLabelsReply{})
+			mockServer.updateCache = newUpdateCache(clock.NewFakeClock(time.Now()), time.Minute)
+			mockServer.updateCache.store(mockNodeName, Labels{}, Annotations{}, ExtendedResources{})
+			mockAPIHelper.On("GetClient").Return(mockClient, nil)
+			mockAPIHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil).Once()
+			mockAPIHelper.On("DeleteConfigMap", mockClient, "nfd", nodeStateConfigMapName(mockNodeName)).Return(nil).Once()
+			mockAPIHelper.On("UpdateNode", mockClient, mockNode).Return(nil).Once()
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", Labels{"feature-1": "true"}, Annotations{"feature-labels": "feature-1"}, ExtendedResources{})
+
+			Convey("The stale state kept for the node should be dropped", func() {
+				So(err, ShouldBeNil)
+				mockAPIHelper.AssertCalled(t, "DeleteConfigMap", mockClient, "nfd", nodeStateConfigMapName(mockNodeName))
+				So(mockServer.updateCache.entries, ShouldNotContainKey, mockNodeName)
+				uid, _ := mockServer.published.uid(mockNodeName)
+				So(string(uid), ShouldEqual, mockNodeUID)
+			})
+		})
+	})
+}
+
+func TestListenNetwork(t *testing.T) {
+	Convey("When resolving the network to listen on", t, func() {
+		Convey("Valid address families should be accepted", func() {
+			for family, expected := range map[string]string{"": "tcp", "dual": "tcp", "ipv4": "tcp4", "ipv6": "tcp6"} {
+				network, err := listenNetwork(family, "")
+				So(err, ShouldBeNil)
+				So(network, ShouldEqual, expected)
+			}
+		})
+		Convey("Addresses matching the family should be accepted", func() {
+			_, err := listenNetwork("dual", "::")
+			So(err, ShouldBeNil)
+			_, err = listenNetwork("ipv4", "127.0.0.1")
+			So(err, ShouldBeNil)
+			_, err = listenNetwork("ipv6", "fd00::1")
+			So(err, ShouldBeNil)
+		})
+		Convey("Invalid families and addresses should be rejected", func() {
+			_, err := listenNetwork("ipv5", "")
+			So(err, ShouldNotBeNil)
+			_, err = listenNetwork("dual", "localhost")
+			So(err, ShouldNotBeNil)
+			_, err = listenNetwork("ipv4", "::1")
+			So(err, ShouldNotBeNil)
+			_, err = listenNetwork("ipv6", "127.0.0.1")
+			So(err, ShouldNotBeNil)
+		})
 	})
 }
 
@@ -148,16 +241,28 @@ func TestUpdateMasterNode(t *testing.T) {
 			mockHelper.On("GetClient").Return(mockClient, nil)
 			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
 			mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
-			err := updateMasterNode(mockHelper)
+			err := updateMasterNode(mockHelper, AnnotationNs)
 			Convey("No error should be returned", func() {
 				So(err, ShouldBeNil)
 			})
 		})
 
+		Convey("When annotations are already up-to-date", func() {
+			mockNode.Annotations[AnnotationNs+"master.version"] = version.Get()
+			mockNode.Annotations[AnnotationNs+"master.instance"] = instanceName
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+			err := updateMasterNode(mockHelper, AnnotationNs)
+			// UpdateNode is not mocked, i.e. calling it would fail the test
+			Convey("Node object should not be updated", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
 		mockErr := errors.New("mock-error")
 		Convey("When getting API client fails", func() {
 			mockHelper.On("GetClient").Return(mockClient, mockErr)
-			err := updateMasterNode(mockHelper)
+			err := updateMasterNode(mockHelper, AnnotationNs)
 			Convey("An error should be returned", func() {
 				So(err, ShouldEqual, mockErr)
 			})
@@ -166,7 +271,7 @@ func TestUpdateMasterNode(t *testing.T) {
 		Convey("When getting API node object fails", func() {
 			mockHelper.On("GetClient").Return(mockClient, nil)
 			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, mockErr)
-			err := updateMasterNode(mockHelper)
+			err := updateMasterNode(mockHelper, AnnotationNs)
 			Convey("An error should be returned", func() {
 				So(err, ShouldEqual, mockErr)
 			})
@@ -176,7 +281,7 @@ func TestUpdateMasterNode(t *testing.T) {
 			mockHelper.On("GetClient").Return(mockClient, nil)
 			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
 			mockHelper.On("UpdateNode", mockClient, mockNode).Return(mockErr)
-			err := updateMasterNode(mockHelper)
+			err := updateMasterNode(mockHelper, AnnotationNs)
 			Convey("An error should be returned", func() {
 				So(err, ShouldEqual, mockErr)
 			})
@@ -184,19 +289,81 @@ func TestUpdateMasterNode(t *testing.T) {
 	})
 }
 
+func TestResyncMasterNode(t *testing.T) {
+	Convey("When resyncing the nfd-master node", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		mockNode := newMockNode()
+		mockHelper.On("GetClient").Return(mockClient, nil)
+		mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+		mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
+
+		fakeClock := clock.NewFakeClock(time.Now())
+		m := &nfdMaster{args: Args{ResyncInterval: time.Minute}, ns: defaultNs, apihelper: mockHelper, clock: fakeClock, stop: make(chan struct{})}
+		done := make(chan struct{})
+		go func() {
+			m.resyncMasterNode()
+			close(done)
+		}()
+
+		Convey("Annotations should be re-applied after the resync interval", func() {
+			for !fakeClock.HasWaiters() {
+				time.Sleep(time.Millisecond)
+			}
+			So(mockNode.Annotations, ShouldNotContainKey, AnnotationNs+"master.instance")
+			fakeClock.Step(time.Minute)
+			// Wait for the next round
+			for !fakeClock.HasWaiters() {
+				time.Sleep(time.Millisecond)
+			}
+			So(mockNode.Annotations[AnnotationNs+"master.instance"], ShouldEqual, instanceName)
+			close(m.stop)
+			<-done
+		})
+
+		Convey("Resync should stop when the master is stopped", func() {
+			close(m.stop)
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Error("resync did not stop")
+			}
+		})
+	})
+}
+
+func TestCheckPodListAccess(t *testing.T) {
+	Convey("When checking the permission to list pods", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		mockHelper.On("GetClient").Return(mockClient, nil)
+
+		Convey("No error should be returned if pods can be listed", func() {
+			mockHelper.On("GetPods", mockClient, nodeName).Return(&api.PodList{}, nil)
+			So(checkPodListAccess(mockHelper), ShouldBeNil)
+		})
+
+		Convey("An error should be returned if listing pods is forbidden", func() {
+			forbidden := k8serrors.NewForbidden(api.Resource("pods"), "", errors.New("denied"))
+			mockHelper.On("GetPods", mockClient, nodeName).Return(nil, forbidden)
+			So(checkPodListAccess(mockHelper), ShouldNotBeNil)
+		})
+	})
+}
+
 func TestAddingExtResources(t *testing.T) {
 	Convey("When adding extended resources", t, func() {
 		Convey("When there are no matching labels", func() {
 			mockNode := newMockNode()
 			mockResourceLabels := ExtendedResources{}
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldEqual, 0)
 		})
 
 		Convey("When there are matching labels", func() {
 			mockNode := newMockNode()
 			mockResourceLabels := ExtendedResources{"feature-1": "1", "feature-2": "2"}
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldBeGreaterThan, 0)
 		})
 
@@ -204,7 +371,7 @@ func TestAddingExtResources(t *testing.T) {
 			mockNode := newMockNode()
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-1")] = *resource.NewQuantity(1, resource.BinarySI)
 			mockResourceLabels := ExtendedResources{"feature-1": "1"}
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldEqual, 0)
 		})
 
@@ -212,9 +379,261 @@ func TestAddingExtResources(t *testing.T) {
 			mockNode := newMockNode()
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-1")] = *resource.NewQuantity(2, resource.BinarySI)
 			mockResourceLabels := ExtendedResources{"feature-1": "1"}
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldBeGreaterThan, 0)
 		})
+
+		Convey("When the resource is in a vendor namespace", func() {
+			mockNode := newMockNode()
+			mockResourceLabels := ExtendedResources{"vendor.com/feature-1": "1"}
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
+			So(resourceOps, ShouldResemble, []statusOp{{"add", "/status/capacity/vendor.com~1feature-1", "1"}})
+		})
+	})
+}
+
+func TestFilterExtendedResources(t *testing.T) {
+	Convey("When filtering feature labels into extended resources", t, func() {
+		labels := Labels{
+			"feature-1":               "1",
+			"vendor.com/feature-2":    "2",
+			"other.com/feature-3":     "3",
+			"kubernetes.io/feature-4": "4",
+		}
+		resourceNames := []string{"feature-1", "vendor.com/feature-2", "other.com/feature-3", "kubernetes.io/feature-4"}
+		labels, extendedResources := filterFeatureLabels(labels, LabelNs, []string{"vendor.com", "kubernetes.io"}, newWhiteList(), resourceNames, nil)
+
+		Convey("Resources in allowed namespaces should be advertised", func() {
+			So(extendedResources, ShouldResemble, ExtendedResources{"feature-1": "1", "vendor.com/feature-2": "2"})
+		})
+		Convey("Resources in reserved namespaces should not be advertised", func() {
+			So(labels, ShouldResemble, Labels{"kubernetes.io/feature-4": "4"})
+		})
+	})
+
+	Convey("When validating extended resource names", t, func() {
+		So(validateExtendedResourceName("feature-1", LabelNs), ShouldBeNil)
+		So(validateExtendedResourceName(LabelNs+"feature-1", LabelNs), ShouldBeNil)
+		So(validateExtendedResourceName("vendor.com/feature-1", LabelNs), ShouldBeNil)
+		So(validateExtendedResourceName("kubernetes.io/feature-1", LabelNs), ShouldNotBeNil)
+		So(validateExtendedResourceName("foo.k8s.io/feature-1", LabelNs), ShouldNotBeNil)
+		So(validateExtendedResourceName("vendor.com/-feature", LabelNs), ShouldNotBeNil)
+	})
+}
+
+func TestWhiteList(t *testing.T) {
+	Convey("When whitelisting labels with multiple patterns", t, func() {
+		c := NFDConfig{
+			LabelWhiteList:      []string{"^cpu-", "^kernel-"},
+			NamespaceWhiteLists: map[string][]string{"vendor.com": {"^gpu-"}},
+		}
+		So(c.compile(), ShouldBeNil)
+		defaultNs := strings.TrimSuffix(LabelNs, "/")
+
+		Convey("Names matching any of the patterns should be accepted", func() {
+			wl := c.whiteList(regexp.MustCompile(""))
+			So(wl.matches(defaultNs, "cpu-model"), ShouldBeTrue)
+			So(wl.matches(defaultNs, "kernel-version"), ShouldBeTrue)
+			So(wl.matches(defaultNs, "pci-present"), ShouldBeFalse)
+		})
+
+		Convey("Pattern given on the command line should be combined with the config", func() {
+			wl := c.whiteList(regexp.MustCompile("^pci-"))
+			So(wl.matches(defaultNs, "cpu-model"), ShouldBeTrue)
+			So(wl.matches(defaultNs, "pci-present"), ShouldBeTrue)
+			So(wl.matches(defaultNs, "usb-present"), ShouldBeFalse)
+		})
+
+		Convey("Namespace specific patterns should replace the global ones", func() {
+			wl := c.whiteList(regexp.MustCompile(""))
+			So(wl.matches("vendor.com", "gpu-present"), ShouldBeTrue)
+			So(wl.matches("vendor.com", "cpu-model"), ShouldBeFalse)
+			So(wl.matches("other.com", "cpu-model"), ShouldBeTrue)
+		})
+
+		Convey("Empty whitelist should accept everything", func() {
+			So(newWhiteList(regexp.MustCompile("")).matches(defaultNs, "foo"), ShouldBeTrue)
+		})
+	})
+
+	Convey("When loading a config with invalid whitelist patterns", t, func() {
+		c := NFDConfig{LabelWhiteList: []string{"^(cpu-"}}
+		So(c.compile(), ShouldNotBeNil)
+		c = NFDConfig{NamespaceWhiteLists: map[string][]string{"vendor.com": {"^(gpu-"}}}
+		So(c.compile(), ShouldNotBeNil)
+	})
+}
+
+func TestFilterFeatureAnnotations(t *testing.T) {
+	Convey("When filtering feature annotations", t, func() {
+		annotations := map[string]string{
+			"feature-1":                        "value 1",
+			"vendor.com/feature-2":             "value 2",
+			"other.com/feature-3":              "value 3",
+			"nfd.node.kubernetes.io/feature-4": "value 4",
+			"invalid/name/feature-5":           "value 5",
+		}
+		extraNs := []string{"vendor.com", "nfd.node.kubernetes.io"}
+
+		Convey("Annotations in allowed namespaces should be published", func() {
+			filtered := filterFeatureAnnotations(annotations, defaultNs, extraNs, newWhiteList(), nil)
+			So(filtered, ShouldResemble, Annotations{
+				LabelNs + "feature-1":  "value 1",
+				"vendor.com/feature-2": "value 2",
+			})
+		})
+
+		Convey("Annotations not matching the whitelist should be dropped", func() {
+			filtered := filterFeatureAnnotations(annotations, defaultNs, extraNs, newWhiteList(regexp.MustCompile("feature-2")), nil)
+			So(filtered, ShouldResemble, Annotations{"vendor.com/feature-2": "value 2"})
+		})
+
+		Convey("Annotations exceeding the size limit should be dropped", func() {
+			annotations := map[string]string{
+				"feature-1": strings.Repeat("a", maxFeatureAnnotationsSize/2),
+				"feature-2": strings.Repeat("b", maxFeatureAnnotationsSize/2),
+			}
+			filtered := filterFeatureAnnotations(annotations, defaultNs, nil, newWhiteList(), nil)
+			So(filtered, ShouldContainKey, LabelNs+"feature-1")
+			So(filtered, ShouldNotContainKey, LabelNs+"feature-2")
+		})
+	})
+
+	Convey("When adding annotations to a node", t, func() {
+		mockNode := newMockNode()
+		addAnnotations(mockNode, map[string]string{"feature-annotations": "vendor.com/feature-1", "vendor.com/feature-1": "foo"}, AnnotationNs)
+		So(mockNode.Annotations[AnnotationNs+"feature-annotations"], ShouldEqual, "vendor.com/feature-1")
+		So(mockNode.Annotations["vendor.com/feature-1"], ShouldEqual, "foo")
+	})
+}
+
+func TestFilterDeclaredExtendedResources(t *testing.T) {
+	Convey("When filtering extended resources declared by nfd-worker", t, func() {
+		resources := map[string]string{
+			"feature-1":               "1",
+			LabelNs + "feature-2":     "2",
+			"vendor.com/feature-3":    "3",
+			"other.com/feature-4":     "4",
+			"feature-5":               "not-a-number",
+			"kubernetes.io/feature-6": "6",
+		}
+		extraNs := []string{"vendor.com", "kubernetes.io"}
+
+		Convey("Valid resources in allowed namespaces should be published", func() {
+			rej := newRejections("node")
+			filtered := filterExtendedResources(resources, LabelNs, extraNs, newWhiteList(), rej)
+			So(filtered, ShouldResemble, ExtendedResources{
+				"feature-1":            "1",
+				"feature-2":            "2",
+				"vendor.com/feature-3": "3",
+			})
+			So(len(rej.list()), ShouldEqual, 3)
+		})
+
+		Convey("Resources not matching the whitelist should be dropped", func() {
+			filtered := filterExtendedResources(resources, LabelNs, extraNs, newWhiteList(regexp.MustCompile("feature-3")), newRejections("node"))
+			So(filtered, ShouldResemble, ExtendedResources{"vendor.com/feature-3": "3"})
+		})
+	})
+}
+
+func TestFilterTaints(t *testing.T) {
+	Convey("When filtering requested taints", t, func() {
+		taints := []*labeler.Taint{
+			{Key: "feature-1", Value: "true", Effect: "NoSchedule"},
+			{Key: "vendor.com/feature-2", Effect: "NoExecute"},
+			{Key: "other.com/feature-3", Value: "true", Effect: "NoSchedule"},
+			{Key: "feature-4", Value: "true", Effect: "NoFoo"},
+			{Key: "feature-5", Value: "not a label value", Effect: "NoSchedule"},
+			{Key: "denied.com/feature-6", Value: "true", Effect: "NoSchedule"},
+		}
+		rej := newRejections("node")
+		filtered := filterTaints(taints, LabelNs, []string{"vendor.com", "denied.com"}, map[string]bool{"denied.com": true}, rej)
+
+		Convey("Valid taints in allowed namespaces should be applied", func() {
+			So(filtered, ShouldResemble, []api.Taint{
+				{Key: LabelNs + "feature-1", Value: "true", Effect: api.TaintEffectNoSchedule},
+				{Key: "vendor.com/feature-2", Effect: api.TaintEffectNoExecute},
+			})
+			So(len(rej.list()), ShouldEqual, 4)
+		})
+		Convey("Taints should survive a round-trip through the annotation", func() {
+			So(parseTaints(formatTaints(filtered)), ShouldResemble, filtered)
+		})
+	})
+}
+
+func TestUpdateTaints(t *testing.T) {
+	Convey("When updating the taints of a node", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		mockServer := &labelerServer{ns: defaultNs, apiHelper: mockHelper}
+		mockNode := newMockNode()
+		mockNode.Spec.Taints = []api.Taint{
+			{Key: "other", Effect: api.TaintEffectNoSchedule},
+			{Key: LabelNs + "feature-1", Value: "old", Effect: api.TaintEffectNoSchedule},
+			{Key: LabelNs + "feature-2", Effect: api.TaintEffectNoExecute},
+		}
+		mockNode.Annotations[AnnotationNs+"taints"] = LabelNs + "feature-1=old:NoSchedule," + LabelNs + "feature-2:NoExecute"
+
+		mockHelper.On("GetClient").Return(mockClient, nil)
+		mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+		mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
+
+		Convey("Taints no longer requested should be removed and others left untouched", func() {
+			annotations := Annotations{"taints": LabelNs + "feature-1=new:NoSchedule"}
+			changes, err := mockServer.updateNodeFeatures(mockNodeName, "", Labels{}, annotations, ExtendedResources{})
+			So(err, ShouldBeNil)
+			So(mockNode.Spec.Taints, ShouldResemble, []api.Taint{
+				{Key: "other", Effect: api.TaintEffectNoSchedule},
+				{Key: LabelNs + "feature-1", Value: "new", Effect: api.TaintEffectNoSchedule},
+			})
+			So(mockNode.Annotations[AnnotationNs+"taints"], ShouldEqual, LabelNs+"feature-1=new:NoSchedule")
+			So(changes.taints.added, ShouldResemble, []string{LabelNs + "feature-1=new:NoSchedule"})
+			So(changes.taints.removed, ShouldResemble, []string{LabelNs + "feature-1=old:NoSchedule", LabelNs + "feature-2:NoExecute"})
+		})
+
+		Convey("Taints should not be touched for other owners", func() {
+			changes, err := mockServer.updateNodeFeatures(mockNodeName, "owner", Labels{}, Annotations{}, ExtendedResources{})
+			So(err, ShouldBeNil)
+			So(len(mockNode.Spec.Taints), ShouldEqual, 3)
+			So(changes.taints.empty(), ShouldBeTrue)
+		})
+	})
+}
+
+func TestRemoveFeatures(t *testing.T) {
+	Convey("When removing feature labels of a node", t, func() {
+		Convey("Labels should be removed from the recorded label namespace", func() {
+			mockNode := newMockNode()
+			mockNode.Labels["feature.example.com/feature-1"] = "true"
+			mockNode.Labels[LabelNs+"feature-1"] = "true"
+			mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+			mockNode.Annotations[AnnotationNs+"label-ns"] = "feature.example.com/"
+
+			removeFeatures(mockNode, AnnotationNs, "")
+			So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-1": "true"})
+			So(mockNode.Annotations, ShouldNotContainKey, AnnotationNs+"label-ns")
+		})
+
+		Convey("Labels and annotations should be migrated from the default annotation namespace", func() {
+			mockHelper := &apihelper.MockAPIHelpers{}
+			mockClient := &k8sclient.Clientset{}
+			mockServer := &labelerServer{ns: namespaces{label: LabelNs, annotation: "nfd.example.com/"}, apiHelper: mockHelper}
+			mockNode := newMockNode()
+			mockNode.Labels[LabelNs+"feature-1"] = "true"
+			mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+			mockNode.Annotations[AnnotationNs+"worker.version"] = "v0.1"
+
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+			mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "", Labels{"feature-2": "true"}, Annotations{"feature-labels": "feature-2"}, ExtendedResources{})
+
+			So(err, ShouldBeNil)
+			So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-2": "true"})
+			So(mockNode.Annotations, ShouldResemble, map[string]string{"nfd.example.com/feature-labels": "feature-2", "nfd.example.com/node-uid": mockNodeUID})
+		})
 	})
 }
 
@@ -226,7 +645,7 @@ func TestRemovingExtResources(t *testing.T) {
 			mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-1,feature-2"
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-1")] = *resource.NewQuantity(1, resource.BinarySI)
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-2")] = *resource.NewQuantity(2, resource.BinarySI)
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldEqual, 0)
 		})
 		Convey("When the related label is gone", func() {
@@ -235,7 +654,7 @@ func TestRemovingExtResources(t *testing.T) {
 			mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-4,feature-2"
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-4")] = *resource.NewQuantity(4, resource.BinarySI)
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-2")] = *resource.NewQuantity(2, resource.BinarySI)
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldBeGreaterThan, 0)
 		})
 		Convey("When the extended resource is no longer wanted", func() {
@@ -244,7 +663,7 @@ func TestRemovingExtResources(t *testing.T) {
 			mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-2")] = *resource.NewQuantity(2, resource.BinarySI)
 			mockResourceLabels := ExtendedResources{"feature-2": "2"}
 			mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-1,feature-2"
-			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels)
+			resourceOps := getExtendedResourceOps(mockNode, mockResourceLabels, defaultNs)
 			So(len(resourceOps), ShouldBeGreaterThan, 0)
 		})
 	})
@@ -257,7 +676,7 @@ func TestSetLabels(t *testing.T) {
 		mockHelper := &apihelper.MockAPIHelpers{}
 		mockClient := &k8sclient.Clientset{}
 		mockNode := newMockNode()
-		mockServer := labelerServer{args: Args{LabelWhiteList: regexp.MustCompile("")}, apiHelper: mockHelper}
+		mockServer := labelerServer{args: Args{LabelWhiteList: regexp.MustCompile("")}, ns: defaultNs, apiHelper: mockHelper}
 		mockCtx := context.Background()
 		mockLabels := map[string]string{"feature-1": "val-1", "feature-2": "val-2", "feature-3": "val-3"}
 		mockReq := &labeler.SetLabelsRequest{NodeName: workerName, NfdVersion: workerVer, Labels: mockLabels}
@@ -270,6 +689,7 @@ func TestSetLabels(t *testing.T) {
 		expectedAnnotations := map[string]string{"worker.version": workerVer}
 		expectedAnnotations["feature-labels"] = strings.Join(mockLabelNames, ",")
 		expectedAnnotations["extended-resources"] = ""
+		expectedAnnotations["node-uid"] = mockNodeUID
 
 		Convey("When node update succeeds", func() {
 			mockHelper.On("GetClient").Return(mockClient, nil)
@@ -291,20 +711,50 @@ func TestSetLabels(t *testing.T) {
 			})
 		})
 
+		Convey("When --dedup-window is specified", func() {
+			mockServer.updateCache = newUpdateCache(clock.NewFakeClock(time.Now()), time.Minute)
+			mockServer.published = newPublishedFeatures()
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, workerName).Return(mockNode, nil)
+			mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
+			_, err := mockServer.SetLabels(mockCtx, mockReq)
+			So(err, ShouldBeNil)
+			mockHelper.AssertNumberOfCalls(t, "UpdateNode", 1)
+
+			Convey("Identical requests should not be re-applied", func() {
+				_, err := mockServer.SetLabels(mockCtx, mockReq)
+				So(err, ShouldBeNil)
+				mockHelper.AssertNumberOfCalls(t, "GetNode", 2)
+				mockHelper.AssertNumberOfCalls(t, "UpdateNode", 1)
+			})
+			Convey("Identical requests should be re-applied if the features were modified by others", func() {
+				delete(mockNode.Labels, LabelNs+"feature-1")
+				_, err := mockServer.SetLabels(mockCtx, mockReq)
+				So(err, ShouldBeNil)
+				mockHelper.AssertNumberOfCalls(t, "UpdateNode", 2)
+				So(mockNode.Labels[LabelNs+"feature-1"], ShouldEqual, "val-1")
+			})
+		})
+
 		Convey("When --label-whitelist is specified", func() {
 			mockServer.args.LabelWhiteList = regexp.MustCompile("^f.*2$")
 			mockHelper.On("GetClient").Return(mockClient, nil)
 			mockHelper.On("GetNode", mockClient, workerName).Return(mockNode, nil)
 			mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
-			_, err := mockServer.SetLabels(mockCtx, mockReq)
+			reply, err := mockServer.SetLabels(mockCtx, mockReq)
 			Convey("Error is nil", func() {
 				So(err, ShouldBeNil)
 			})
+			Convey("Labels not matching the whitelist should be reported as rejected", func() {
+				So(len(reply.Rejected), ShouldEqual, 2)
+				So(reply.Rejected[0].Name, ShouldEqual, "feature-1")
+				So(reply.Rejected[1].Name, ShouldEqual, "feature-3")
+			})
 			Convey("Node object should only have whitelisted labels", func() {
 				So(len(mockNode.Labels), ShouldEqual, 1)
 				So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-2": "val-2"})
 
-				a := map[string]string{AnnotationNs + "worker.version": workerVer, AnnotationNs + "feature-labels": "feature-2", AnnotationNs + "extended-resources": ""}
+				a := map[string]string{AnnotationNs + "worker.version": workerVer, AnnotationNs + "feature-labels": "feature-2", AnnotationNs + "extended-resources": "", AnnotationNs + "node-uid": mockNodeUID}
 				So(len(mockNode.Annotations), ShouldEqual, len(a))
 				So(mockNode.Annotations, ShouldResemble, a)
 			})
@@ -319,15 +769,20 @@ func TestSetLabels(t *testing.T) {
 				"valid.ns/feature-2":   "val-2",
 				"invalid.ns/feature-3": "val-3"}
 			mockReq := &labeler.SetLabelsRequest{NodeName: workerName, NfdVersion: workerVer, Labels: mockLabels}
-			_, err := mockServer.SetLabels(mockCtx, mockReq)
+			reply, err := mockServer.SetLabels(mockCtx, mockReq)
 			Convey("Error is nil", func() {
 				So(err, ShouldBeNil)
 			})
+			Convey("Labels in disallowed namespaces should be reported as rejected", func() {
+				So(len(reply.Rejected), ShouldEqual, 1)
+				So(reply.Rejected[0].Kind, ShouldEqual, rejectedLabel)
+				So(reply.Rejected[0].Name, ShouldEqual, "invalid.ns/feature-3")
+			})
 			Convey("Node object should only have allowed label namespaces", func() {
 				So(len(mockNode.Labels), ShouldEqual, 2)
 				So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-1": "val-1", "valid.ns/feature-2": "val-2"})
 
-				a := map[string]string{AnnotationNs + "worker.version": workerVer, AnnotationNs + "feature-labels": "feature-1,valid.ns/feature-2", AnnotationNs + "extended-resources": ""}
+				a := map[string]string{AnnotationNs + "worker.version": workerVer, AnnotationNs + "feature-labels": "feature-1,valid.ns/feature-2", AnnotationNs + "extended-resources": "", AnnotationNs + "node-uid": mockNodeUID}
 				So(len(mockNode.Annotations), ShouldEqual, len(a))
 				So(mockNode.Annotations, ShouldResemble, a)
 			})
@@ -352,6 +807,190 @@ func TestSetLabels(t *testing.T) {
 	})
 }
 
+func TestGetLabels(t *testing.T) {
+	Convey("When servicing GetLabels request", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		mockServer := labelerServer{ns: defaultNs, apiHelper: mockHelper}
+		mockNode := newMockNode()
+		mockNode.Labels[LabelNs+"feature-1"] = "true"
+		mockNode.Labels["vendor.com/feature-2"] = "val-2"
+		mockNode.Labels["other-label"] = "foo"
+		mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1,feature-3"
+		mockNode.Annotations[AnnotationNs+"feature-labels.vendor-a"] = "vendor.com/feature-2"
+		mockNode.Annotations[AnnotationNs+"feature-annotations"] = "vendor.com/feature-4"
+		mockNode.Annotations["vendor.com/feature-4"] = "value 4"
+		mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-5"
+		mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-5")] = *resource.NewQuantity(5, resource.BinarySI)
+		mockReq := &labeler.GetLabelsRequest{NodeName: mockNodeName}
+
+		Convey("Features of all owners should be returned", func() {
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+			reply, err := mockServer.GetLabels(context.Background(), mockReq)
+			So(err, ShouldBeNil)
+			So(reply.Labels, ShouldResemble, map[string]string{LabelNs + "feature-1": "true", "vendor.com/feature-2": "val-2"})
+			So(reply.Annotations, ShouldResemble, map[string]string{"vendor.com/feature-4": "value 4"})
+			So(reply.ExtendedResources, ShouldResemble, map[string]string{LabelNs + "feature-5": "5"})
+		})
+
+		Convey("Names should be resolved with the recorded label namespace", func() {
+			mockServer.ns = namespaces{label: "feature.example.com/", annotation: AnnotationNs}
+			mockNode.Labels = map[string]string{"feature.example.com/feature-1": "true"}
+			mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+			mockNode.Annotations[AnnotationNs+"label-ns"] = "feature.example.com/"
+			mockNode.Status.Capacity = api.ResourceList{api.ResourceName("feature.example.com/feature-5"): *resource.NewQuantity(5, resource.BinarySI)}
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+			reply, err := mockServer.GetLabels(context.Background(), mockReq)
+			So(err, ShouldBeNil)
+			So(reply.Labels, ShouldResemble, map[string]string{"feature.example.com/feature-1": "true"})
+			So(reply.ExtendedResources, ShouldResemble, map[string]string{"feature.example.com/feature-5": "5"})
+		})
+
+		Convey("When getting the node object fails", func() {
+			mockErr := errors.New("mock-error")
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, mockErr)
+			_, err := mockServer.GetLabels(context.Background(), mockReq)
+			So(err, ShouldEqual, mockErr)
+		})
+
+		Convey("With '--no-publish' an error should be returned", func() {
+			mockServer.args.NoPublish = true
+			_, err := mockServer.GetLabels(context.Background(), mockReq)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetCapabilities(t *testing.T) {
+	Convey("When servicing GetCapabilities request", t, func() {
+		mockServer := labelerServer{ns: defaultNs}
+		reply, err := mockServer.GetCapabilities(context.Background(), &labeler.GetCapabilitiesRequest{NfdVersion: "0.1-test"})
+
+		Convey("The master version and capabilities should be returned", func() {
+			So(err, ShouldBeNil)
+			So(reply.NfdVersion, ShouldEqual, version.Get())
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityFeatureAnnotations)
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityRejections)
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityGetLabels)
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityExtendedResources)
+			So(reply.Capabilities, ShouldNotContain, labeler.CapabilityTaints)
+			So(reply.Capabilities, ShouldNotContain, labeler.CapabilityWatchFeatures)
+		})
+
+		Convey("Taints should be supported if enabled", func() {
+			mockServer.args.EnableTaints = true
+			reply, err := mockServer.GetCapabilities(context.Background(), &labeler.GetCapabilitiesRequest{NfdVersion: "0.1-test"})
+			So(err, ShouldBeNil)
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityTaints)
+		})
+
+		Convey("Watching should be supported with the node cache", func() {
+			mockServer.watchers = newFeatureWatchers()
+			reply, err := mockServer.GetCapabilities(context.Background(), &labeler.GetCapabilitiesRequest{NfdVersion: "0.1-test"})
+			So(err, ShouldBeNil)
+			So(reply.Capabilities, ShouldContain, labeler.CapabilityWatchFeatures)
+		})
+	})
+}
+
+// fakeWatchFeaturesServer is a server stream of WatchFeatures recording the
+// events sent
+type fakeWatchFeaturesServer struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *labeler.FeatureEvent
+}
+
+func (s *fakeWatchFeaturesServer) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeWatchFeaturesServer) Send(e *labeler.FeatureEvent) error {
+	s.events <- e
+	return nil
+}
+
+func TestWatchFeatures(t *testing.T) {
+	Convey("When servicing WatchFeatures request", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		mockServer := labelerServer{ns: defaultNs, published: newPublishedFeatures(), watchers: newFeatureWatchers(),
+			updateCache: newUpdateCache(fakeClock, time.Minute)}
+		mockReq := &labeler.WatchFeaturesRequest{NodeName: mockNodeName}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stream := &fakeWatchFeaturesServer{ctx: ctx, events: make(chan *labeler.FeatureEvent, 10)}
+
+		Convey("Only changes made by others should be reported", func() {
+			done := make(chan error)
+			go func() { done <- mockServer.WatchFeatures(mockReq, stream) }()
+			for {
+				mockServer.watchers.Lock()
+				n := len(mockServer.watchers.streams[mockNodeName])
+				mockServer.watchers.Unlock()
+				if n > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			handler := mockServer.nodeEventHandler()
+			mockNode := newMockNode()
+			mockNode.Labels[LabelNs+"feature-1"] = "true"
+			mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+			mockServer.updateCache.store(mockNodeName, Labels{"feature-1": "true"}, Annotations{}, ExtendedResources{})
+
+			// Update done by nfd-master
+			prev := mockNode.DeepCopy()
+			mockNode.Labels[LabelNs+"feature-1"] = "false"
+			mockServer.published.store(mockNodeName, mockNode.UID, watchedFeatures(mockNode, AnnotationNs))
+			handler.OnUpdate(prev, mockNode.DeepCopy())
+
+			// Update not touching the features
+			prev = mockNode.DeepCopy()
+			mockNode.Labels["other-label"] = "foo"
+			handler.OnUpdate(prev, mockNode.DeepCopy())
+			So(mockServer.updateCache.entries, ShouldContainKey, mockNodeName)
+
+			// Update done by someone else
+			prev = mockNode.DeepCopy()
+			delete(mockNode.Labels, LabelNs+"feature-1")
+			handler.OnUpdate(prev, mockNode.DeepCopy())
+
+			e := <-stream.events
+			So(e.NodeName, ShouldEqual, mockNodeName)
+			So(e.Reason, ShouldEqual, featureEventModified)
+			So(mockServer.updateCache.entries, ShouldNotContainKey, mockNodeName)
+
+			nodeLastSuccessfulUpdate.WithLabelValues(mockNodeName).SetToCurrentTime()
+			handler.OnDelete(cache.DeletedFinalStateUnknown{Key: mockNodeName, Obj: mockNode.DeepCopy()})
+			e = <-stream.events
+			So(e.Reason, ShouldEqual, featureEventDeleted)
+			So(mockServer.published.nodes, ShouldNotContainKey, mockNodeName)
+			So(nodeLastSuccessfulUpdate.DeleteLabelValues(mockNodeName), ShouldBeFalse)
+
+			cancel()
+			So(<-done, ShouldBeNil)
+			So(stream.events, ShouldBeEmpty)
+			So(mockServer.watchers.streams, ShouldBeEmpty)
+		})
+
+		Convey("Without node cache an error should be returned", func() {
+			mockServer.watchers = nil
+			err := mockServer.WatchFeatures(mockReq, stream)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("With '--no-publish' an error should be returned", func() {
+			mockServer.args.NoPublish = true
+			err := mockServer.WatchFeatures(mockReq, stream)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestAddLabels(t *testing.T) {
 	Convey("When adding labels", t, func() {
 		labels := map[string]string{}
@@ -362,7 +1001,7 @@ func TestAddLabels(t *testing.T) {
 		}
 
 		Convey("If no labels are passed", func() {
-			addLabels(n, labels)
+			addLabels(n, labels, LabelNs)
 
 			Convey("None should be added", func() {
 				So(len(n.Labels), ShouldEqual, 0)
@@ -372,7 +1011,7 @@ func TestAddLabels(t *testing.T) {
 		Convey("They should be added to the node.Labels", func() {
 			test1 := "test1"
 			labels[test1] = "true"
-			addLabels(n, labels)
+			addLabels(n, labels, LabelNs)
 			So(n.Labels, ShouldContainKey, LabelNs+test1)
 		})
 	})
@@ -412,3 +1051,711 @@ func TestRemoveLabelsWithPrefix(t *testing.T) {
 		})
 	})
 }
+
+func TestLegacyLabelPrefixes(t *testing.T) {
+	Convey("When resolving legacy label prefixes", t, func() {
+		Convey("Built-in prefixes should be used by default", func() {
+			c := NFDConfig{}
+			So(c.legacyLabelPrefixes(), ShouldResemble, defaultLegacyLabelPrefixes)
+		})
+
+		Convey("Configured prefixes should replace the built-in ones", func() {
+			f, err := ioutil.TempFile("", "nfd-master-conf")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+
+			_, err = f.WriteString("legacyLabelPrefixes: [\"old.vendor.com/\"]\n")
+			So(err, ShouldBeNil)
+			c, err := loadConfig(f.Name())
+			So(err, ShouldBeNil)
+			So(c.legacyLabelPrefixes(), ShouldResemble, []string{"old.vendor.com/"})
+
+			So(f.Truncate(0), ShouldBeNil)
+			_, err = f.WriteAt([]byte("legacyLabelPrefixes: []\n"), 0)
+			So(err, ShouldBeNil)
+			c, err = loadConfig(f.Name())
+			So(err, ShouldBeNil)
+			So(c.legacyLabelPrefixes(), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestLabelValueRules(t *testing.T) {
+	Convey("When applying label value rules", t, func() {
+		c := NFDConfig{LabelValueRules: []LabelValueRule{
+			{Label: "^feature-1$", Map: map[string]string{"true": "enabled"}},
+			{Label: "^feature-2$", Lowercase: true},
+			{Label: "^feature-3$", Regexp: "-v[0-9.]+$", Replacement: ""},
+			{Label: "^feature-4$", Regexp: "^.*$", Replacement: "in valid"},
+		}}
+		So(c.compile(), ShouldBeNil)
+
+		labels := Labels{"feature-1": "true", "feature-2": "MiXeD", "feature-3": "foo-v1.2.3", "feature-4": "val-4", "feature-5": "true"}
+		applyLabelValueRules(labels, c.LabelValueRules)
+
+		Convey("Matching label values should be rewritten", func() {
+			So(labels, ShouldResemble, Labels{"feature-1": "enabled", "feature-2": "mixed", "feature-3": "foo", "feature-5": "true"})
+		})
+	})
+
+	Convey("When the config has an invalid rule", t, func() {
+		c := NFDConfig{LabelValueRules: []LabelValueRule{{Regexp: "("}}}
+		Convey("Compiling should fail", func() {
+			So(c.compile(), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDeprecatedLabels(t *testing.T) {
+	Convey("When applying deprecated label mappings", t, func() {
+		deprecated := map[string]string{"old-1": "new-1", "old-2": "new-2", "old-3": "new-3"}
+		labels := Labels{"old-1": "val-1", "new-2": "val-2", "feature": "val"}
+		applyDeprecatedLabels(labels, deprecated)
+
+		Convey("Both old and new names should be published", func() {
+			So(labels, ShouldResemble, Labels{"old-1": "val-1", "new-1": "val-1", "old-2": "val-2", "new-2": "val-2", "feature": "val"})
+		})
+	})
+}
+
+func TestStop(t *testing.T) {
+	Convey("When stopping nfd-master", t, func() {
+		Convey("Run should return without serving if stopped before", func() {
+			m, err := NewNfdMaster(Args{NoPublish: true, ListenAddress: "127.0.0.1"})
+			So(err, ShouldBeNil)
+			m.Stop()
+			So(m.Run(), ShouldBeNil)
+			So(m.WaitForReady(0), ShouldBeFalse)
+		})
+
+		Convey("Stop should wait for the server indefinitely with zero drain timeout", func() {
+			m, err := NewNfdMaster(Args{NoPublish: true, ListenAddress: "127.0.0.1"})
+			So(err, ShouldBeNil)
+			done := make(chan error)
+			go func() { done <- m.Run() }()
+			So(m.WaitForReady(10*time.Second), ShouldBeTrue)
+			m.Stop()
+			So(<-done, ShouldBeNil)
+		})
+	})
+}
+
+func TestWaitForReady(t *testing.T) {
+	Convey("When waiting for nfd-master to become ready", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		m := &nfdMaster{ready: make(chan struct{}), clock: fakeClock}
+
+		Convey("When the server becomes ready", func() {
+			close(m.ready)
+			Convey("Waiting should succeed", func() {
+				So(m.WaitForReady(time.Minute), ShouldBeTrue)
+			})
+			Convey("All consumers of Ready() should be notified", func() {
+				for i := 0; i < 2; i++ {
+					_, ok := <-m.Ready()
+					So(ok, ShouldBeFalse)
+				}
+			})
+		})
+
+		Convey("When the server does not become ready before the timeout", func() {
+			result := make(chan bool)
+			go func() { result <- m.WaitForReady(time.Minute) }()
+			for !fakeClock.HasWaiters() {
+				runtime.Gosched()
+			}
+			fakeClock.Step(time.Minute)
+			Convey("Waiting should fail", func() {
+				So(<-result, ShouldBeFalse)
+			})
+		})
+	})
+}
+
+func TestLabelTransforms(t *testing.T) {
+	Convey("When applying label transforms", t, func() {
+		c := NFDConfig{LabelTransforms: LabelTransforms{
+			KeyRewrites: []KeyRewrite{
+				{Regexp: "^cpu-cpuid\\.(.*)$", Replacement: "cpu-$1"},
+				{Regexp: "^feature-3$", Replacement: "invalid name"},
+			},
+			NamespaceRemap: map[string]string{"old.ns": "new.ns", "feature.node.kubernetes.io": "vendor.io", "nfd.ns": "feature.node.kubernetes.io"},
+		}}
+		So(c.compile(), ShouldBeNil)
+
+		labels := Labels{"cpu-cpuid.AVX": "true", "old.ns/feature-1": "val-1", "nfd.ns/feature-2": "val-2", "feature-3": "val-3", "other.ns/feature-4": "val-4"}
+		labels = applyLabelTransforms(labels, c.LabelTransforms, LabelNs)
+
+		Convey("Label names should be rewritten", func() {
+			So(labels, ShouldResemble, Labels{"vendor.io/cpu-AVX": "true", "new.ns/feature-1": "val-1", "feature-2": "val-2", "other.ns/feature-4": "val-4"})
+		})
+	})
+}
+
+func TestDiffLabels(t *testing.T) {
+	Convey("When comparing two sets of labels", t, func() {
+		oldLabels := map[string]string{"a": "1", "b": "2", "c": "3"}
+		newLabels := map[string]string{"b": "2", "c": "4", "d": "5", "e": "6"}
+		changes := diffLabels(oldLabels, newLabels)
+
+		Convey("Added, removed and changed labels should be detected", func() {
+			So(changes.added, ShouldResemble, []string{"d", "e"})
+			So(changes.removed, ShouldResemble, []string{"a"})
+			So(changes.changed, ShouldResemble, []string{"c"})
+		})
+
+		Convey("Summary of the changes should contain the label values", func() {
+			c := nodeChanges{labels: changes, oldLabels: oldLabels, newLabels: newLabels}
+			So(c.String(), ShouldEqual, "added: d=5, e=6; removed: a; changed: c=4 (was 3)")
+			So(nodeChanges{}.String(), ShouldEqual, "no label changes")
+		})
+	})
+}
+
+func TestUpdateCache(t *testing.T) {
+	Convey("When suppressing duplicate node updates", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		c := newUpdateCache(fakeClock, time.Minute)
+		labels := Labels{"feature-1": "val-1"}
+		annotations := Annotations{"feature-labels": "feature-1"}
+		extendedResources := ExtendedResources{}
+
+		Convey("Unknown nodes should not be considered duplicates", func() {
+			So(c.isDuplicate(mockNodeName, labels, annotations, extendedResources), ShouldBeFalse)
+		})
+
+		c.store(mockNodeName, labels, annotations, extendedResources)
+		Convey("Identical update within the window should be a duplicate", func() {
+			So(c.isDuplicate(mockNodeName, labels, annotations, extendedResources), ShouldBeTrue)
+		})
+		Convey("Changed content should not be a duplicate", func() {
+			So(c.isDuplicate(mockNodeName, Labels{"feature-1": "val-2"}, annotations, extendedResources), ShouldBeFalse)
+		})
+		Convey("Identical update after the window should not be a duplicate", func() {
+			fakeClock.Step(time.Minute)
+			So(c.isDuplicate(mockNodeName, labels, annotations, extendedResources), ShouldBeFalse)
+		})
+		Convey("A nil cache should never report duplicates", func() {
+			var nilCache *updateCache
+			So(nilCache.isDuplicate(mockNodeName, labels, annotations, extendedResources), ShouldBeFalse)
+		})
+	})
+}
+
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestAuditLog(t *testing.T) {
+	Convey("When recording changes to the audit log", t, func() {
+		buf := &bytes.Buffer{}
+		a := &auditLog{clock: clock.NewFakeClock(time.Now()), file: nopWriteCloser{buf}}
+		oldLabels := map[string]string{"a": "1", "b": "2"}
+		newLabels := map[string]string{"b": "3", "c": "4"}
+
+		Convey("A record of the changes should be written", func() {
+			changes := nodeChanges{labels: diffLabels(oldLabels, newLabels), oldLabels: oldLabels, newLabels: newLabels}
+			a.record(mockNodeName, "mock-requester", changes)
+
+			r := auditRecord{}
+			So(json.Unmarshal(buf.Bytes(), &r), ShouldBeNil)
+			So(r.Node, ShouldEqual, mockNodeName)
+			So(r.Requester, ShouldEqual, "mock-requester")
+			So(r.LabelsAdded, ShouldResemble, map[string]string{"c": "4"})
+			So(r.LabelsRemoved, ShouldResemble, map[string]string{"a": "1"})
+			So(r.LabelsChanged, ShouldResemble, map[string]auditValueChange{"b": {Old: "2", New: "3"}})
+		})
+
+		Convey("Taint changes alone should be recorded", func() {
+			changes := nodeChanges{taints: diffTaints(
+				[]api.Taint{{Key: "a", Value: "1", Effect: api.TaintEffectNoSchedule}},
+				[]api.Taint{{Key: "a", Value: "1", Effect: api.TaintEffectNoSchedule}, {Key: "b", Effect: api.TaintEffectNoExecute}})}
+			a.record(mockNodeName, "mock-requester", changes)
+
+			r := auditRecord{}
+			So(json.Unmarshal(buf.Bytes(), &r), ShouldBeNil)
+			So(r.TaintsAdded, ShouldResemble, []string{"b:NoExecute"})
+			So(r.TaintsRemoved, ShouldBeEmpty)
+		})
+
+		Convey("Nothing should be written if there are no changes", func() {
+			a.record(mockNodeName, "mock-requester", nodeChanges{})
+			So(buf.Len(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("When creating an audit log", t, func() {
+		Convey("Without a file or a webhook", func() {
+			a, err := newAuditLog("", "", clock.RealClock{})
+			Convey("Audit logging should be disabled", func() {
+				So(err, ShouldBeNil)
+				So(a, ShouldBeNil)
+			})
+		})
+		Convey("With an audit log file", func() {
+			f, err := ioutil.TempFile("", "nfd-audit")
+			So(err, ShouldBeNil)
+			f.Close()
+			defer os.Remove(f.Name())
+			a, err := newAuditLog(f.Name(), "", clock.RealClock{})
+			Convey("Audit logging should be enabled", func() {
+				So(err, ShouldBeNil)
+				So(a, ShouldNotBeNil)
+				a.file.Close()
+			})
+		})
+	})
+}
+
+func TestDeferExtendedResourceRemoval(t *testing.T) {
+	Convey("When removing extended resources with deferral enabled", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		mockServer := &labelerServer{args: Args{DeferResourceRemoval: true}, ns: defaultNs, apiHelper: mockHelper}
+		mockNode := newMockNode()
+		mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-1,feature-2"
+		mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-1")] = *resource.NewQuantity(1, resource.BinarySI)
+		mockNode.Status.Capacity[api.ResourceName(LabelNs+"feature-2")] = *resource.NewQuantity(2, resource.BinarySI)
+
+		pod := api.Pod{}
+		pod.Spec.Containers = []api.Container{{}}
+		pod.Spec.Containers[0].Resources.Limits = api.ResourceList{api.ResourceName(LabelNs + "feature-1"): *resource.NewQuantity(1, resource.BinarySI)}
+		mockHelper.On("GetPods", mockClient, mockNodeName).Return(&api.PodList{Items: []api.Pod{pod}}, nil)
+
+		extendedResources := ExtendedResources{}
+		annotations := Annotations{"extended-resources": ""}
+		err := mockServer.deferExtendedResourceRemoval(mockClient, mockNode, extendedResources, annotations)
+
+		Convey("Resources in use should be kept", func() {
+			So(err, ShouldBeNil)
+			So(extendedResources, ShouldResemble, ExtendedResources{"feature-1": "1"})
+			So(annotations["extended-resources"], ShouldEqual, "feature-1")
+		})
+
+		Convey("Resources not in use should be removed", func() {
+			resourceOps := getExtendedResourceOps(mockNode, extendedResources, defaultNs)
+			So(len(resourceOps), ShouldEqual, 2)
+		})
+	})
+}
+
+func TestStoreNodeState(t *testing.T) {
+	Convey("When storing the published state of a node", t, func() {
+		mockHelper := &apihelper.MockAPIHelpers{}
+		mockClient := &k8sclient.Clientset{}
+		fakeClock := clock.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+		mockServer := &labelerServer{args: Args{StateNamespace: "nfd"}, ns: defaultNs, apiHelper: mockHelper,
+			clock: fakeClock, storedStates: newStoredStates()}
+		u := nodeUpdate{
+			requester:   "mock-worker",
+			owner:       "vendor-a",
+			labels:      Labels{"vendor.com/feature-1": "true"},
+			annotations: Annotations{"feature-labels.vendor-a": "vendor.com/feature-1"},
+		}
+
+		var stored map[string]string
+		mockHelper.On("GetClient").Return(mockClient, nil)
+		mockHelper.On("UpdateConfigMapData", mockClient, "nfd", "nfd-node-state-"+mockNodeName, mock.Anything).Return(
+			func(c *k8sclient.Clientset, ns, name string, data map[string]string) error {
+				stored = data
+				return nil
+			})
+		mockServer.storeNodeState(mockNodeName, u)
+
+		Convey("State should be stored under the key of the owner", func() {
+			So(stored, ShouldContainKey, "state.vendor-a")
+			state := nodeState{}
+			So(json.Unmarshal([]byte(stored["state.vendor-a"]), &state), ShouldBeNil)
+			So(state.Timestamp, ShouldEqual, "2020-01-02T03:04:05Z")
+			So(state.Requester, ShouldEqual, "mock-worker")
+			So(state.LabelNs, ShouldEqual, LabelNs)
+			So(state.Labels, ShouldResemble, u.labels)
+			So(state.Annotations, ShouldResemble, u.annotations)
+		})
+
+		Convey("Unchanged state should not be re-written", func() {
+			fakeClock.Step(time.Minute)
+			mockServer.storeNodeState(mockNodeName, u)
+			mockHelper.AssertNumberOfCalls(t, "UpdateConfigMapData", 1)
+
+			u.labels = Labels{"vendor.com/feature-1": "false"}
+			mockServer.storeNodeState(mockNodeName, u)
+			mockHelper.AssertNumberOfCalls(t, "UpdateConfigMapData", 2)
+
+			mockServer.storedStates.forget(mockNodeName)
+			mockServer.storeNodeState(mockNodeName, u)
+			mockHelper.AssertNumberOfCalls(t, "UpdateConfigMapData", 3)
+		})
+
+		Convey("Names of over-long ConfigMaps should be shortened", func() {
+			longName := strings.Repeat("a", 250)
+			name := nodeStateConfigMapName(longName)
+			So(len(name), ShouldEqual, 253)
+			So(name, ShouldNotEqual, nodeStateConfigMapName(longName+"b"))
+			So(nodeStateConfigMapName(mockNodeName), ShouldEqual, "nfd-node-state-"+mockNodeName)
+		})
+	})
+}
+
+func TestNodeInconsistencies(t *testing.T) {
+	Convey("When checking the consistency of a node", t, func() {
+		mockNode := newMockNode()
+		mockNode.Labels[LabelNs+"feature-1"] = "true"
+		mockNode.Labels["vendor.com/feature-2"] = "true"
+		mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+		mockNode.Annotations[AnnotationNs+"feature-labels.vendor-a"] = "feature-2"
+		mockNode.Annotations[AnnotationNs+"label-ns.vendor-a"] = "vendor.com/"
+		mockNode.Annotations[AnnotationNs+"taints"] = LabelNs + "feature-3=true:NoSchedule"
+		mockNode.Spec.Taints = []api.Taint{{Key: LabelNs + "feature-3", Value: "true", Effect: api.TaintEffectNoSchedule}}
+
+		Convey("No issues should be reported if all features are present", func() {
+			So(nodeInconsistencies(mockNode, AnnotationNs), ShouldBeEmpty)
+		})
+
+		Convey("Features removed by others should be reported", func() {
+			delete(mockNode.Labels, "vendor.com/feature-2")
+			mockNode.Spec.Taints = nil
+			mockNode.Annotations[AnnotationNs+"extended-resources"] = "feature-4"
+			So(nodeInconsistencies(mockNode, AnnotationNs), ShouldResemble, []string{
+				`label "vendor.com/feature-2" missing`,
+				`extended resource "` + LabelNs + `feature-4" missing`,
+				`taint "` + LabelNs + `feature-3=true:NoSchedule" missing`,
+			})
+		})
+	})
+}
+
+func TestRunBulk(t *testing.T) {
+	Convey("When running a bulk operation", t, func() {
+		nodes := []string{"node-1", "node-2", "node-3", "node-4"}
+		opts := bulkOptions{concurrency: 2}
+
+		Convey("All nodes should be processed", func() {
+			var mutex sync.Mutex
+			processed := []string{}
+			err := runBulk("test", nodes, opts, clock.RealClock{}, func(node string) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				processed = append(processed, node)
+				return nil
+			})
+			sort.Strings(processed)
+			So(err, ShouldBeNil)
+			So(processed, ShouldResemble, nodes)
+		})
+
+		Convey("Failed nodes should be reported", func() {
+			err := runBulk("test", nodes, opts, clock.RealClock{}, func(node string) error {
+				if node == "node-2" {
+					return errors.New("fake error")
+				}
+				return nil
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "1 out of 4 nodes")
+			So(err.Error(), ShouldContainSubstring, "node-2: fake error")
+		})
+
+		Convey("Timed out nodes should be reported", func() {
+			fakeClock := clock.NewFakeClock(time.Now())
+			opts.nodeTimeout = time.Second
+			release := make(chan struct{})
+			defer close(release)
+
+			errChan := make(chan error)
+			go func() {
+				errChan <- runBulk("test", nodes[:1], opts, fakeClock, func(node string) error {
+					<-release
+					return nil
+				})
+			}()
+			for !fakeClock.HasWaiters() {
+				runtime.Gosched()
+			}
+			fakeClock.Step(time.Second)
+
+			err := <-errChan
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "node-1: timed out")
+		})
+	})
+}
+
+func TestClientNamespaces(t *testing.T) {
+	Convey("When restricting namespaces per client", t, func() {
+		rules := []ClientNamespaceRule{
+			{OrganizationalUnit: "vendor-a", Namespaces: []string{"a.vendor.com"}},
+			{URI: "spiffe://cluster.local/ns/vendor-b/sa/nfd-worker", Namespaces: []string{"b.vendor.com"}},
+		}
+		certA := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"vendor-a"}}}
+		uriB, _ := url.Parse("spiffe://cluster.local/ns/vendor-b/sa/nfd-worker")
+		certB := &x509.Certificate{URIs: []*url.URL{uriB}}
+
+		Convey("Matching clients should only be denied other restricted namespaces", func() {
+			So(deniedNamespaces(rules, certA), ShouldResemble, map[string]bool{"b.vendor.com": true})
+			So(deniedNamespaces(rules, certB), ShouldResemble, map[string]bool{"a.vendor.com": true})
+		})
+
+		Convey("Clients without a certificate should be denied all restricted namespaces", func() {
+			So(deniedNamespaces(rules, nil), ShouldResemble, map[string]bool{"a.vendor.com": true, "b.vendor.com": true})
+		})
+
+		Convey("Labels in denied namespaces should be filtered out", func() {
+			labels := Labels{"feature-1": "true", "a.vendor.com/feature-2": "true", "b.vendor.com/feature-3": "true", "c.vendor.com/feature-4": "true"}
+			rej := newRejections(mockNodeName)
+			filterDeniedNamespaces(labels, deniedNamespaces(rules, certA), rejectedLabel, rej)
+			So(labels, ShouldResemble, Labels{"feature-1": "true", "a.vendor.com/feature-2": "true", "c.vendor.com/feature-4": "true"})
+			So(len(rej.list()), ShouldEqual, 1)
+			So(rej.list()[0].Name, ShouldEqual, "b.vendor.com/feature-3")
+		})
+	})
+
+	Convey("When loading a config with invalid client namespace rules", t, func() {
+		c := NFDConfig{ClientNamespaces: []ClientNamespaceRule{{Namespaces: []string{"a.vendor.com"}}}}
+		So(c.compile(), ShouldNotBeNil)
+	})
+}
+
+func TestNodeNamePatterns(t *testing.T) {
+	Convey("When verifying the node name against a client certificate", t, func() {
+		c := NFDConfig{NodeNamePatterns: []string{"^system:node:(?P<nodename>.+)$", "^(.+)\\.cluster\\.local$"}}
+		So(c.compile(), ShouldBeNil)
+
+		Convey("An exact CN match should be accepted without patterns", func() {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: mockNodeName}}
+			So(certMatchesNode(cert, mockNodeName, nil), ShouldBeTrue)
+			So(certMatchesNode(cert, "other-node", nil), ShouldBeFalse)
+		})
+
+		Convey("The node name should be extracted from the CN", func() {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "system:node:" + mockNodeName}}
+			So(certMatchesNode(cert, mockNodeName, nil), ShouldBeFalse)
+			So(certMatchesNode(cert, mockNodeName, c.nodeNameRegexps), ShouldBeTrue)
+			So(certMatchesNode(cert, "other-node", c.nodeNameRegexps), ShouldBeFalse)
+		})
+
+		Convey("The node name should be extracted from the DNS SANs", func() {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "nfd-worker"}, DNSNames: []string{"foo.example.com", mockNodeName + ".cluster.local"}}
+			So(certMatchesNode(cert, mockNodeName, c.nodeNameRegexps), ShouldBeTrue)
+			So(certMatchesNode(cert, "foo", c.nodeNameRegexps), ShouldBeFalse)
+		})
+
+		Convey("Patterns should only match the whole identity", func() {
+			c := NFDConfig{NodeNamePatterns: []string{"system:node:(.+)", "(.+)\\.cluster\\.local"}}
+			So(c.compile(), ShouldBeNil)
+
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: "system:node:" + mockNodeName}}
+			So(certMatchesNode(cert, mockNodeName, c.nodeNameRegexps), ShouldBeTrue)
+			cert = &x509.Certificate{Subject: pkix.Name{CommonName: "evil-system:node:" + mockNodeName}}
+			So(certMatchesNode(cert, mockNodeName, c.nodeNameRegexps), ShouldBeFalse)
+			cert = &x509.Certificate{Subject: pkix.Name{CommonName: "nfd-worker"}, DNSNames: []string{mockNodeName + ".cluster.local.evil.com"}}
+			So(certMatchesNode(cert, mockNodeName, c.nodeNameRegexps), ShouldBeFalse)
+		})
+	})
+
+	Convey("When loading a config with invalid node name patterns", t, func() {
+		c := NFDConfig{NodeNamePatterns: []string{"^system:node:.+$"}}
+		So(c.compile(), ShouldNotBeNil)
+		c = NFDConfig{NodeNamePatterns: []string{"^(.+"}}
+		So(c.compile(), ShouldNotBeNil)
+	})
+}
+
+func TestLabelOwners(t *testing.T) {
+	Convey("When tracking label ownership per client", t, func() {
+		c := NFDConfig{LabelOwners: []LabelOwnerRule{{Name: "vendor-a", OrganizationalUnit: "vendor-a"}}}
+		So(c.compile(), ShouldBeNil)
+
+		Convey("Owner should be resolved from the client certificate", func() {
+			certA := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"vendor-a"}}}
+			certB := &x509.Certificate{Subject: pkix.Name{OrganizationalUnit: []string{"vendor-b"}}}
+			So(labelOwner(c.LabelOwners, certA), ShouldEqual, "vendor-a")
+			So(labelOwner(c.LabelOwners, certB), ShouldEqual, "")
+			So(labelOwner(c.LabelOwners, nil), ShouldEqual, "")
+		})
+
+		Convey("Updates of an owner should not remove features of other owners", func() {
+			mockHelper := &apihelper.MockAPIHelpers{}
+			mockClient := &k8sclient.Clientset{}
+			mockServer := &labelerServer{ns: defaultNs, apiHelper: mockHelper}
+			mockNode := newMockNode()
+			mockNode.Labels[LabelNs+"feature-1"] = "true"
+			mockNode.Annotations[AnnotationNs+"feature-labels"] = "feature-1"
+			mockHelper.On("GetClient").Return(mockClient, nil)
+			mockHelper.On("GetNode", mockClient, mockNodeName).Return(mockNode, nil)
+			mockHelper.On("UpdateNode", mockClient, mockNode).Return(nil)
+
+			_, err := mockServer.updateNodeFeatures(mockNodeName, "vendor-a", Labels{"vendor.com/feature-2": "true"}, Annotations{"feature-labels.vendor-a": "vendor.com/feature-2"}, ExtendedResources{})
+			So(err, ShouldBeNil)
+			So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-1": "true", "vendor.com/feature-2": "true"})
+			So(featureOwners(mockNode, AnnotationNs), ShouldResemble, []string{"vendor-a"})
+
+			_, err = mockServer.updateNodeFeatures(mockNodeName, "", Labels{"feature-3": "true"}, Annotations{"feature-labels": "feature-3"}, ExtendedResources{})
+			So(err, ShouldBeNil)
+			So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-3": "true", "vendor.com/feature-2": "true"})
+
+			_, err = mockServer.updateNodeFeatures(mockNodeName, "vendor-a", Labels{}, Annotations{"feature-labels.vendor-a": ""}, ExtendedResources{})
+			So(err, ShouldBeNil)
+			So(mockNode.Labels, ShouldResemble, map[string]string{LabelNs + "feature-3": "true"})
+		})
+	})
+
+	Convey("When loading a config with invalid label owner rules", t, func() {
+		c := NFDConfig{LabelOwners: []LabelOwnerRule{{Name: "vendor-a"}}}
+		So(c.compile(), ShouldNotBeNil)
+		c = NFDConfig{LabelOwners: []LabelOwnerRule{{Name: "Vendor_A", URI: "spiffe://vendor-a"}}}
+		So(c.compile(), ShouldNotBeNil)
+		c = NFDConfig{LabelOwners: []LabelOwnerRule{{Name: "vendor-a", URI: "spiffe://a"}, {Name: "vendor-a", URI: "spiffe://b"}}}
+		So(c.compile(), ShouldNotBeNil)
+	})
+}
+
+func TestInterceptors(t *testing.T) {
+	Convey("When chaining interceptors", t, func() {
+		calls := []string{}
+		newInterceptor := func(name string) grpc.UnaryServerInterceptor {
+			return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				calls = append(calls, name)
+				return handler(ctx, req)
+			}
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			calls = append(calls, "handler")
+			return "reply", nil
+		}
+
+		chained := chainUnaryInterceptors(newInterceptor("first"), newInterceptor("second"))
+		resp, err := chained(context.Background(), "request", &grpc.UnaryServerInfo{}, handler)
+
+		Convey("Interceptors should be called in order", func() {
+			So(err, ShouldBeNil)
+			So(resp, ShouldEqual, "reply")
+			So(calls, ShouldResemble, []string{"first", "second", "handler"})
+		})
+	})
+
+	Convey("When logging a failed request", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		mockErr := errors.New("mock-error")
+		ctx := context.WithValue(context.Background(), requestIDContextKey{}, "test-1")
+		interceptor := newLoggingInterceptor(0, fakeClock)
+
+		var reqID string
+		_, err := interceptor(ctx, &labeler.SetLabelsRequest{NodeName: mockNodeName}, &grpc.UnaryServerInfo{FullMethod: "/labeler.Labeler/SetLabels"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				reqID = requestID(ctx)
+				return nil, mockErr
+			})
+
+		Convey("The error and request ID should be passed through", func() {
+			So(err, ShouldEqual, mockErr)
+			So(reqID, ShouldEqual, "test-1")
+		})
+	})
+}
+
+func TestUpdateQueue(t *testing.T) {
+	Convey("When updating a node via the update queue", t, func() {
+		fakeClock := clock.NewFakeClock(time.Now())
+		var mutex sync.Mutex
+		applied := []nodeUpdate{}
+		q := newUpdateQueue(fakeClock, time.Second, func(node string, u nodeUpdate) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			applied = append(applied, u)
+			return nil
+		})
+
+		errChan := make(chan error, 2)
+		go func() { errChan <- q.update(mockNodeName, nodeUpdate{requester: "first"}) }()
+		for !fakeClock.HasWaiters() {
+			runtime.Gosched()
+		}
+		go func() { errChan <- q.update(mockNodeName, nodeUpdate{requester: "second"}) }()
+		for {
+			q.Lock()
+			requester := q.pending[updateKey{node: mockNodeName}].requester
+			q.Unlock()
+			if requester == "second" {
+				break
+			}
+			runtime.Gosched()
+		}
+		fakeClock.Step(time.Second)
+
+		Convey("Successive updates should be coalesced into one", func() {
+			So(<-errChan, ShouldBeNil)
+			So(<-errChan, ShouldBeNil)
+			So(applied, ShouldResemble, []nodeUpdate{{requester: "second"}})
+		})
+	})
+}
+
+func TestLabelSchemas(t *testing.T) {
+	Convey("When validating label values against schemas", t, func() {
+		min, max := int64(0), int64(100)
+		c := NFDConfig{LabelSchemas: []LabelSchema{
+			{Label: "^custom-mode$", Enum: []string{"a", "b"}},
+			{Label: "^custom-count$", Integer: &IntegerRange{Min: &min, Max: &max}},
+			{Label: "^custom-version$", Regexp: "^v[0-9]+$"},
+		}}
+		So(c.compile(), ShouldBeNil)
+
+		Convey("Valid values should be accepted", func() {
+			labels := Labels{"custom-mode": "a", "custom-count": "42", "custom-version": "v1", "other": "foo"}
+			So(validateLabelValues(labels, c.LabelSchemas), ShouldBeNil)
+		})
+
+		Convey("Invalid values should be rejected", func() {
+			labels := Labels{"custom-mode": "c", "custom-count": "101", "custom-version": "1"}
+			err := validateLabelValues(labels, c.LabelSchemas)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "label custom-mode")
+			So(err.Error(), ShouldContainSubstring, "label custom-count")
+			So(err.Error(), ShouldContainSubstring, "label custom-version")
+		})
+
+		Convey("Non-integer values should be rejected", func() {
+			So(validateLabelValues(Labels{"custom-count": "true"}, c.LabelSchemas), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	Convey("When probing the health endpoints", t, func() {
+		apiServerErr := error(nil)
+		m := &nfdMaster{ready: make(chan struct{}), stop: make(chan struct{}), clock: clock.RealClock{}}
+		m.apiServerCheck = func() error { return apiServerErr }
+
+		probe := func(handler http.HandlerFunc) int {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", "/", nil))
+			return w.Code
+		}
+
+		Convey("Readiness probe should fail before the gRPC server is ready", func() {
+			So(probe(m.healthzHandler), ShouldEqual, http.StatusOK)
+			So(probe(m.readyzHandler), ShouldEqual, http.StatusServiceUnavailable)
+		})
+
+		Convey("When the gRPC server is ready", func() {
+			close(m.ready)
+			Convey("Probes should succeed", func() {
+				So(probe(m.healthzHandler), ShouldEqual, http.StatusOK)
+				So(probe(m.readyzHandler), ShouldEqual, http.StatusOK)
+			})
+			Convey("Readiness probe should fail if the API server is not reachable", func() {
+				apiServerErr = errors.New("connection refused")
+				So(probe(m.healthzHandler), ShouldEqual, http.StatusOK)
+				So(probe(m.readyzHandler), ShouldEqual, http.StatusServiceUnavailable)
+			})
+			Convey("Readiness probe should fail when the server is stopping", func() {
+				close(m.stop)
+				So(probe(m.healthzHandler), ShouldEqual, http.StatusOK)
+				So(probe(m.readyzHandler), ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+}
@@ -24,28 +24,43 @@ import (
 	"log"
 	"net"
 	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8sclient "k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/node-feature-discovery/pkg/apihelper"
 	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
 	"sigs.k8s.io/node-feature-discovery/pkg/version"
 )
 
 const (
-	// Namespace for feature labels
+	// Default namespace for feature labels
 	LabelNs = "feature.node.kubernetes.io/"
 
-	// Namespace for all NFD-related annotations
+	// Default namespace for all NFD-related annotations
 	AnnotationNs = "nfd.node.kubernetes.io/"
+
+	// Max total size of the feature annotations of one node. Kubernetes
+	// limits the total size of all annotations of an object to 256kB.
+	maxFeatureAnnotationsSize = 128 * 1024
 )
 
 // package loggers
@@ -53,8 +68,18 @@ var (
 	stdoutLogger = log.New(os.Stdout, "", log.LstdFlags)
 	stderrLogger = log.New(os.Stderr, "", log.LstdFlags)
 	nodeName     = os.Getenv("NODE_NAME")
+	// instanceName identifies this nfd-master instance, i.e. the pod name
+	// when running in a Kubernetes pod
+	instanceName, _ = os.Hostname()
 )
 
+// namespaces are the effective namespaces of feature labels and NFD-related
+// annotations, configurable with Args. Both have a trailing slash.
+type namespaces struct {
+	label      string
+	annotation string
+}
+
 // Labels are a Kubernetes representation of discovered features.
 type Labels map[string]string
 
@@ -66,30 +91,72 @@ type Annotations map[string]string
 
 // Command line arguments
 type Args struct {
-	CaFile         string
-	CertFile       string
-	ExtraLabelNs   []string
-	KeyFile        string
-	Kubeconfig     string
-	LabelWhiteList *regexp.Regexp
-	NoPublish      bool
-	Port           int
-	Prune          bool
-	VerifyNodeName bool
-	ResourceLabels []string
+	AuditLog             string
+	AnnotationNs         string
+	AuditWebhook         string
+	BulkConcurrency      int
+	BulkNodeTimeout      time.Duration
+	BulkTimeout          time.Duration
+	CaFile               string
+	CertFile             string
+	CheckConsistency     bool
+	CoalesceWindow       time.Duration
+	CompressionLevel     int
+	ConfigFile           string
+	DedupWindow          time.Duration
+	DeferResourceRemoval bool
+	DrainTimeout         time.Duration
+	EnablePprof          bool
+	EnableTaints         bool
+	ExtraAnnotationNs    []string
+	ExtraLabelNs         []string
+	HealthPort           int
+	KeyFile              string
+	Kubeconfig           string
+	LabelNs              string
+	LabelWhiteList       *regexp.Regexp
+	ListenAddress        string
+	ListenFamily         string
+	MaxConnectionAge     time.Duration
+	MetricsPort          int
+	NodeCache            bool
+	NoPublish            bool
+	Port                 int
+	PprofPort            int
+	Prune                bool
+	ResyncInterval       time.Duration
+	StateNamespace       string
+	VerifyNodeName       bool
+	Verbosity            int
+	ResourceLabels       []string
 }
 
 type NfdMaster interface {
 	Run() error
 	Stop()
+	// Ready returns a channel that is closed when the server is able to
+	// accept connections
+	Ready() <-chan struct{}
 	WaitForReady(time.Duration) bool
 }
 
 type nfdMaster struct {
 	args      Args
-	server    *grpc.Server
-	ready     chan bool
+	config    NFDConfig
+	ns        namespaces
+	ready     chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
 	apihelper apihelper.APIHelpers
+	auditLog  *auditLog
+	// clock is used for all time related operations, replaceable in tests
+	clock clock.Clock
+	// apiServerCheck checks API server connectivity, replaceable in tests
+	apiServerCheck func() error
+	// server is the gRPC server, guarded by serverMutex as Stop() may be
+	// called concurrently with Run()
+	server      *grpc.Server
+	serverMutex sync.Mutex
 }
 
 // statusOp is a json marshaling helper used for patching node status
@@ -99,9 +166,9 @@ type statusOp struct {
 	Value string `json:"value,omitempty"`
 }
 
-func createStatusOp(verb string, resource string, path string, value string) statusOp {
+func createStatusOp(verb string, resource string, path string, value string, labelNs string) statusOp {
 	if !strings.Contains(resource, "/") {
-		resource = LabelNs + resource
+		resource = labelNs + resource
 	}
 	res := strings.ReplaceAll(resource, "/", "~1")
 	return statusOp{verb, "/status/" + path + "/" + res, value}
@@ -109,7 +176,12 @@ func createStatusOp(verb string, resource string, path string, value string) sta
 
 // Create new NfdMaster server instance.
 func NewNfdMaster(args Args) (NfdMaster, error) {
-	nfd := &nfdMaster{args: args, ready: make(chan bool, 1)}
+	nfd := &nfdMaster{args: args,
+		ns:    namespaces{label: LabelNs, annotation: AnnotationNs},
+		ready: make(chan struct{}),
+		stop:  make(chan struct{}),
+		clock: clock.RealClock{}}
+	nfd.apiServerCheck = nfd.apiServerVersionCheck
 
 	// Check TLS related args
 	if args.CertFile != "" || args.KeyFile != "" || args.CaFile != "" {
@@ -124,6 +196,55 @@ func NewNfdMaster(args Args) (NfdMaster, error) {
 		}
 	}
 
+	// Set label and annotation namespaces
+	if args.LabelNs != "" {
+		if err := validateNs(args.LabelNs); err != nil {
+			return nfd, fmt.Errorf("invalid label namespace: %v", err)
+		}
+		nfd.ns.label = strings.TrimSuffix(args.LabelNs, "/") + "/"
+	}
+	if args.AnnotationNs != "" {
+		if err := validateNs(args.AnnotationNs); err != nil {
+			return nfd, fmt.Errorf("invalid annotation namespace: %v", err)
+		}
+		nfd.ns.annotation = strings.TrimSuffix(args.AnnotationNs, "/") + "/"
+	}
+
+	if args.StateNamespace != "" {
+		if errs := validation.IsDNS1123Label(args.StateNamespace); len(errs) > 0 {
+			return nfd, fmt.Errorf("invalid --state-namespace: %s", strings.Join(errs, "; "))
+		}
+	}
+
+	// Set compression level of replies. Registering the gzip compressor
+	// (by importing it) makes the server accept compressed requests.
+	if args.CompressionLevel != 0 {
+		if args.CompressionLevel < 1 || args.CompressionLevel > 9 {
+			return nfd, fmt.Errorf("invalid --compression-level %d, must be in range 1-9", args.CompressionLevel)
+		}
+		if err := gzip.SetLevel(args.CompressionLevel); err != nil {
+			return nfd, fmt.Errorf("failed to set compression level: %v", err)
+		}
+	}
+
+	// Check listener related args
+	if _, err := listenNetwork(args.ListenFamily, args.ListenAddress); err != nil {
+		return nfd, err
+	}
+
+	// Read configuration file
+	var err error
+	nfd.config, err = loadConfig(args.ConfigFile)
+	if err != nil {
+		return nfd, err
+	}
+
+	// Initialize audit logging
+	nfd.auditLog, err = newAuditLog(args.AuditLog, args.AuditWebhook, nfd.clock)
+	if err != nil {
+		return nfd, err
+	}
+
 	// Initialize Kubernetes API helpers
 	nfd.apihelper = apihelper.K8sHelpers{Kubeconfig: args.Kubeconfig}
 
@@ -135,27 +256,77 @@ func NewNfdMaster(args Args) (NfdMaster, error) {
 func (m *nfdMaster) Run() error {
 	stdoutLogger.Printf("Node Feature Discovery Master %s", version.Get())
 	stdoutLogger.Printf("NodeName: '%s'", nodeName)
+	stdoutLogger.Printf("Instance: '%s'", instanceName)
 
 	if m.args.Prune {
 		return m.prune()
 	}
+	if m.args.CheckConsistency {
+		return m.checkConsistency()
+	}
+
+	// Start serving the health endpoints first, readiness is reported once
+	// the gRPC server is up
+	if m.args.HealthPort > 0 {
+		go func() {
+			if err := m.runHealthServer(m.args.HealthPort); err != nil {
+				stderrLogger.Printf("health server failed: %v", err)
+			}
+		}()
+	}
+
+	// Serve node objects from a local cache
+	var nodeCache *apihelper.CachedK8sHelpers
+	if m.args.NodeCache && !m.args.NoPublish {
+		stdoutLogger.Printf("populating node cache...")
+		var err error
+		nodeCache, err = apihelper.NewCachedK8sHelpers(apihelper.K8sHelpers{Kubeconfig: m.args.Kubeconfig}, m.stop)
+		if err != nil {
+			return fmt.Errorf("failed to initialize node cache: %v", err)
+		}
+		m.apihelper = nodeCache
+	}
 
 	if !m.args.NoPublish {
-		err := updateMasterNode(m.apihelper)
+		err := updateMasterNode(m.apihelper, m.ns.annotation)
 		if err != nil {
 			return fmt.Errorf("failed to update master node: %v", err)
 		}
+		if m.args.ResyncInterval > 0 {
+			go m.resyncMasterNode()
+		}
+		if m.args.DeferResourceRemoval {
+			if err := checkPodListAccess(m.apihelper); err != nil {
+				return err
+			}
+		}
+	}
+
+	if m.args.MetricsPort > 0 {
+		go func() {
+			if err := runMetricsServer(m.args.MetricsPort); err != nil {
+				stderrLogger.Printf("metrics server failed: %v", err)
+			}
+		}()
+	}
+
+	if m.args.EnablePprof {
+		go func() {
+			if err := runPprofServer(m.args.PprofPort); err != nil {
+				stderrLogger.Printf("pprof server failed: %v", err)
+			}
+		}()
 	}
 
 	// Create server listening for TCP connections
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", m.args.Port))
+	network, err := listenNetwork(m.args.ListenFamily, m.args.ListenAddress)
+	if err != nil {
+		return err
+	}
+	lis, err := net.Listen(network, net.JoinHostPort(m.args.ListenAddress, strconv.Itoa(m.args.Port)))
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
-	// Notify that we're ready to accept connections
-	m.ready <- true
-	close(m.ready)
-
 	serverOpts := []grpc.ServerOption{}
 	// Enable mutual TLS authentication if --cert-file, --key-file or --ca-file
 	// is defined
@@ -182,30 +353,129 @@ func (m *nfdMaster) Run() error {
 		}
 		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
 	}
-	m.server = grpc.NewServer(serverOpts...)
-	pb.RegisterLabelerServer(m.server, &labelerServer{args: m.args, apiHelper: m.apihelper})
-	stdoutLogger.Printf("gRPC server serving on port: %d", m.args.Port)
-	return m.server.Serve(lis)
+	// Periodically recycle client connections so that workers get spread
+	// across all replicas of nfd-master
+	if m.args.MaxConnectionAge > 0 {
+		serverOpts = append(serverOpts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge: m.args.MaxConnectionAge,
+		}))
+	}
+	serverOpts = append(serverOpts,
+		grpc.UnaryInterceptor(chainUnaryInterceptors(
+			requestIDInterceptor,
+			newLoggingInterceptor(m.args.Verbosity, m.clock))),
+		grpc.StreamInterceptor(chainStreamInterceptors(
+			requestIDStreamInterceptor,
+			newLoggingStreamInterceptor(m.args.Verbosity, m.clock))))
+	server := grpc.NewServer(serverOpts...)
+	labeler := &labelerServer{args: m.args, config: m.config, ns: m.ns, apiHelper: m.apihelper, auditLog: m.auditLog,
+		clock: m.clock, published: newPublishedFeatures(), storedStates: newStoredStates(), stop: m.stop}
+	if m.args.DedupWindow > 0 {
+		labeler.updateCache = newUpdateCache(m.clock, m.args.DedupWindow)
+	}
+	if m.args.CoalesceWindow > 0 {
+		labeler.updateQueue = newUpdateQueue(m.clock, m.args.CoalesceWindow, labeler.applyNodeUpdate)
+	}
+	// Feature events of all nodes are generated from the node cache
+	if nodeCache != nil {
+		labeler.watchers = newFeatureWatchers()
+		nodeCache.AddNodeEventHandler(labeler.nodeEventHandler())
+	}
+	pb.RegisterLabelerServer(server, labeler)
+
+	// Do not start serving if Stop() was called in the meantime
+	m.serverMutex.Lock()
+	select {
+	case <-m.stop:
+		m.serverMutex.Unlock()
+		lis.Close()
+		return nil
+	default:
+	}
+	m.server = server
+	m.serverMutex.Unlock()
+
+	// Notify that we're ready to accept connections
+	close(m.ready)
+
+	stdoutLogger.Printf("gRPC server serving on %s", lis.Addr())
+	return server.Serve(lis)
+}
+
+// listenNetwork returns the network to listen on for the given address
+// family. An empty listen address binds to all addresses of the family.
+func listenNetwork(family string, address string) (string, error) {
+	var network string
+	switch family {
+	case "", "dual":
+		network = "tcp"
+	case "ipv4":
+		network = "tcp4"
+	case "ipv6":
+		network = "tcp6"
+	default:
+		return "", fmt.Errorf("invalid listen address family %q, must be one of dual, ipv4 or ipv6", family)
+	}
+
+	if address != "" {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return "", fmt.Errorf("invalid listen address %q, must be an IP address", address)
+		}
+		if (network == "tcp4" && ip.To4() == nil) || (network == "tcp6" && ip.To4() != nil) {
+			return "", fmt.Errorf("listen address %q does not match address family %q", address, family)
+		}
+	}
+	return network, nil
 }
 
-// Stop NfdMaster
+// Stop NfdMaster. In-flight requests are let to finish, but, the server is
+// forcibly stopped if this takes longer than the configured drain timeout. A
+// zero drain timeout waits for the requests indefinitely. Stop may be called
+// before or during Run, in which case Run returns without serving.
 func (m *nfdMaster) Stop() {
-	m.server.Stop()
+	m.serverMutex.Lock()
+	m.stopOnce.Do(func() { close(m.stop) })
+	server := m.server
+	m.serverMutex.Unlock()
+
+	if server == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	if m.args.DrainTimeout <= 0 {
+		<-stopped
+		return
+	}
+
+	select {
+	case <-stopped:
+	case <-m.clock.After(m.args.DrainTimeout):
+		stderrLogger.Printf("WARNING: gRPC server did not drain in %v, forcing stop", m.args.DrainTimeout)
+		server.Stop()
+	}
+}
+
+// Ready returns a channel that is closed when NfdMaster is able to accept
+// connections. Any number of consumers may wait on the channel.
+func (m *nfdMaster) Ready() <-chan struct{} {
+	return m.ready
 }
 
 // Wait until NfdMaster is able able to accept connections.
 func (m *nfdMaster) WaitForReady(timeout time.Duration) bool {
 	select {
-	case ready, ok := <-m.ready:
-		// Ready if the flag is true or the channel has been closed
-		if ready || !ok {
-			return true
-		}
-	case <-time.After(timeout):
+	case <-m.Ready():
+		return true
+	case <-m.clock.After(timeout):
 		return false
 	}
-	// We should never end-up here
-	return false
 }
 
 // Prune erases all NFD related properties from the node objects of the cluster.
@@ -220,36 +490,74 @@ func (m *nfdMaster) prune() error {
 		return err
 	}
 
-	for _, node := range nodes.Items {
-		stdoutLogger.Printf("pruning node %q...", node.Name)
+	// Extended resources are unconditionally removed when pruning
+	labeler := &labelerServer{args: m.args, config: m.config, ns: m.ns, apiHelper: m.apihelper, clock: m.clock}
+	labeler.args.DeferResourceRemoval = false
 
-		// Prune labels and extended resources
-		err := updateNodeFeatures(m.apihelper, node.Name, Labels{}, Annotations{}, ExtendedResources{})
-		if err != nil {
-			return fmt.Errorf("failed to prune labels from node %q: %v", node.Name, err)
-		}
+	nodeNames := make([]string, len(nodes.Items))
+	for i, node := range nodes.Items {
+		nodeNames[i] = node.Name
+	}
 
-		// Prune annotations
-		node, err := m.apihelper.GetNode(cli, node.Name)
-		if err != nil {
-			return err
-		}
-		for a := range node.Annotations {
-			if strings.HasPrefix(a, AnnotationNs) {
-				delete(node.Annotations, a)
-			}
+	return runBulk("prune", nodeNames, m.bulkOptions(), m.clock, func(nodeName string) error {
+		return m.pruneNode(cli, labeler, nodeName)
+	})
+}
+
+// pruneNode erases all NFD related properties from one node object
+func (m *nfdMaster) pruneNode(cli *k8sclient.Clientset, labeler *labelerServer, nodeName string) error {
+	stdoutLogger.Printf("pruning node %q...", nodeName)
+
+	// Prune labels and extended resources
+	changes, err := labeler.updateNodeFeatures(nodeName, "", Labels{}, Annotations{}, ExtendedResources{})
+	m.auditLog.record(nodeName, "nfd-master (prune)", changes)
+	if err != nil {
+		return fmt.Errorf("failed to prune labels from node %q: %v", nodeName, err)
+	}
+
+	// Prune annotations, and, labels of non-default owners
+	node, err := m.apihelper.GetNode(cli, nodeName)
+	if err != nil {
+		return err
+	}
+	for _, owner := range featureOwners(node, m.ns.annotation) {
+		removeFeatures(node, m.ns.annotation, owner)
+	}
+	for a := range node.Annotations {
+		if strings.HasPrefix(a, m.ns.annotation) {
+			delete(node.Annotations, a)
 		}
-		err = m.apihelper.UpdateNode(cli, node)
+	}
+	err = m.apihelper.UpdateNode(cli, node)
+	if err != nil {
+		return fmt.Errorf("failed to prune annotations from node %q: %v", nodeName, err)
+	}
+
+	// Prune published state
+	if m.args.StateNamespace != "" {
+		err = m.apihelper.DeleteConfigMap(cli, m.args.StateNamespace, nodeStateConfigMapName(nodeName))
 		if err != nil {
-			return fmt.Errorf("failed to prune annotations from node %q: %v", node.Name, err)
+			return fmt.Errorf("failed to prune state of node %q: %v", nodeName, err)
 		}
-
 	}
+
+	// The node is no longer managed by nfd-master
+	forgetNodeMetrics(nodeName)
+
 	return nil
 }
 
+// bulkOptions returns the options for operations done on all nodes
+func (m *nfdMaster) bulkOptions() bulkOptions {
+	return bulkOptions{
+		concurrency: m.args.BulkConcurrency,
+		nodeTimeout: m.args.BulkNodeTimeout,
+		timeout:     m.args.BulkTimeout,
+	}
+}
+
 // Advertise NFD master information
-func updateMasterNode(helper apihelper.APIHelpers) error {
+func updateMasterNode(helper apihelper.APIHelpers, annotationNs string) error {
 	cli, err := helper.GetClient()
 	if err != nil {
 		return err
@@ -259,8 +567,21 @@ func updateMasterNode(helper apihelper.APIHelpers) error {
 		return err
 	}
 
-	// Advertise NFD version as an annotation
-	addAnnotations(node, Annotations{"master.version": version.Get()})
+	// Advertise NFD version and the identity of the active master as
+	// annotations
+	annotations := Annotations{"master.version": version.Get(), "master.instance": instanceName}
+	upToDate := true
+	for k, v := range annotations {
+		if node.Annotations[annotationNs+k] != v {
+			upToDate = false
+			break
+		}
+	}
+	if upToDate {
+		return nil
+	}
+
+	addAnnotations(node, annotations, annotationNs)
 	err = helper.UpdateNode(cli, node)
 	if err != nil {
 		stderrLogger.Printf("can't update node: %s", err.Error())
@@ -270,29 +591,60 @@ func updateMasterNode(helper apihelper.APIHelpers) error {
 	return nil
 }
 
+// checkPodListAccess verifies that nfd-master is allowed to list pods, as
+// required by --defer-resource-removal. Failing early is preferred over
+// failing every node update later on.
+func checkPodListAccess(helper apihelper.APIHelpers) error {
+	cli, err := helper.GetClient()
+	if err != nil {
+		return err
+	}
+	_, err = helper.GetPods(cli, nodeName)
+	if errors.IsForbidden(err) {
+		return fmt.Errorf("--defer-resource-removal requires RBAC permission to list pods: %v", err)
+	}
+	return err
+}
+
+// resyncMasterNode periodically re-applies the master node annotations, e.g.
+// in case the node object has been re-created. The method returns when the
+// master is stopped.
+func (m *nfdMaster) resyncMasterNode() {
+	for {
+		select {
+		case <-m.clock.After(m.args.ResyncInterval):
+			if err := updateMasterNode(m.apihelper, m.ns.annotation); err != nil {
+				stderrLogger.Printf("failed to re-advertise master node annotations: %v", err)
+			}
+		case <-m.stop:
+			return
+		}
+	}
+}
+
 // Filter labels by namespace and name whitelist
-func filterFeatureLabels(labels Labels, extraLabelNs []string, labelWhiteList *regexp.Regexp, extendedResourceNames []string) (Labels, ExtendedResources) {
+func filterFeatureLabels(labels Labels, labelNs string, extraLabelNs []string, wl *whiteList, extendedResourceNames []string, rej *rejections) (Labels, ExtendedResources) {
 	for label := range labels {
 		split := strings.SplitN(label, "/", 2)
-		name := split[0]
+		ns, name := strings.TrimSuffix(labelNs, "/"), split[0]
 
 		// Check namespaced labels, filter out if ns is not whitelisted
 		if len(split) == 2 {
-			ns := split[0]
+			ns = split[0]
 			name = split[1]
 			for i, extraNs := range extraLabelNs {
 				if ns == extraNs {
 					break
 				} else if i == len(extraLabelNs)-1 {
-					stderrLogger.Printf("Namespace '%s' is not allowed. Ignoring label '%s'\n", ns, label)
+					rej.add(rejectedLabel, label, "namespace '%s' is not allowed", ns)
 					delete(labels, label)
 				}
 			}
 		}
 
 		// Skip if label doesn't match labelWhiteList
-		if !labelWhiteList.MatchString(name) {
-			stderrLogger.Printf("%s does not match the whitelist (%s) and will not be published.", name, labelWhiteList.String())
+		if _, ok := labels[label]; ok && !wl.matches(ns, name) {
+			rej.add(rejectedLabel, label, "%s does not match the whitelist (%s)", name, wl.patternsFor(ns))
 			delete(labels, label)
 		}
 	}
@@ -300,14 +652,19 @@ func filterFeatureLabels(labels Labels, extraLabelNs []string, labelWhiteList *r
 	// Remove labels which are intended to be extended resources
 	extendedResources := ExtendedResources{}
 	for _, extendedResourceName := range extendedResourceNames {
-		// remove possibly given default LabelNs to keep annotations shorter
-		extendedResourceName = strings.TrimPrefix(extendedResourceName, LabelNs)
+		// remove possibly given default labelNs to keep annotations shorter
+		extendedResourceName = strings.TrimPrefix(extendedResourceName, labelNs)
 		if _, ok := labels[extendedResourceName]; ok {
 			if _, err := strconv.Atoi(labels[extendedResourceName]); err != nil {
-				stderrLogger.Printf("bad label value encountered for extended resource: %s", err.Error())
+				rej.add(rejectedExtendedResource, extendedResourceName, "bad label value: %s", err.Error())
 				continue // non-numeric label can't be used
 			}
 
+			if err := validateExtendedResourceName(extendedResourceName, labelNs); err != nil {
+				rej.add(rejectedExtendedResource, extendedResourceName, "invalid extended resource name: %v", err)
+				continue
+			}
+
 			extendedResources[extendedResourceName] = labels[extendedResourceName]
 			delete(labels, extendedResourceName)
 		}
@@ -316,40 +673,237 @@ func filterFeatureLabels(labels Labels, extraLabelNs []string, labelWhiteList *r
 	return labels, extendedResources
 }
 
+// filterExtendedResources filters out extended resources with invalid names or
+// values, and resources in namespaces that are not allowed or not matching the
+// whitelist. Resources in the default namespace are returned without the
+// namespace prefix, similar to the ones created from labels.
+func filterExtendedResources(resources map[string]string, labelNs string, extraLabelNs []string, wl *whiteList, rej *rejections) ExtendedResources {
+	filtered := ExtendedResources{}
+	for name, value := range resources {
+		shortName := strings.TrimPrefix(name, labelNs)
+		split := strings.SplitN(shortName, "/", 2)
+		ns, base := strings.TrimSuffix(labelNs, "/"), split[0]
+
+		// Check namespace, filter out if ns is not whitelisted
+		if len(split) == 2 {
+			ns, base = split[0], split[1]
+			allowed := false
+			for _, extraNs := range extraLabelNs {
+				if ns == extraNs {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				rej.add(rejectedExtendedResource, name, "namespace '%s' is not allowed", ns)
+				continue
+			}
+		}
+
+		if err := validateExtendedResourceName(shortName, labelNs); err != nil {
+			rej.add(rejectedExtendedResource, name, "invalid extended resource name: %v", err)
+			continue
+		}
+		if _, err := strconv.Atoi(value); err != nil {
+			rej.add(rejectedExtendedResource, name, "bad value: %s", err.Error())
+			continue
+		}
+
+		// Skip if the resource doesn't match labelWhiteList
+		if !wl.matches(ns, base) {
+			rej.add(rejectedExtendedResource, name, "%s does not match the whitelist (%s)", base, wl.patternsFor(ns))
+			continue
+		}
+
+		filtered[shortName] = value
+	}
+	return filtered
+}
+
+// filterFeatureAnnotations filters out feature annotations in namespaces that
+// are not allowed and annotations not matching the whitelist. Annotations are
+// also dropped if their total size would exceed maxFeatureAnnotationsSize.
+// Non-namespaced annotations are put in the default feature namespace. The
+// returned annotations have fully qualified names.
+func filterFeatureAnnotations(annotations map[string]string, ns namespaces, extraAnnotationNs []string, wl *whiteList, rej *rejections) Annotations {
+	names := make([]string, 0, len(annotations))
+	for name := range annotations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	filtered := Annotations{}
+	size := 0
+	for _, name := range names {
+		fullName := addNs(name, ns.label)
+		split := strings.SplitN(fullName, "/", 2)
+		annotationNs := split[0]
+
+		// Check namespace, filter out if ns is not whitelisted
+		if annotationNs+"/" != ns.label {
+			allowed := false
+			for _, extraNs := range extraAnnotationNs {
+				if annotationNs == extraNs {
+					allowed = true
+					break
+				}
+			}
+			if !allowed || annotationNs+"/" == ns.annotation {
+				rej.add(rejectedAnnotation, name, "namespace '%s' is not allowed", annotationNs)
+				continue
+			}
+		}
+
+		if errs := validation.IsQualifiedName(fullName); len(errs) > 0 {
+			rej.add(rejectedAnnotation, name, "invalid name: %s", errs)
+			continue
+		}
+
+		// Skip if annotation doesn't match labelWhiteList
+		if !wl.matches(annotationNs, split[1]) {
+			rej.add(rejectedAnnotation, name, "%s does not match the whitelist (%s)", split[1], wl.patternsFor(annotationNs))
+			continue
+		}
+
+		value := annotations[name]
+		if size+len(fullName)+len(value) > maxFeatureAnnotationsSize {
+			rej.add(rejectedAnnotation, name, "total size of feature annotations would exceed %d bytes", maxFeatureAnnotationsSize)
+			continue
+		}
+		size += len(fullName) + len(value)
+
+		filtered[fullName] = value
+	}
+
+	return filtered
+}
+
+// validateExtendedResourceName checks that a (possibly non-namespaced)
+// feature name can be used as an extended resource name. Extended resources
+// must not be in the kubernetes.io domain, except for the default NFD
+// namespace.
+func validateExtendedResourceName(name string, labelNs string) error {
+	fullName := addNs(name, labelNs)
+	ns := strings.SplitN(fullName, "/", 2)[0]
+
+	if ns+"/" != labelNs && (ns == "kubernetes.io" || strings.HasSuffix(ns, ".kubernetes.io") ||
+		ns == "k8s.io" || strings.HasSuffix(ns, ".k8s.io")) {
+		return fmt.Errorf("namespace %q is reserved", ns)
+	}
+	// Kubernetes uses the resource name prefixed with "requests." as a
+	// resource quota name so it must be a valid qualified name, too
+	if errs := validation.IsQualifiedName("requests." + fullName); len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // Implement LabelerServer
 type labelerServer struct {
-	args      Args
-	apiHelper apihelper.APIHelpers
+	args         Args
+	config       NFDConfig
+	ns           namespaces
+	apiHelper    apihelper.APIHelpers
+	auditLog     *auditLog
+	clock        clock.Clock
+	updateCache  *updateCache
+	updateQueue  *updateQueue
+	published    *publishedFeatures
+	storedStates *storedStates
+	watchers     *featureWatchers
+	stop         <-chan struct{}
+}
+
+// authorizeNode checks that the client is authorized to access the given node
+func (s *labelerServer) authorizeNode(c context.Context, nodeName string) error {
+	if !s.args.VerifyNodeName {
+		return nil
+	}
+
+	// Client authorization.
+	// Check that the node name matches the CN from the TLS cert, or, one
+	// of the configured node name patterns
+	client, ok := peer.FromContext(c)
+	if !ok {
+		stderrLogger.Printf("gRPC request error: failed to get peer (client)")
+		return fmt.Errorf("failed to get peer (client)")
+	}
+	tlsAuth, ok := client.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		stderrLogger.Printf("gRPC request error: incorrect client credentials from '%v'", client.Addr)
+		return fmt.Errorf("incorrect client credentials")
+	}
+	if len(tlsAuth.State.VerifiedChains) == 0 || len(tlsAuth.State.VerifiedChains[0]) == 0 {
+		stderrLogger.Printf("gRPC request error: client certificate verification for '%v' failed", client.Addr)
+		return fmt.Errorf("client certificate verification failed")
+	}
+	cert := tlsAuth.State.VerifiedChains[0][0]
+	if !certMatchesNode(cert, nodeName, s.config.nodeNameRegexps) {
+		cn := cert.Subject.CommonName
+		stderrLogger.Printf("gRPC request error: authorization for %v failed: cert valid for '%s', requested node name '%s'", client.Addr, cn, nodeName)
+		return fmt.Errorf("request authorization failed: cert valid for '%s', requested node name '%s'", cn, nodeName)
+	}
+	return nil
 }
 
 // Service SetLabels
 func (s *labelerServer) SetLabels(c context.Context, r *pb.SetLabelsRequest) (*pb.SetLabelsReply, error) {
-	if s.args.VerifyNodeName {
-		// Client authorization.
-		// Check that the node name matches the CN from the TLS cert
-		client, ok := peer.FromContext(c)
-		if !ok {
-			stderrLogger.Printf("gRPC request error: failed to get peer (client)")
-			return &pb.SetLabelsReply{}, fmt.Errorf("failed to get peer (client)")
-		}
-		tlsAuth, ok := client.AuthInfo.(credentials.TLSInfo)
-		if !ok {
-			stderrLogger.Printf("gRPC request error: incorrect client credentials from '%v'", client.Addr)
-			return &pb.SetLabelsReply{}, fmt.Errorf("incorrect client credentials")
-		}
-		if len(tlsAuth.State.VerifiedChains) == 0 || len(tlsAuth.State.VerifiedChains[0]) == 0 {
-			stderrLogger.Printf("gRPC request error: client certificate verification for '%v' failed", client.Addr)
-			return &pb.SetLabelsReply{}, fmt.Errorf("client certificate verification failed")
+	if err := s.authorizeNode(c, r.NodeName); err != nil {
+		return &pb.SetLabelsReply{}, err
+	}
+	labels := applyLabelTransforms(r.Labels, s.config.LabelTransforms, s.ns.label)
+	applyLabelValueRules(labels, s.config.LabelValueRules)
+	applyDeprecatedLabels(labels, s.config.DeprecatedLabels)
+
+	if err := validateLabelValues(labels, s.config.LabelSchemas); err != nil {
+		stderrLogger.Printf("rejecting labels of node %q: %v", r.NodeName, err)
+		return &pb.SetLabelsReply{}, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	// Features that are not published are reported back to nfd-worker
+	rej := newRejections(r.NodeName)
+
+	// Enforce per-client namespace restrictions
+	cert := clientCertificate(c)
+	denied := deniedNamespaces(s.config.ClientNamespaces, cert)
+	filterDeniedNamespaces(labels, denied, rejectedLabel, rej)
+
+	// Extended resources are only managed for the default owner
+	owner := labelOwner(s.config.LabelOwners, cert)
+	resourceLabels := s.args.ResourceLabels
+	if owner != "" {
+		resourceLabels = nil
+	}
+
+	wl := s.config.whiteList(s.args.LabelWhiteList)
+	labels, extendedResources := filterFeatureLabels(labels, s.ns.label, s.args.ExtraLabelNs, wl, resourceLabels, rej)
+	featureAnnotations := filterFeatureAnnotations(r.Annotations, s.ns, s.args.ExtraAnnotationNs, wl, rej)
+	filterDeniedNamespaces(featureAnnotations, denied, rejectedAnnotation, rej)
+
+	if owner != "" {
+		for name := range r.ExtendedResources {
+			rej.add(rejectedExtendedResource, name, "extended resources are only managed for the default owner")
 		}
-		cn := tlsAuth.State.VerifiedChains[0][0].Subject.CommonName
-		if cn != r.NodeName {
-			stderrLogger.Printf("gRPC request error: authorization for %v failed: cert valid for '%s', requested node name '%s'", client.Addr, cn, r.NodeName)
-			return &pb.SetLabelsReply{}, fmt.Errorf("request authorization failed: cert valid for '%s', requested node name '%s'", cn, r.NodeName)
+	} else {
+		requestedResources := filterExtendedResources(r.ExtendedResources, s.ns.label, s.args.ExtraLabelNs, wl, rej)
+		filterDeniedNamespaces(requestedResources, denied, rejectedExtendedResource, rej)
+		for name, value := range requestedResources {
+			extendedResources[name] = value
 		}
 	}
-	stdoutLogger.Printf("REQUEST Node: %s NFD-version: %s Labels: %s", r.NodeName, r.NfdVersion, r.Labels)
 
-	labels, extendedResources := filterFeatureLabels(r.Labels, s.args.ExtraLabelNs, s.args.LabelWhiteList, s.args.ResourceLabels)
+	// Taints are only managed for the default owner
+	var taints []api.Taint
+	for _, t := range r.Taints {
+		if !s.args.EnableTaints {
+			rej.add(rejectedTaint, taintName(t), "taints are not enabled")
+		} else if owner != "" {
+			rej.add(rejectedTaint, taintName(t), "taints are only managed for the default owner")
+		}
+	}
+	if s.args.EnableTaints && owner == "" {
+		taints = filterTaints(r.Taints, s.ns.label, s.args.ExtraLabelNs, denied, rej)
+	}
 
 	if !s.args.NoPublish {
 		// Advertise NFD worker version, label names and extended resources as annotations
@@ -365,71 +919,462 @@ func (s *labelerServer) SetLabels(c context.Context, r *pb.SetLabelsRequest) (*p
 		}
 		sort.Strings(extendedResourceKeys)
 
-		annotations := Annotations{"worker.version": r.NfdVersion,
-			"feature-labels":     strings.Join(labelKeys, ","),
-			"extended-resources": strings.Join(extendedResourceKeys, ","),
+		annotations := Annotations{ownerAnnotation("feature-labels", owner): strings.Join(labelKeys, ",")}
+		if owner == "" {
+			annotations["worker.version"] = r.NfdVersion
+			annotations["extended-resources"] = strings.Join(extendedResourceKeys, ",")
+			if len(taints) > 0 {
+				annotations["taints"] = formatTaints(taints)
+			}
+		}
+		// Record non-default label namespace for removing the labels later on
+		if s.ns.label != LabelNs {
+			annotations[ownerAnnotation("label-ns", owner)] = s.ns.label
+		}
+
+		// Feature annotations are advertised with their fully qualified
+		// names, alongside the NFD-related annotations
+		if len(featureAnnotations) > 0 {
+			featureAnnotationKeys := make([]string, 0, len(featureAnnotations))
+			for k, v := range featureAnnotations {
+				featureAnnotationKeys = append(featureAnnotationKeys, k)
+				annotations[k] = v
+			}
+			sort.Strings(featureAnnotationKeys)
+			annotations[ownerAnnotation("feature-annotations", owner)] = strings.Join(featureAnnotationKeys, ",")
 		}
 
-		err := updateNodeFeatures(s.apiHelper, r.NodeName, labels, annotations, extendedResources)
+		if s.updateCache.isDuplicate(r.NodeName, labels, annotations, extendedResources) && s.featuresIntact(r.NodeName) {
+			stdoutLogger.Printf("node %q already up-to-date, skipping update", r.NodeName)
+			return &pb.SetLabelsReply{Rejected: rej.list()}, nil
+		}
+
+		u := nodeUpdate{requesterIdentity(c), owner, labels, annotations, extendedResources}
+		var err error
+		if s.updateQueue != nil {
+			err = s.updateQueue.update(r.NodeName, u)
+		} else {
+			err = s.applyNodeUpdate(r.NodeName, u)
+		}
 		if err != nil {
-			stderrLogger.Printf("failed to advertise labels: %s", err.Error())
 			return &pb.SetLabelsReply{}, err
 		}
 	}
-	return &pb.SetLabelsReply{}, nil
+	return &pb.SetLabelsReply{Rejected: rej.list()}, nil
+}
+
+// Service GetLabels
+func (s *labelerServer) GetLabels(c context.Context, r *pb.GetLabelsRequest) (*pb.GetLabelsReply, error) {
+	if err := s.authorizeNode(c, r.NodeName); err != nil {
+		return &pb.GetLabelsReply{}, err
+	}
+	if s.args.NoPublish {
+		return &pb.GetLabelsReply{}, status.Errorf(codes.FailedPrecondition, "node features are not available with --no-publish")
+	}
+
+	cli, err := s.apiHelper.GetClient()
+	if err != nil {
+		return &pb.GetLabelsReply{}, err
+	}
+	node, err := s.apiHelper.GetNode(cli, r.NodeName)
+	if err != nil {
+		return &pb.GetLabelsReply{}, err
+	}
+
+	return nodeFeatures(node, s.ns.annotation), nil
+}
+
+// Service GetCapabilities
+func (s *labelerServer) GetCapabilities(c context.Context, r *pb.GetCapabilitiesRequest) (*pb.GetCapabilitiesReply, error) {
+	caps := []string{
+		pb.CapabilityFeatureAnnotations,
+		pb.CapabilityRejections,
+		pb.CapabilityGetLabels,
+		pb.CapabilityExtendedResources,
+	}
+	// Taints are rejected unless enabled, so workers should not send them
+	if s.args.EnableTaints {
+		caps = append(caps, pb.CapabilityTaints)
+	}
+	// Watching is only supported if node events are available
+	if s.watchers != nil {
+		caps = append(caps, pb.CapabilityWatchFeatures)
+	}
+	return &pb.GetCapabilitiesReply{NfdVersion: version.Get(), Capabilities: caps}, nil
+}
+
+// splitList splits a comma-separated list annotation, an empty value being
+// an empty list
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// recordedLabelNs returns the label namespace recorded for an owner in the
+// annotations of a node, defaulting to LabelNs
+func recordedLabelNs(n *api.Node, annotationNs, owner string) string {
+	if v, ok := n.Annotations[annotationNs+ownerAnnotation("label-ns", owner)]; ok {
+		return v
+	}
+	return LabelNs
+}
+
+// nodeFeatures returns the feature labels, feature annotations and extended
+// resources of all owners, as recorded in the NFD-related annotations (in the
+// given annotation namespace) of a node object. Non-namespaced names are
+// resolved with the label namespace recorded for the owner. All names are
+// fully qualified.
+func nodeFeatures(n *api.Node, annotationNs string) *pb.GetLabelsReply {
+	reply := &pb.GetLabelsReply{
+		Labels:            map[string]string{},
+		Annotations:       map[string]string{},
+		ExtendedResources: map[string]string{},
+	}
+
+	for _, owner := range append([]string{""}, featureOwners(n, annotationNs)...) {
+		ns := recordedLabelNs(n, annotationNs, owner)
+		for _, l := range splitList(n.Annotations[annotationNs+ownerAnnotation("feature-labels", owner)]) {
+			name := addNs(l, ns)
+			if v, ok := n.Labels[name]; ok {
+				reply.Labels[name] = v
+			}
+		}
+		for _, a := range splitList(n.Annotations[annotationNs+ownerAnnotation("feature-annotations", owner)]) {
+			if v, ok := n.Annotations[a]; ok {
+				reply.Annotations[a] = v
+			}
+		}
+	}
+
+	// Extended resources are only managed for the default owner
+	for _, r := range splitList(n.Annotations[annotationNs+"extended-resources"]) {
+		name := addNs(r, recordedLabelNs(n, annotationNs, ""))
+		if q, ok := n.Status.Capacity[api.ResourceName(name)]; ok {
+			reply.ExtendedResources[name] = q.String()
+		}
+	}
+
+	return reply
+}
+
+// applyNodeUpdate updates the node object and records the changes made
+func (s *labelerServer) applyNodeUpdate(nodeName string, u nodeUpdate) error {
+	changes, err := s.updateNodeFeatures(nodeName, u.owner, u.labels, u.annotations, u.extendedResources)
+	s.auditLog.record(nodeName, u.requester, changes)
+	if err != nil {
+		return err
+	}
+	if s.args.Verbosity >= 1 && !changes.empty() {
+		stdoutLogger.Printf("updated node %q: %s", nodeName, changes)
+	}
+	if s.args.StateNamespace != "" {
+		s.storeNodeState(nodeName, u)
+	}
+	s.updateCache.store(nodeName, u.labels, u.annotations, u.extendedResources)
+	return nil
 }
 
 // updateNodeFeatures ensures the Kubernetes node object is up to date,
 // creating new labels and extended resources where necessary and removing
-// outdated ones. Also updates the corresponding annotations.
-func updateNodeFeatures(helper apihelper.APIHelpers, nodeName string, labels Labels, annotations Annotations, extendedResources ExtendedResources) error {
+// outdated ones. Also updates the corresponding annotations. The changes
+// made are returned.
+func (s *labelerServer) updateNodeFeatures(nodeName, owner string, labels Labels, annotations Annotations, extendedResources ExtendedResources) (nodeChanges, error) {
+	helper := s.apiHelper
 	cli, err := helper.GetClient()
 	if err != nil {
-		return err
+		return nodeChanges{}, err
 	}
 
 	// Get the worker node object
 	node, err := helper.GetNode(cli, nodeName)
 	if err != nil {
-		return err
+		if errors.IsNotFound(err) {
+			forgetNodeMetrics(nodeName)
+		}
+		return nodeChanges{}, err
 	}
 
-	// Resolve publishable extended resources before node is modified
-	statusOps := getExtendedResourceOps(node, extendedResources)
+	// Extended resources are only managed for the default owner
+	var statusOps []statusOp
+	if owner == "" {
+		if s.args.DeferResourceRemoval {
+			err = s.deferExtendedResourceRemoval(cli, node, extendedResources, annotations)
+			if err != nil {
+				return nodeChanges{}, err
+			}
+		}
+
+		// Resolve publishable extended resources before node is modified
+		statusOps = getExtendedResourceOps(node, extendedResources, s.ns)
+	}
+
+	origLabels := make(map[string]string, len(node.Labels))
+	for k, v := range node.Labels {
+		origLabels[k] = v
+	}
+	origAnnotations := make(map[string]string, len(node.Annotations))
+	for k, v := range node.Annotations {
+		origAnnotations[k] = v
+	}
+
+	// The state kept for the node is stale if it was recorded for another
+	// node object with the same name. The UID recorded in the annotations
+	// catches objects restored from a backup, the one kept in memory objects
+	// re-created from scratch. Drop the features of all owners in that case,
+	// forcing a full re-publish.
+	if oldUID, ok := s.previousNodeUID(node); ok && oldUID != node.UID {
+		stderrLogger.Printf("node %q has been re-created (uid %s, was %s), removing stale features", nodeName, node.UID, oldUID)
+		for _, o := range append([]string{""}, featureOwners(node, s.ns.annotation)...) {
+			removeFeatures(node, s.ns.annotation, o)
+			delete(node.Annotations, s.ns.annotation+ownerAnnotation("feature-labels", o))
+		}
+		s.forgetNode(nodeName)
+	}
+
+	// Remove old labels and feature annotations of the owner
+	removeFeatures(node, s.ns.annotation, owner)
+
+	// Taints are only managed for the default owner
+	origTaints := node.Spec.Taints
+	var oldTaints []api.Taint
+	if owner == "" {
+		oldTaints = parseTaints(node.Annotations[s.ns.annotation+"taints"])
+		delete(node.Annotations, s.ns.annotation+"taints")
+	}
 
-	// Remove old labels
-	if l, ok := node.Annotations[AnnotationNs+"feature-labels"]; ok {
-		oldLabels := strings.Split(l, ",")
-		removeLabels(node, oldLabels)
+	// Migrate from the default annotation namespace if another one is used
+	if s.ns.annotation != AnnotationNs {
+		removeFeatures(node, AnnotationNs, owner)
+		for a := range node.Annotations {
+			if strings.HasPrefix(a, AnnotationNs) {
+				delete(node.Annotations, a)
+			}
+		}
 	}
 
-	// Also, remove all labels with the old prefix, and the old version label
-	removeLabelsWithPrefix(node, "node.alpha.kubernetes-incubator.io/nfd")
-	removeLabelsWithPrefix(node, "node.alpha.kubernetes-incubator.io/node-feature-discovery")
+	// Also, remove all labels with legacy prefixes, e.g. the old version label
+	for _, prefix := range s.config.legacyLabelPrefixes() {
+		removeLabelsWithPrefix(node, prefix)
+	}
 
 	// Add labels to the node object.
-	addLabels(node, labels)
+	addLabels(node, labels, s.ns.label)
 
 	// Add annotations
-	addAnnotations(node, annotations)
+	addAnnotations(node, annotations, s.ns.annotation)
+	node.Annotations[s.ns.annotation+"node-uid"] = string(node.UID)
 
-	// Send the updated node to the apiserver.
-	err = helper.UpdateNode(cli, node)
-	if err != nil {
-		stderrLogger.Printf("can't update node: %s", err.Error())
-		return err
+	// Apply the taints recorded in the annotations
+	if owner == "" {
+		updateTaints(node, oldTaints, parseTaints(node.Annotations[s.ns.annotation+"taints"]))
+	}
+
+	// Send the updated node to the apiserver, unless nothing was changed
+	changes := nodeChanges{
+		labels:    diffLabels(origLabels, node.Labels),
+		oldLabels: origLabels,
+		newLabels: node.Labels,
+		taints:    diffTaints(origTaints, node.Spec.Taints),
+		statusOps: statusOps,
+	}
+	if changes.labels.empty() && reflect.DeepEqual(origAnnotations, node.Annotations) && reflect.DeepEqual(origTaints, node.Spec.Taints) {
+		stdoutLogger.Printf("no changes in labels, annotations or taints of node %q", nodeName)
+	} else {
+		err = helper.UpdateNode(cli, node)
+		if err != nil {
+			stderrLogger.Printf("can't update node: %s", err.Error())
+			nodeUpdateFailures.WithLabelValues(updateFailureReason(err)).Inc()
+			return nodeChanges{}, err
+		}
 	}
 
+	labelsAdded.Add(float64(len(changes.labels.added)))
+	labelsRemoved.Add(float64(len(changes.labels.removed)))
+	labelsChanged.Add(float64(len(changes.labels.changed)))
+
 	// patch node status with extended resource changes
 	if len(statusOps) > 0 {
 		err = helper.PatchStatus(cli, node.Name, statusOps)
 		if err != nil {
 			stderrLogger.Printf("error while patching extended resources: %s", err.Error())
-			return err
+			extendedResourcePatchFailures.Inc()
+			// Node object itself was updated successfully
+			changes.statusOps = nil
+			return changes, err
 		}
 	}
 
-	return err
+	// Only record the published state once it has been fully applied, so that
+	// node events are compared against what is actually on the node
+	s.published.store(nodeName, node.UID, watchedFeatures(node, s.ns.annotation))
+
+	nodeLastSuccessfulUpdate.WithLabelValues(nodeName).SetToCurrentTime()
+
+	return changes, nil
+}
+
+// previousNodeUID returns the UID of the node object nfd-master last updated.
+// The UID recorded in the annotations of the node takes precedence over the
+// one kept in memory.
+func (s *labelerServer) previousNodeUID(node *api.Node) (types.UID, bool) {
+	if uid, ok := node.Annotations[s.ns.annotation+"node-uid"]; ok {
+		return types.UID(uid), true
+	}
+	return s.published.uid(node.Name)
+}
+
+// deferExtendedResourceRemoval keeps extended resources that are going to be
+// removed but are still requested by pods running on the node. The deferred
+// resources are kept in the given set of extended resources (and the
+// corresponding annotation) with their current capacity so that their
+// removal is retried on the next update.
+func (s *labelerServer) deferExtendedResourceRemoval(cli *k8sclient.Clientset, node *api.Node, extendedResources ExtendedResources, annotations Annotations) error {
+	var toBeRemoved []string
+	for _, resource := range strings.Split(node.Annotations[s.ns.annotation+"extended-resources"], ",") {
+		if _, ok := node.Status.Capacity[api.ResourceName(addNs(resource, s.ns.label))]; ok {
+			if _, ok := extendedResources[resource]; !ok {
+				toBeRemoved = append(toBeRemoved, resource)
+			}
+		}
+	}
+	if len(toBeRemoved) == 0 {
+		return nil
+	}
+
+	pods, err := s.apiHelper.GetPods(cli, node.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get pods of node %q: %v", node.Name, err)
+	}
+
+	deferred := false
+	for _, resource := range toBeRemoved {
+		name := api.ResourceName(addNs(resource, s.ns.label))
+		if !resourceInUse(pods, name) {
+			continue
+		}
+		quantity := node.Status.Capacity[name]
+		val, _ := quantity.AsInt64()
+		stderrLogger.Printf("WARNING: extended resource %q is still in use on node %q, deferring its removal", name, node.Name)
+		deferredResourceRemovals.Inc()
+		extendedResources[resource] = strconv.FormatInt(val, 10)
+		deferred = true
+	}
+
+	if deferred {
+		if _, ok := annotations["extended-resources"]; ok {
+			keys := make([]string, 0, len(extendedResources))
+			for key := range extendedResources {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			annotations["extended-resources"] = strings.Join(keys, ",")
+		}
+	}
+	return nil
+}
+
+// resourceInUse returns true if any of the active pods requests the resource
+func resourceInUse(pods *api.PodList, name api.ResourceName) bool {
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == api.PodSucceeded || pod.Status.Phase == api.PodFailed {
+			continue
+		}
+		containers := append(pod.Spec.InitContainers, pod.Spec.Containers...)
+		for _, c := range containers {
+			if _, ok := c.Resources.Requests[name]; ok {
+				return true
+			}
+			if _, ok := c.Resources.Limits[name]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeChanges describes the modifications made to a node object
+type nodeChanges struct {
+	labels    labelChanges
+	oldLabels map[string]string
+	newLabels map[string]string
+	taints    taintChanges
+	statusOps []statusOp
+}
+
+// empty returns true if no changes were made
+func (c nodeChanges) empty() bool {
+	return c.labels.empty() && c.taints.empty() && len(c.statusOps) == 0
+}
+
+// labelChanges contains the names of added, removed and changed labels
+type labelChanges struct {
+	added   []string
+	removed []string
+	changed []string
+}
+
+// empty returns true if there are no changes
+func (c labelChanges) empty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0 && len(c.changed) == 0
+}
+
+// String returns a human readable summary of the label changes, including
+// the label values
+func (c nodeChanges) String() string {
+	var parts []string
+	if len(c.labels.added) > 0 {
+		s := make([]string, len(c.labels.added))
+		for i, l := range c.labels.added {
+			s[i] = l + "=" + c.newLabels[l]
+		}
+		parts = append(parts, "added: "+strings.Join(s, ", "))
+	}
+	if len(c.labels.removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(c.labels.removed, ", "))
+	}
+	if len(c.labels.changed) > 0 {
+		s := make([]string, len(c.labels.changed))
+		for i, l := range c.labels.changed {
+			s[i] = fmt.Sprintf("%s=%s (was %s)", l, c.newLabels[l], c.oldLabels[l])
+		}
+		parts = append(parts, "changed: "+strings.Join(s, ", "))
+	}
+	if len(c.taints.added) > 0 {
+		parts = append(parts, "taints added: "+strings.Join(c.taints.added, ", "))
+	}
+	if len(c.taints.removed) > 0 {
+		parts = append(parts, "taints removed: "+strings.Join(c.taints.removed, ", "))
+	}
+	if len(c.statusOps) > 0 {
+		parts = append(parts, fmt.Sprintf("%d extended resource operation(s)", len(c.statusOps)))
+	}
+	if len(parts) == 0 {
+		return "no label changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// diffLabels compares two sets of labels
+func diffLabels(oldLabels, newLabels map[string]string) labelChanges {
+	changes := labelChanges{}
+	for k, v := range newLabels {
+		if oldValue, ok := oldLabels[k]; !ok {
+			changes.added = append(changes.added, k)
+		} else if oldValue != v {
+			changes.changed = append(changes.changed, k)
+		}
+	}
+	for k := range oldLabels {
+		if _, ok := newLabels[k]; !ok {
+			changes.removed = append(changes.removed, k)
+		}
+	}
+	sort.Strings(changes.added)
+	sort.Strings(changes.removed)
+	sort.Strings(changes.changed)
+	return changes
 }
 
 // Remove any labels having the given prefix
@@ -441,31 +1386,55 @@ func removeLabelsWithPrefix(n *api.Node, search string) {
 	}
 }
 
-// Removes NFD labels from a Node object
-func removeLabels(n *api.Node, labelNames []string) {
+// Removes NFD labels from a Node object. Non-namespaced labels are looked up
+// from the given namespace.
+func removeLabels(n *api.Node, labelNames []string, ns string) {
 	for _, l := range labelNames {
 		if strings.Contains(l, "/") {
 			delete(n.Labels, l)
 		} else {
-			delete(n.Labels, LabelNs+l)
+			delete(n.Labels, ns+l)
 		}
 	}
 }
 
+// removeFeatures removes the feature labels and annotations listed in the
+// NFD-related annotations (in the given annotation namespace) of one owner
+// of a Node object, together with the bookkeeping annotations themselves. The
+// label namespace the labels were created in is recorded in the "label-ns"
+// annotation. The annotations of non-default owners are suffixed with the
+// owner name.
+func removeFeatures(n *api.Node, annotationNs, owner string) {
+	ns := LabelNs
+	if v, ok := n.Annotations[annotationNs+ownerAnnotation("label-ns", owner)]; ok {
+		ns = v
+		delete(n.Annotations, annotationNs+ownerAnnotation("label-ns", owner))
+	}
+	if l, ok := n.Annotations[annotationNs+ownerAnnotation("feature-labels", owner)]; ok {
+		removeLabels(n, strings.Split(l, ","), ns)
+	}
+	if a, ok := n.Annotations[annotationNs+ownerAnnotation("feature-annotations", owner)]; ok {
+		for _, name := range strings.Split(a, ",") {
+			delete(n.Annotations, name)
+		}
+		delete(n.Annotations, annotationNs+ownerAnnotation("feature-annotations", owner))
+	}
+}
+
 // getExtendedResourceOps returns a slice of operations to perform on the node status
-func getExtendedResourceOps(n *api.Node, extendedResources ExtendedResources) []statusOp {
+func getExtendedResourceOps(n *api.Node, extendedResources ExtendedResources, ns namespaces) []statusOp {
 	var statusOps []statusOp
 
-	oldResources := strings.Split(n.Annotations[AnnotationNs+"extended-resources"], ",")
+	oldResources := strings.Split(n.Annotations[ns.annotation+"extended-resources"], ",")
 
 	// figure out which resources to remove
 	for _, resource := range oldResources {
-		if _, ok := n.Status.Capacity[api.ResourceName(addNs(resource, LabelNs))]; ok {
+		if _, ok := n.Status.Capacity[api.ResourceName(addNs(resource, ns.label))]; ok {
 			// check if the ext resource is still needed
 			_, extResNeeded := extendedResources[resource]
 			if !extResNeeded {
-				statusOps = append(statusOps, createStatusOp("remove", resource, "capacity", ""))
-				statusOps = append(statusOps, createStatusOp("remove", resource, "allocatable", ""))
+				statusOps = append(statusOps, createStatusOp("remove", resource, "capacity", "", ns.label))
+				statusOps = append(statusOps, createStatusOp("remove", resource, "allocatable", "", ns.label))
 			}
 		}
 	}
@@ -473,14 +1442,14 @@ func getExtendedResourceOps(n *api.Node, extendedResources ExtendedResources) []
 	// figure out which resources to replace and which to add
 	for resource, value := range extendedResources {
 		// check if the extended resource already exists with the same capacity in the node
-		if quantity, ok := n.Status.Capacity[api.ResourceName(addNs(resource, LabelNs))]; ok {
+		if quantity, ok := n.Status.Capacity[api.ResourceName(addNs(resource, ns.label))]; ok {
 			val, _ := quantity.AsInt64()
 			if strconv.FormatInt(val, 10) != value {
-				statusOps = append(statusOps, createStatusOp("replace", resource, "capacity", value))
-				statusOps = append(statusOps, createStatusOp("replace", resource, "allocatable", value))
+				statusOps = append(statusOps, createStatusOp("replace", resource, "capacity", value, ns.label))
+				statusOps = append(statusOps, createStatusOp("replace", resource, "allocatable", value, ns.label))
 			}
 		} else {
-			statusOps = append(statusOps, createStatusOp("add", resource, "capacity", value))
+			statusOps = append(statusOps, createStatusOp("add", resource, "capacity", value, ns.label))
 			// "allocatable" gets added implicitly after adding to capacity
 		}
 	}
@@ -488,24 +1457,38 @@ func getExtendedResourceOps(n *api.Node, extendedResources ExtendedResources) []
 	return statusOps
 }
 
-// Add NFD labels to a Node object.
-func addLabels(n *api.Node, labels map[string]string) {
+// Add NFD labels to a Node object. Non-namespaced labels are put in the given
+// label namespace.
+func addLabels(n *api.Node, labels map[string]string, labelNs string) {
 	for k, v := range labels {
 		if strings.Contains(k, "/") {
 			n.Labels[k] = v
 		} else {
-			n.Labels[LabelNs+k] = v
+			n.Labels[labelNs+k] = v
 		}
 	}
 }
 
-// Add Annotations to a Node object
-func addAnnotations(n *api.Node, annotations map[string]string) {
+// Add Annotations to a Node object. Non-namespaced annotations are put in
+// the given NFD annotation namespace.
+func addAnnotations(n *api.Node, annotations map[string]string, annotationNs string) {
 	for k, v := range annotations {
-		n.Annotations[AnnotationNs+k] = v
+		if strings.Contains(k, "/") {
+			n.Annotations[k] = v
+		} else {
+			n.Annotations[annotationNs+k] = v
+		}
 	}
 }
 
+// validateNs checks that a string is valid as a label or annotation namespace
+func validateNs(ns string) error {
+	if errs := validation.IsDNS1123Subdomain(strings.TrimSuffix(ns, "/")); len(errs) > 0 {
+		return fmt.Errorf("%q: %s", ns, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
 // addNs adds a namespace if one isn't already found from src string
 func addNs(src string, nsToAdd string) string {
 	if strings.Contains(src, "/") {
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"crypto/x509"
+	"sort"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+)
+
+// maxOwnerNameLength limits the length of owner names so that the owner
+// specific annotation names stay within the 63 character limit
+const maxOwnerNameLength = 40
+
+// Names of the NFD-related annotations that are tracked per owner
+var ownedAnnotations = []string{"feature-labels", "feature-annotations", "label-ns"}
+
+// matches returns true if the client certificate matches the rule
+func (r *LabelOwnerRule) matches(cert *x509.Certificate) bool {
+	return certMatches(cert, r.OrganizationalUnit, r.URI)
+}
+
+// labelOwner returns the owner of the features published by a client. The
+// empty string denotes the default owner, i.e. nfd-worker.
+func labelOwner(rules []LabelOwnerRule, cert *x509.Certificate) string {
+	for i := range rules {
+		if rules[i].matches(cert) {
+			return rules[i].Name
+		}
+	}
+	return ""
+}
+
+// ownerAnnotation returns the name of an NFD-related annotation of an owner
+func ownerAnnotation(name, owner string) string {
+	if owner == "" {
+		return name
+	}
+	return name + "." + owner
+}
+
+// featureOwners returns the non-default owners that have features recorded
+// in the annotations of a node
+func featureOwners(n *api.Node, annotationNs string) []string {
+	owners := map[string]bool{}
+	for a := range n.Annotations {
+		for _, name := range ownedAnnotations {
+			if prefix := annotationNs + name + "."; strings.HasPrefix(a, prefix) {
+				owners[strings.TrimPrefix(a, prefix)] = true
+			}
+		}
+	}
+
+	ret := make([]string, 0, len(owners))
+	for o := range owners {
+		ret = append(ret, o)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"sort"
+
+	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
+)
+
+// Kinds of rejected features
+const (
+	rejectedLabel            = "label"
+	rejectedAnnotation       = "annotation"
+	rejectedExtendedResource = "extended-resource"
+	rejectedTaint            = "taint"
+)
+
+// rejections collects the features of a labeling request that are not
+// published, for reporting them back to nfd-worker
+type rejections struct {
+	nodeName string
+	items    []*pb.Rejection
+}
+
+func newRejections(nodeName string) *rejections {
+	return &rejections{nodeName: nodeName}
+}
+
+// add logs and records a rejected feature. A nil receiver only logs.
+func (r *rejections) add(kind, name, format string, args ...interface{}) {
+	reason := fmt.Sprintf(format, args...)
+	if r == nil {
+		stderrLogger.Printf("Ignoring %s '%s': %s", kind, name, reason)
+		return
+	}
+	stderrLogger.Printf("Ignoring %s '%s' of node %q: %s", kind, name, r.nodeName, reason)
+	r.items = append(r.items, &pb.Rejection{Kind: kind, Name: name, Reason: reason})
+}
+
+// list returns the recorded rejections, sorted by kind and name
+func (r *rejections) list() []*pb.Rejection {
+	sort.Slice(r.items, func(i, j int) bool {
+		if r.items[i].Kind != r.items[j].Kind {
+			return r.items[i].Kind < r.items[j].Kind
+		}
+		return r.items[i].Name < r.items[j].Name
+	})
+	return r.items
+}
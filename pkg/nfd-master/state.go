@@ -0,0 +1,153 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Prefix of the names of the ConfigMaps holding the published state of nodes
+const nodeStateConfigMapPrefix = "nfd-node-state-"
+
+// Number of hex digits of the node name hash used in over-long ConfigMap names
+const nodeStateHashLen = 16
+
+// nodeState is the last accepted feature set of a node, stored for debugging
+// purposes
+type nodeState struct {
+	Timestamp         string            `json:"timestamp"`
+	WorkerVersion     string            `json:"workerVersion,omitempty"`
+	Requester         string            `json:"requester"`
+	LabelNs           string            `json:"labelNs"`
+	Labels            Labels            `json:"labels"`
+	Annotations       Annotations       `json:"annotations,omitempty"`
+	ExtendedResources ExtendedResources `json:"extendedResources,omitempty"`
+}
+
+// storedStates keeps a digest of the node states last written, in order to
+// skip writes that would not change anything
+type storedStates struct {
+	sync.Mutex
+	digests map[string]map[string][sha256.Size]byte
+}
+
+func newStoredStates() *storedStates {
+	return &storedStates{digests: make(map[string]map[string][sha256.Size]byte)}
+}
+
+// changed returns true if the state of the owner differs from the one last
+// stored for the node
+func (c *storedStates) changed(nodeName, owner string, digest [sha256.Size]byte) bool {
+	if c == nil {
+		return true
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	d, ok := c.digests[nodeName][owner]
+	return !ok || d != digest
+}
+
+// store records the digest of the state stored for an owner
+func (c *storedStates) store(nodeName, owner string, digest [sha256.Size]byte) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	if c.digests[nodeName] == nil {
+		c.digests[nodeName] = make(map[string][sha256.Size]byte)
+	}
+	c.digests[nodeName][owner] = digest
+}
+
+// forget drops the digests of a node
+func (c *storedStates) forget(nodeName string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	delete(c.digests, nodeName)
+}
+
+// nodeStateConfigMapName returns the name of the ConfigMap holding the
+// published state of a node. Names exceeding the maximum length of an object
+// name are truncated and made unique with a hash of the node name.
+func nodeStateConfigMapName(nodeName string) string {
+	name := nodeStateConfigMapPrefix + nodeName
+	if len(name) <= validation.DNS1123SubdomainMaxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(nodeName))
+	name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-nodeStateHashLen-1], ".-")
+	return name + "-" + hex.EncodeToString(sum[:])[:nodeStateHashLen]
+}
+
+// storeNodeState writes the published state of a node into a ConfigMap in
+// the namespace given with Args.StateNamespace. The state of each owner is
+// stored under a separate key. The write is skipped if the state (ignoring
+// the timestamp) is unchanged since the last write. Failures are only logged.
+func (s *labelerServer) storeNodeState(nodeName string, u nodeUpdate) {
+	state := nodeState{
+		WorkerVersion:     u.annotations["worker.version"],
+		Requester:         u.requester,
+		LabelNs:           s.ns.label,
+		Labels:            u.labels,
+		Annotations:       u.annotations,
+		ExtendedResources: u.extendedResources,
+	}
+	// Map keys are sorted by encoding/json so the digest is deterministic
+	data, err := json.Marshal(state)
+	if err != nil {
+		stderrLogger.Printf("failed to serialize state of node %q: %v", nodeName, err)
+		return
+	}
+	digest := sha256.Sum256(data)
+	if !s.storedStates.changed(nodeName, u.owner, digest) {
+		return
+	}
+
+	state.Timestamp = s.clock.Now().UTC().Format(time.RFC3339)
+	data, err = json.Marshal(state)
+	if err != nil {
+		stderrLogger.Printf("failed to serialize state of node %q: %v", nodeName, err)
+		return
+	}
+
+	cli, err := s.apiHelper.GetClient()
+	if err != nil {
+		stderrLogger.Printf("failed to store state of node %q: %v", nodeName, err)
+		return
+	}
+	key := ownerAnnotation("state", u.owner)
+	err = s.apiHelper.UpdateConfigMapData(cli, s.args.StateNamespace, nodeStateConfigMapName(nodeName), map[string]string{key: string(data)})
+	if err != nil {
+		stderrLogger.Printf("failed to store state of node %q: %v", nodeName, err)
+		return
+	}
+	s.storedStates.store(nodeName, u.owner, digest)
+}
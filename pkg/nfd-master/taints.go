@@ -0,0 +1,164 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"sort"
+	"strings"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
+)
+
+// taintName returns the name of a requested taint used in rejections
+func taintName(t *pb.Taint) string {
+	return t.Key + "=" + t.Value + ":" + t.Effect
+}
+
+// filterTaints filters out requested taints with an invalid key, value or
+// effect, and taints in namespaces that are not allowed. Taint keys without a
+// namespace are put to the label namespace. The taints are returned sorted.
+func filterTaints(taints []*pb.Taint, labelNs string, extraLabelNs []string, denied map[string]bool, rej *rejections) []api.Taint {
+	filtered := []api.Taint{}
+	for _, t := range taints {
+		key := addNs(t.Key, labelNs)
+		ns := strings.SplitN(key, "/", 2)[0]
+
+		// Check namespace, filter out if ns is not whitelisted
+		if ns+"/" != labelNs {
+			allowed := false
+			for _, extraNs := range extraLabelNs {
+				if ns == extraNs {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				rej.add(rejectedTaint, taintName(t), "namespace '%s' is not allowed", ns)
+				continue
+			}
+		}
+		if denied[ns] {
+			rej.add(rejectedTaint, taintName(t), "client is not authorized to publish in namespace '%s'", ns)
+			continue
+		}
+
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			rej.add(rejectedTaint, taintName(t), "invalid key: %s", strings.Join(errs, "; "))
+			continue
+		}
+		if errs := validation.IsValidLabelValue(t.Value); len(errs) > 0 {
+			rej.add(rejectedTaint, taintName(t), "invalid value: %s", strings.Join(errs, "; "))
+			continue
+		}
+		switch api.TaintEffect(t.Effect) {
+		case api.TaintEffectNoSchedule, api.TaintEffectPreferNoSchedule, api.TaintEffectNoExecute:
+		default:
+			rej.add(rejectedTaint, taintName(t), "unsupported effect %q", t.Effect)
+			continue
+		}
+
+		filtered = append(filtered, api.Taint{Key: key, Value: t.Value, Effect: api.TaintEffect(t.Effect)})
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].ToString() < filtered[j].ToString() })
+	return filtered
+}
+
+// formatTaints returns the taints as a comma-separated list of
+// key=value:effect items, the format used in the taints annotation
+func formatTaints(taints []api.Taint) string {
+	items := make([]string, len(taints))
+	for i := range taints {
+		items[i] = taints[i].ToString()
+	}
+	return strings.Join(items, ",")
+}
+
+// parseTaints parses the taints annotation. Malformed items are ignored.
+func parseTaints(s string) []api.Taint {
+	taints := []api.Taint{}
+	for _, item := range strings.Split(s, ",") {
+		i := strings.LastIndex(item, ":")
+		if i < 0 {
+			continue
+		}
+		split := strings.SplitN(item[:i], "=", 2)
+		t := api.Taint{Key: split[0], Effect: api.TaintEffect(item[i+1:])}
+		if len(split) == 2 {
+			t.Value = split[1]
+		}
+		taints = append(taints, t)
+	}
+	return taints
+}
+
+// updateTaints replaces the taints previously applied by nfd-master with new
+// ones. Other taints of the node are left untouched, unless they have the same
+// key and effect as one of the new taints.
+func updateTaints(n *api.Node, oldTaints, newTaints []api.Taint) {
+	var taints []api.Taint
+	for _, t := range n.Spec.Taints {
+		if containsTaint(oldTaints, t, false) || containsTaint(newTaints, t, true) {
+			continue
+		}
+		taints = append(taints, t)
+	}
+	n.Spec.Taints = append(taints, newTaints...)
+}
+
+// taintChanges contains the added and removed taints of a node, in
+// key=value:effect format
+type taintChanges struct {
+	added   []string
+	removed []string
+}
+
+// empty returns true if there are no changes
+func (c taintChanges) empty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0
+}
+
+// diffTaints compares two sets of taints. A taint whose value changed is
+// reported as removed and added.
+func diffTaints(oldTaints, newTaints []api.Taint) taintChanges {
+	changes := taintChanges{}
+	for _, t := range newTaints {
+		if !containsTaint(oldTaints, t, false) {
+			changes.added = append(changes.added, t.ToString())
+		}
+	}
+	for _, t := range oldTaints {
+		if !containsTaint(newTaints, t, false) {
+			changes.removed = append(changes.removed, t.ToString())
+		}
+	}
+	sort.Strings(changes.added)
+	sort.Strings(changes.removed)
+	return changes
+}
+
+// containsTaint returns true if the list contains the taint. Only the key and
+// effect are compared if matchOnly is true.
+func containsTaint(taints []api.Taint, t api.Taint, matchOnly bool) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&t) && (matchOnly || taints[i].Value == t.Value) {
+			return true
+		}
+	}
+	return false
+}
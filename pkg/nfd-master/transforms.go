@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// applyLabelTransforms returns a new set of labels with label names rewritten
+// according to the given transforms. Non-namespaced labels are in the given
+// label namespace. Labels whose name becomes invalid are dropped.
+func applyLabelTransforms(labels Labels, t LabelTransforms, labelNs string) Labels {
+	if len(t.KeyRewrites) == 0 && len(t.NamespaceRemap) == 0 {
+		return labels
+	}
+
+	defaultNs := strings.TrimSuffix(labelNs, "/")
+	out := make(Labels, len(labels))
+	for name, value := range labels {
+		newName := name
+		for _, r := range t.KeyRewrites {
+			newName = r.regexp.ReplaceAllString(newName, r.Replacement)
+		}
+
+		// Remap namespace
+		ns, base := defaultNs, newName
+		if split := strings.SplitN(newName, "/", 2); len(split) == 2 {
+			ns, base = split[0], split[1]
+		}
+		if newNs, ok := t.NamespaceRemap[ns]; ok {
+			if newNs == defaultNs {
+				newName = base
+			} else {
+				newName = newNs + "/" + base
+			}
+		}
+
+		if newName != name {
+			nameForValidation := newName
+			if !strings.Contains(newName, "/") {
+				nameForValidation = labelNs + newName
+			}
+			if errs := validation.IsQualifiedName(nameForValidation); len(errs) > 0 {
+				stderrLogger.Printf("Ignoring label %s: invalid name %q after transform: %s", name, newName, errs)
+				continue
+			}
+		}
+		if _, ok := out[newName]; ok {
+			stderrLogger.Printf("WARNING: label %s overrides an existing label %s after transform", name, newName)
+		}
+		out[newName] = value
+	}
+	return out
+}
+
+// validate checks a label value against the schema
+func (r *LabelSchema) validate(value string) error {
+	if r.valueRegexp != nil && !r.valueRegexp.MatchString(value) {
+		return fmt.Errorf("value %q does not match %q", value, r.Regexp)
+	}
+	if len(r.Enum) > 0 {
+		found := false
+		for _, v := range r.Enum {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value %q is not one of [%s]", value, strings.Join(r.Enum, ", "))
+		}
+	}
+	if r.Integer != nil {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("value %q is not an integer", value)
+		}
+		if r.Integer.Min != nil && i < *r.Integer.Min {
+			return fmt.Errorf("value %d is less than %d", i, *r.Integer.Min)
+		}
+		if r.Integer.Max != nil && i > *r.Integer.Max {
+			return fmt.Errorf("value %d is greater than %d", i, *r.Integer.Max)
+		}
+	}
+	return nil
+}
+
+// validateLabelValues checks the label values against the given schemas. An
+// error describing all invalid labels is returned.
+func validateLabelValues(labels Labels, schemas []LabelSchema) error {
+	var errs []string
+	for name, value := range labels {
+		for i := range schemas {
+			if schemas[i].labelRegexp != nil && !schemas[i].labelRegexp.MatchString(name) {
+				continue
+			}
+			if err := schemas[i].validate(value); err != nil {
+				errs = append(errs, fmt.Sprintf("label %s: %v", name, err))
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("invalid label values: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"reflect"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
+)
+
+// Reasons of feature events sent to watching clients
+const (
+	featureEventModified = "modified"
+	featureEventDeleted  = "deleted"
+)
+
+// publishedFeatures tracks the feature labels and annotations last written by
+// nfd-master on each node, together with the UID of the node object. It is
+// used for telling the changes made by nfd-master itself apart from changes
+// made by others, and for detecting re-created node objects.
+type publishedFeatures struct {
+	sync.Mutex
+	nodes map[string]publishedNode
+}
+
+type publishedNode struct {
+	uid      types.UID
+	features *pb.GetLabelsReply
+}
+
+func newPublishedFeatures() *publishedFeatures {
+	return &publishedFeatures{nodes: make(map[string]publishedNode)}
+}
+
+// watchedFeatures returns the features of a node that are reported to
+// watching clients. Extended resources are not included as they are updated
+// separately from the node object.
+func watchedFeatures(n *api.Node, annotationNs string) *pb.GetLabelsReply {
+	f := nodeFeatures(n, annotationNs)
+	f.ExtendedResources = nil
+	return f
+}
+
+// store records the features of a node about to be written by nfd-master
+func (p *publishedFeatures) store(nodeName string, uid types.UID, f *pb.GetLabelsReply) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	p.nodes[nodeName] = publishedNode{uid: uid, features: f}
+}
+
+// uid returns the UID of the node object last written by nfd-master
+func (p *publishedFeatures) uid(nodeName string) (types.UID, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	n, ok := p.nodes[nodeName]
+	return n.uid, ok
+}
+
+// forget drops the features recorded for a node
+func (p *publishedFeatures) forget(nodeName string) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.nodes, nodeName)
+}
+
+// matches returns true if the given features are the ones last written by
+// nfd-master on the node object with the given UID
+func (p *publishedFeatures) matches(nodeName string, uid types.UID, f *pb.GetLabelsReply) bool {
+	if p == nil {
+		return false
+	}
+	p.Lock()
+	defer p.Unlock()
+
+	n, ok := p.nodes[nodeName]
+	return ok && n.uid == uid && reflect.DeepEqual(n.features, f)
+}
+
+// featureWatchers distributes feature events to the WatchFeatures streams of
+// each node. All streams are served from the events of the shared node
+// informer so that no per-stream watches of the API server are needed.
+type featureWatchers struct {
+	sync.Mutex
+	streams map[string]map[chan string]struct{}
+}
+
+func newFeatureWatchers() *featureWatchers {
+	return &featureWatchers{streams: make(map[string]map[chan string]struct{})}
+}
+
+// subscribe returns a channel receiving the reasons of the feature events of
+// a node
+func (w *featureWatchers) subscribe(nodeName string) chan string {
+	w.Lock()
+	defer w.Unlock()
+
+	// One pending event is enough as each one triggers a full re-publish
+	ch := make(chan string, 1)
+	if w.streams[nodeName] == nil {
+		w.streams[nodeName] = make(map[chan string]struct{})
+	}
+	w.streams[nodeName][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe stops sending events to a channel returned by subscribe
+func (w *featureWatchers) unsubscribe(nodeName string, ch chan string) {
+	w.Lock()
+	defer w.Unlock()
+
+	delete(w.streams[nodeName], ch)
+	if len(w.streams[nodeName]) == 0 {
+		delete(w.streams, nodeName)
+	}
+}
+
+// notify sends a feature event to all streams watching the node. The event
+// is dropped for streams that already have one pending.
+func (w *featureWatchers) notify(nodeName, reason string) {
+	if w == nil {
+		return
+	}
+	w.Lock()
+	defer w.Unlock()
+
+	for ch := range w.streams[nodeName] {
+		select {
+		case ch <- reason:
+		default:
+		}
+	}
+}
+
+// nodeEventHandler returns the handler of the events of the shared node
+// informer
+func (s *labelerServer) nodeEventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			prev, ok := oldObj.(*api.Node)
+			if !ok {
+				return
+			}
+			cur, ok := newObj.(*api.Node)
+			if !ok {
+				return
+			}
+			s.nodeUpdated(prev, cur)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if n, ok := obj.(*api.Node); ok {
+				s.nodeDeleted(n)
+			}
+		},
+	}
+}
+
+// nodeUpdated notifies the watchers of a node if its features were modified
+// by someone else than nfd-master. The features are then re-applied on the
+// next request from nfd-worker, even if identical to the previous one. A
+// change of the UID means that the node object was re-created.
+func (s *labelerServer) nodeUpdated(prev, cur *api.Node) {
+	if prev.UID != cur.UID {
+		s.nodeDeleted(prev)
+		return
+	}
+	f := watchedFeatures(cur, s.ns.annotation)
+	if reflect.DeepEqual(watchedFeatures(prev, s.ns.annotation), f) || s.published.matches(cur.Name, cur.UID, f) {
+		return
+	}
+	s.updateCache.forget(cur.Name)
+	s.watchers.notify(cur.Name, featureEventModified)
+}
+
+// nodeDeleted drops the state kept for a node and notifies its watchers
+func (s *labelerServer) nodeDeleted(n *api.Node) {
+	s.forgetNode(n.Name)
+	forgetNodeMetrics(n.Name)
+	s.watchers.notify(n.Name, featureEventDeleted)
+}
+
+// forgetNode drops all state kept for a node, both in memory and in the
+// state ConfigMap, forcing a full re-publish on the next request
+func (s *labelerServer) forgetNode(nodeName string) {
+	s.updateCache.forget(nodeName)
+	s.published.forget(nodeName)
+	s.storedStates.forget(nodeName)
+	if s.args.StateNamespace != "" {
+		cli, err := s.apiHelper.GetClient()
+		if err == nil {
+			err = s.apiHelper.DeleteConfigMap(cli, s.args.StateNamespace, nodeStateConfigMapName(nodeName))
+		}
+		if err != nil {
+			stderrLogger.Printf("failed to remove state of node %q: %v", nodeName, err)
+		}
+	}
+}
+
+// featuresIntact returns true if the features of a node are the ones last
+// written by nfd-master, i.e. they have not been modified by others since
+func (s *labelerServer) featuresIntact(nodeName string) bool {
+	cli, err := s.apiHelper.GetClient()
+	if err != nil {
+		return false
+	}
+	node, err := s.apiHelper.GetNode(cli, nodeName)
+	if err != nil {
+		return false
+	}
+	return s.published.matches(nodeName, node.UID, watchedFeatures(node, s.ns.annotation))
+}
+
+// Service WatchFeatures
+func (s *labelerServer) WatchFeatures(r *pb.WatchFeaturesRequest, stream pb.Labeler_WatchFeaturesServer) error {
+	if err := s.authorizeNode(stream.Context(), r.NodeName); err != nil {
+		return err
+	}
+	if s.args.NoPublish {
+		return status.Errorf(codes.FailedPrecondition, "node features are not available with --no-publish")
+	}
+	if s.watchers == nil {
+		return status.Errorf(codes.FailedPrecondition, "watching node features requires --node-cache")
+	}
+
+	events := s.watchers.subscribe(r.NodeName)
+	defer s.watchers.unsubscribe(r.NodeName, events)
+
+	stdoutLogger.Printf("watching features of node %q", r.NodeName)
+	defer stdoutLogger.Printf("stopped watching features of node %q", r.NodeName)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.stop:
+			return status.Errorf(codes.Unavailable, "nfd-master is shutting down")
+		case reason := <-events:
+			if err := stream.Send(&pb.FeatureEvent{NodeName: r.NodeName, Reason: reason}); err != nil {
+				return err
+			}
+		}
+	}
+}
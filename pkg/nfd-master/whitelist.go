@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdmaster
+
+import (
+	"regexp"
+	"strings"
+)
+
+// whiteList decides which feature labels and annotations are published, based
+// on their name (without the namespace). A name is accepted if it matches any
+// of the patterns, or, if there are no patterns. Namespace specific patterns
+// replace the global patterns for names in that namespace.
+type whiteList struct {
+	patterns   []*regexp.Regexp
+	nsPatterns map[string][]*regexp.Regexp
+}
+
+func newWhiteList(patterns ...*regexp.Regexp) *whiteList {
+	w := &whiteList{}
+	for _, p := range patterns {
+		if p != nil && p.String() != "" {
+			w.patterns = append(w.patterns, p)
+		}
+	}
+	return w
+}
+
+// whiteList returns the effective whitelist, combining the pattern given on
+// the command line with the patterns of the configuration file
+func (c *NFDConfig) whiteList(pattern *regexp.Regexp) *whiteList {
+	w := newWhiteList(append([]*regexp.Regexp{pattern}, c.labelWhiteList...)...)
+	w.nsPatterns = c.namespaceWhiteLists
+	return w
+}
+
+// matches returns true if a name in the given namespace is accepted
+func (w *whiteList) matches(ns, name string) bool {
+	patterns := w.patterns
+	if p, ok := w.nsPatterns[ns]; ok {
+		patterns = p
+	}
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternsFor returns the patterns applied in the given namespace, for logging
+func (w *whiteList) patternsFor(ns string) string {
+	patterns := w.patterns
+	if p, ok := w.nsPatterns[ns]; ok {
+		patterns = p
+	}
+	s := make([]string, len(patterns))
+	for i, p := range patterns {
+		s[i] = p.String()
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdworker
+
+import (
+	"log"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// Per-source verbosity levels, overriding the global klog verbosity
+var sourceVerbosity = map[string]int{}
+
+// sourceV returns true if the verbosity of a feature source is at least the
+// given level. The global verbosity applies to sources without a level of
+// their own.
+func sourceV(name string, level int) bool {
+	if v, ok := sourceVerbosity[name]; ok {
+		return v >= level
+	}
+	return bool(klog.V(klog.Level(level)))
+}
+
+// sourceLogWriter forwards the output of the standard logger, used by the
+// feature sources, to klog. Messages are prefixed with the name of the source
+// being run, if any. Errors and warnings are always logged, other messages of
+// a source only at verbosity 1 or higher.
+type sourceLogWriter struct {
+	name string
+}
+
+// Write implements the io.Writer interface
+func (w sourceLogWriter) Write(p []byte) (int, error) {
+	prefix := ""
+	if w.name != "" {
+		prefix = "[" + w.name + "] "
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "ERROR: "), strings.HasPrefix(line, "PANIC: "):
+			klog.Errorf("%s%s", prefix, line[strings.Index(line, " ")+1:])
+		case strings.HasPrefix(line, "WARNING: "):
+			klog.Warningf("%s%s", prefix, strings.TrimPrefix(line, "WARNING: "))
+		case w.name == "" || sourceV(w.name, 1):
+			klog.Infof("%s%s", prefix, strings.TrimPrefix(line, "INFO: "))
+		}
+	}
+	return len(p), nil
+}
+
+// setSourceLogger routes the standard logger to klog, with the messages
+// attributed to the given source
+func setSourceLogger(name string) {
+	log.SetFlags(0)
+	log.SetOutput(sourceLogWriter{name: name})
+}
@@ -17,16 +17,31 @@ limitations under the License.
 package nfdworker
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	crand "crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/mock"
 	"github.com/vektra/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"sigs.k8s.io/node-feature-discovery/pkg/labeler"
 	"sigs.k8s.io/node-feature-discovery/source"
 	"sigs.k8s.io/node-feature-discovery/source/cpu"
@@ -55,9 +70,12 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 			mockFeatureSource.On("Name").Return(fakeFeatureSourceName)
 			mockFeatureSource.On("Discover").Return(fakeFeatures, nil)
 
-			returnedLabels, err := getFeatureLabels(fakeFeatureSource, labelWhiteList)
+			returned, err := getFeatureLabels(fakeFeatureSource, labelWhiteList, nil)
 			Convey("Proper label is returned", func() {
-				So(returnedLabels, ShouldResemble, fakeFeatureLabels)
+				So(returned.Labels, ShouldResemble, fakeFeatureLabels)
+			})
+			Convey("Raw features of the source are returned", func() {
+				So(returned.RawFeatures, ShouldResemble, map[string]source.Features{fakeFeatureSourceName: fakeFeatures})
 			})
 			Convey("Error is nil", func() {
 				So(err, ShouldBeNil)
@@ -66,11 +84,12 @@ func TestDiscoveryWithMockSources(t *testing.T) {
 
 		Convey("When I fail to get the labels from the mock source", func() {
 			expectedError := errors.New("fake error")
+			mockFeatureSource.On("Name").Return(fakeFeatureSourceName)
 			mockFeatureSource.On("Discover").Return(nil, expectedError)
 
-			returnedLabels, err := getFeatureLabels(fakeFeatureSource, labelWhiteList)
+			returned, err := getFeatureLabels(fakeFeatureSource, labelWhiteList, nil)
 			Convey("No label is returned", func() {
-				So(returnedLabels, ShouldBeNil)
+				So(returned.Labels, ShouldBeNil)
 			})
 			Convey("Error is produced", func() {
 				So(err, ShouldEqual, expectedError)
@@ -155,6 +174,217 @@ func TestConfigParse(t *testing.T) {
 				So(c.(*pci.Config).DeviceClassWhitelist, ShouldResemble, []string{"03"})
 			})
 		})
+
+		Convey("and core settings are specified", func() {
+			overrides := `{"core": {"labelSources": ["fake", "kernel"], "labelWhiteList": "^fake"}, "sources": {"kernel": {"configOpts": ["X86"]}}}`
+			worker.configure(f.Name(), overrides)
+
+			Convey("they should take precedence over the command line flags", func() {
+				So(len(worker.sources), ShouldEqual, 2)
+				So(worker.getSource("fake"), ShouldNotBeNil)
+				So(worker.getSource("cpu"), ShouldBeNil)
+				c := worker.getSource("kernel").GetConfig()
+				So(c.(*kernel.Config).ConfigOpts, ShouldResemble, []string{"X86"})
+				So(worker.labelWhiteList.String(), ShouldEqual, "^fake")
+			})
+
+			Convey("command line flags should be restored when removed from the config", func() {
+				worker.configure(f.Name(), "")
+				So(len(worker.sources), ShouldEqual, 3)
+				So(worker.getSource("cpu"), ShouldNotBeNil)
+				So(worker.labelWhiteList.String(), ShouldEqual, "")
+			})
+		})
+
+		Convey("and sources are specified with the deprecated setting", func() {
+			worker.configure(f.Name(), `{"core": {"sources": ["fake", "kernel"]}}`)
+
+			Convey("they should take effect", func() {
+				So(len(worker.sources), ShouldEqual, 2)
+				So(worker.getSource("cpu"), ShouldBeNil)
+			})
+
+			Convey("they should be ignored if the new setting is used", func() {
+				worker.configure(f.Name(), `{"core": {"sources": ["fake", "kernel"], "labelSources": ["fake"]}}`)
+				So(len(worker.sources), ShouldEqual, 1)
+			})
+		})
+
+		Convey("and sources are enabled and disabled with per-source settings", func() {
+			overrides := `{"sources": {"cpu": {"enabled": false}, "fake": {"enabled": true}, "kernel": {"enabled": true, "configOpts": ["X86"]}}}`
+			worker.configure(f.Name(), overrides)
+
+			Convey("the list of enabled sources should be updated", func() {
+				So(len(worker.sources), ShouldEqual, 3)
+				So(worker.getSource("cpu"), ShouldBeNil)
+				So(worker.getSource("fake"), ShouldNotBeNil)
+				c := worker.getSource("kernel").GetConfig()
+				So(c.(*kernel.Config).ConfigOpts, ShouldResemble, []string{"X86"})
+			})
+		})
+
+		Convey("and per-source label filters are specified", func() {
+			overrides := `{"sources": {"kernel": {"labelWhiteList": "^kernel-version", "labelDenyList": "full$"}, "fake": {"labelDenyList": "("}}}`
+			worker.configure(f.Name(), overrides)
+
+			Convey("valid filters should be applied", func() {
+				So(worker.labelFilters, ShouldContainKey, "kernel")
+				So(worker.labelFilters["kernel"].match("kernel-version.major"), ShouldBeTrue)
+				So(worker.labelFilters["kernel"].match("kernel-version.full"), ShouldBeFalse)
+				So(worker.labelFilters["kernel"].match("kernel-config.NO_HZ"), ShouldBeFalse)
+			})
+			Convey("invalid filters should be ignored", func() {
+				So(worker.labelFilters, ShouldNotContainKey, "fake")
+			})
+			Convey("filters should be removed when removed from the config", func() {
+				worker.configure(f.Name(), "")
+				So(worker.labelFilters, ShouldBeEmpty)
+			})
+		})
+
+		Convey("and sleep settings are specified", func() {
+			worker.configure(f.Name(), `{"core": {"sleepInterval": "30s", "sleepJitter": 10}}`)
+			So(worker.sleepInterval, ShouldEqual, 30*time.Second)
+			So(worker.config.Core.SleepJitter, ShouldEqual, 10)
+
+			Convey("invalid jitter should be ignored", func() {
+				worker.configure(f.Name(), `{"core": {"sleepJitter": 200}}`)
+				So(worker.sleepInterval, ShouldEqual, worker.args.SleepInterval)
+				So(worker.config.Core.SleepJitter, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestJitteredInterval(t *testing.T) {
+	Convey("When randomizing the sleep interval", t, func() {
+		w := &nfdWorker{rand: rand.New(rand.NewSource(1))}
+
+		Convey("Intervals should stay within the jitter", func() {
+			for i := 0; i < 100; i++ {
+				d := w.jitteredInterval(60*time.Second, 10)
+				So(d, ShouldBeBetweenOrEqual, 54*time.Second, 66*time.Second)
+			}
+		})
+		Convey("Zero jitter should not change the interval", func() {
+			So(w.jitteredInterval(60*time.Second, 0), ShouldEqual, 60*time.Second)
+		})
+	})
+}
+
+func TestRetryInterval(t *testing.T) {
+	Convey("When computing the retry interval", t, func() {
+		w := &nfdWorker{args: Args{MaxRetryInterval: time.Minute}, rand: rand.New(rand.NewSource(1))}
+
+		Convey("Intervals should grow exponentially", func() {
+			for i := 0; i < 100; i++ {
+				So(w.retryInterval(0), ShouldBeBetweenOrEqual, 500*time.Millisecond, time.Second)
+				So(w.retryInterval(3), ShouldBeBetweenOrEqual, 4*time.Second, 8*time.Second)
+			}
+		})
+		Convey("Intervals should not exceed the maximum", func() {
+			for i := 0; i < 100; i++ {
+				So(w.retryInterval(10), ShouldBeBetweenOrEqual, 30*time.Second, time.Minute)
+				So(w.retryInterval(100), ShouldBeBetweenOrEqual, 30*time.Second, time.Minute)
+			}
+		})
+	})
+}
+
+func TestEnabledSources(t *testing.T) {
+	sourceNames := func(sources []source.FeatureSource) []string {
+		names := make([]string, len(sources))
+		for i, s := range sources {
+			names[i] = s.Name()
+		}
+		return names
+	}
+
+	Convey("When selecting the enabled sources", t, func() {
+		names := []string{"cpu", " kernel", "pci", "custom", "nonexistent"}
+
+		Convey("Only known sources in the list should be returned", func() {
+			So(sourceNames(enabledSources(names, false)), ShouldResemble, []string{"cpu", "kernel", "pci", "custom"})
+		})
+		Convey("Heavyweight sources should be dropped in low-memory mode", func() {
+			So(sourceNames(enabledSources(names, true)), ShouldResemble, []string{"cpu", "kernel"})
+		})
+	})
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	Convey("When watching the config file", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "nfd-worker.conf")
+
+		changed := make(chan struct{}, 1)
+		watcher, err := watchConfigFile(path, changed)
+		So(err, ShouldBeNil)
+		defer watcher.Close()
+
+		Convey("Changes of the file should be signalled", func() {
+			So(ioutil.WriteFile(path, []byte("core:\n"), 0644), ShouldBeNil)
+			select {
+			case <-changed:
+			case <-time.After(5 * time.Second):
+				t.Error("config file change was not detected")
+			}
+		})
+		Convey("Changes of other files should be ignored", func() {
+			So(ioutil.WriteFile(filepath.Join(dir, "other.conf"), []byte("foo\n"), 0644), ShouldBeNil)
+			select {
+			case <-changed:
+				t.Error("unexpected config file change")
+			case <-time.After(100 * time.Millisecond):
+			}
+		})
+	})
+
+	Convey("When the config file directory does not exist", t, func() {
+		_, err := watchConfigFile("/non-existent-dir/nfd-worker.conf", make(chan struct{}, 1))
+		Convey("An error should be returned", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestWatchDropinDir(t *testing.T) {
+	Convey("When watching a drop-in directory", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		So(os.Mkdir(filepath.Join(dir, "sub"), 0755), ShouldBeNil)
+
+		changed := make(chan struct{}, 1)
+		watcher, err := watchDropinDir(dir, changed)
+		So(err, ShouldBeNil)
+		defer watcher.Close()
+
+		Convey("Changes of files in the directory should be signalled", func() {
+			So(ioutil.WriteFile(filepath.Join(dir, "rules.yaml"), []byte("[]\n"), 0644), ShouldBeNil)
+			select {
+			case <-changed:
+			case <-time.After(5 * time.Second):
+				t.Error("drop-in file change was not detected")
+			}
+		})
+		Convey("Changes of files in subdirectories should be signalled", func() {
+			So(ioutil.WriteFile(filepath.Join(dir, "sub", "rules.yaml"), []byte("[]\n"), 0644), ShouldBeNil)
+			select {
+			case <-changed:
+			case <-time.After(5 * time.Second):
+				t.Error("drop-in file change was not detected")
+			}
+		})
+	})
+
+	Convey("When the drop-in directory does not exist", t, func() {
+		_, err := watchDropinDir("/non-existent-dir/custom.d", make(chan struct{}, 1))
+		Convey("A not-exist error should be returned", func() {
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
 	})
 }
 
@@ -224,7 +454,8 @@ func TestCreateFeatureLabels(t *testing.T) {
 			fakeFeatureSource := source.FeatureSource(new(fake.Source))
 			sources := []source.FeatureSource{}
 			sources = append(sources, fakeFeatureSource)
-			labels := createFeatureLabels(sources, emptyLabelWL)
+			features, _ := createFeatureLabels(sources, emptyLabelWL, nil)
+			labels := features.Labels
 
 			Convey("Proper fake labels are returned", func() {
 				So(len(labels), ShouldEqual, 3)
@@ -238,7 +469,8 @@ func TestCreateFeatureLabels(t *testing.T) {
 			fakeFeatureSource := source.FeatureSource(new(fake.Source))
 			sources := []source.FeatureSource{}
 			sources = append(sources, fakeFeatureSource)
-			labels := createFeatureLabels(sources, emptyLabelWL)
+			features, _ := createFeatureLabels(sources, emptyLabelWL, nil)
+			labels := features.Labels
 
 			Convey("fake labels are not returned", func() {
 				So(len(labels), ShouldEqual, 0)
@@ -247,6 +479,32 @@ func TestCreateFeatureLabels(t *testing.T) {
 				So(labels, ShouldNotContainKey, "fake-fakefeature3")
 			})
 		})
+		Convey("When fake feature source is configured with per-source label filters", func() {
+			sources := []source.FeatureSource{new(fake.Source)}
+			filter, err := newLabelFilter(labelFilterConfig{WhiteList: "feature[12]$", DenyList: "feature2"})
+			So(err, ShouldBeNil)
+			features, _ := createFeatureLabels(sources, regexp.MustCompile(""), map[string]*labelFilter{"fake": filter})
+
+			Convey("only labels passing the filters are returned", func() {
+				So(features.Labels, ShouldResemble, Labels{"fake-fakefeature1": "true"})
+			})
+		})
+	})
+}
+
+func TestCreateFeatureLabelsFailure(t *testing.T) {
+	Convey("When discovery fails for a feature source", t, func() {
+		sources := []source.FeatureSource{new(fake.Source), new(panicfake.Source)}
+		features, failed := createFeatureLabels(sources, regexp.MustCompile(""), nil)
+
+		Convey("Labels of the other sources should be returned", func() {
+			So(len(features.Labels), ShouldEqual, 3)
+			So(features.RawFeatures, ShouldContainKey, "fake")
+			So(features.RawFeatures, ShouldNotContainKey, "panic_fake")
+		})
+		Convey("The failed source should be reported", func() {
+			So(failed, ShouldResemble, []string{"panic_fake"})
+		})
 	})
 }
 
@@ -254,25 +512,83 @@ func TestGetFeatureLabels(t *testing.T) {
 	Convey("When I get feature labels and panic occurs during discovery of a feature source", t, func() {
 		fakePanicFeatureSource := source.FeatureSource(new(panicfake.Source))
 
-		returnedLabels, err := getFeatureLabels(fakePanicFeatureSource, regexp.MustCompile(""))
+		returned, err := getFeatureLabels(fakePanicFeatureSource, regexp.MustCompile(""), nil)
 		Convey("No label is returned", func() {
-			So(len(returnedLabels), ShouldEqual, 0)
+			So(len(returned.Labels), ShouldEqual, 0)
 		})
 		Convey("Error is produced and panic error is returned", func() {
 			So(err, ShouldResemble, fmt.Errorf("fake panic error"))
 		})
 
 	})
+
+	Convey("When I get feature labels from a source that reports annotations, extended resources and taints", t, func() {
+		mockFeatureSource := &fakeTaintSource{MockFeatureSource: &source.MockFeatureSource{}, taints: []source.Taint{
+			{Key: "feature-1", Value: "true", Effect: "NoSchedule"},
+			{Key: "feature-1", Value: "true", Effect: "NoExecute"},
+			{Key: "invalid/name/feature-6", Effect: "NoSchedule"},
+		}}
+		mockFeatureSource.On("Name").Return(fakeFeatureSourceName)
+		mockFeatureSource.On("Discover").Return(source.Features{
+			"feature-1":              true,
+			"feature-2":              source.AnnotationFeatureValue("free-form value, not a label"),
+			"vendor.com/feature-3":   source.AnnotationFeatureValue("{\"json\": true}"),
+			"invalid/name/feature-4": source.AnnotationFeatureValue("foo"),
+			"feature-5":              source.ExtendedResourceFeatureValue(8),
+		}, nil)
+
+		features, err := getFeatureLabels(mockFeatureSource, regexp.MustCompile(""), nil)
+		Convey("Annotations are returned separately from labels", func() {
+			So(err, ShouldBeNil)
+			So(features.Labels, ShouldResemble, Labels{fakeFeatureSourceName + "-feature-1": "true"})
+			So(features.Annotations, ShouldResemble, Annotations{
+				fakeFeatureSourceName + "-feature-2": "free-form value, not a label",
+				"vendor.com/feature-3":               "{\"json\": true}",
+			})
+		})
+		Convey("Extended resources are returned separately from labels", func() {
+			So(features.ExtendedResources, ShouldResemble, ExtendedResources{fakeFeatureSourceName + "-feature-5": "8"})
+		})
+		Convey("Taints are returned separately from labels", func() {
+			So(features.Taints, ShouldResemble, Taints{
+				{Key: fakeFeatureSourceName + "-feature-1", Value: "true", Effect: "NoSchedule"},
+				{Key: fakeFeatureSourceName + "-feature-1", Value: "true", Effect: "NoExecute"},
+			})
+		})
+	})
+}
+
+func TestSanitizeLabelValue(t *testing.T) {
+	Convey("When sanitizing feature values", t, func() {
+		Convey("invalid characters should be replaced", func() {
+			So(sanitizeLabelValue("Intel(R) Xeon(R) CPU"), ShouldEqual, "Intel_R__Xeon_R__CPU")
+			So(sanitizeLabelValue("a/b:c"), ShouldEqual, "a_b_c")
+		})
+		Convey("values should be trimmed to begin and end with an alphanumeric character", func() {
+			So(sanitizeLabelValue(" -value_1. "), ShouldEqual, "value_1")
+		})
+		Convey("too long values should be truncated", func() {
+			So(sanitizeLabelValue(strings.Repeat("a", 100)), ShouldEqual, strings.Repeat("a", 63))
+		})
+		Convey("values without alphanumeric characters should become empty", func() {
+			So(sanitizeLabelValue("/!?"), ShouldEqual, "")
+		})
+	})
 }
 
 func TestAdvertiseFeatureLabels(t *testing.T) {
 	Convey("When advertising labels", t, func() {
 		mockClient := &labeler.MockLabelerClient{}
-		labels := map[string]string{"feature-1": "value-1"}
+		features := nodeFeatures{
+			Labels:            Labels{"feature-1": "value-1"},
+			Annotations:       Annotations{"feature-2": "value 2"},
+			ExtendedResources: ExtendedResources{"feature-3": "4"},
+			Taints:            Taints{{Key: "feature-4", Effect: "NoSchedule"}},
+		}
 
 		Convey("Correct labeling request is sent", func() {
 			mockClient.On("SetLabels", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.SetLabelsRequest")).Return(&labeler.SetLabelsReply{}, nil)
-			err := advertiseFeatureLabels(mockClient, labels)
+			err := advertiseFeatureLabels(mockClient, features)
 			Convey("There should be no error", func() {
 				So(err, ShouldBeNil)
 			})
@@ -280,10 +596,253 @@ func TestAdvertiseFeatureLabels(t *testing.T) {
 		Convey("Labeling request fails", func() {
 			mockErr := errors.New("mock-error")
 			mockClient.On("SetLabels", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.SetLabelsRequest")).Return(&labeler.SetLabelsReply{}, mockErr)
-			err := advertiseFeatureLabels(mockClient, labels)
+			err := advertiseFeatureLabels(mockClient, features)
 			Convey("An error should be returned", func() {
 				So(err, ShouldEqual, mockErr)
 			})
 		})
 	})
 }
+
+func TestWriteFeatures(t *testing.T) {
+	Convey("When writing features in dry-run mode", t, func() {
+		tmpDir, err := ioutil.TempDir("", "*.nfd-test")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tmpDir)
+		path := filepath.Join(tmpDir, "features.json")
+
+		features := newNodeFeatures()
+		features.Labels["fake-feature-1"] = "true"
+		features.ExtendedResources["fake-feature-2"] = "4"
+		features.Taints = Taints{{Key: "fake-feature-3", Value: "true", Effect: "NoSchedule"}}
+		features.RawFeatures["fake"] = source.Features{
+			"feature-1": true,
+			"feature-2": source.ExtendedResourceFeatureValue(4),
+		}
+
+		err = writeFeatures(features, path)
+		Convey("The features are written as JSON", func() {
+			So(err, ShouldBeNil)
+			data, err := ioutil.ReadFile(path)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `{
+  "labels": {
+    "fake-feature-1": "true"
+  },
+  "annotations": {},
+  "extendedResources": {
+    "fake-feature-2": "4"
+  },
+  "taints": [
+    {
+      "key": "fake-feature-3",
+      "value": "true",
+      "effect": "NoSchedule"
+    }
+  ],
+  "rawFeatures": {
+    "fake": {
+      "feature-1": true,
+      "feature-2": 4
+    }
+  }
+}
+`)
+		})
+	})
+}
+
+func TestGetMasterCapabilities(t *testing.T) {
+	Convey("When querying nfd-master capabilities", t, func() {
+		mockClient := &labeler.MockLabelerClient{}
+
+		Convey("Capabilities advertised by nfd-master should be returned", func() {
+			reply := &labeler.GetCapabilitiesReply{NfdVersion: "0.1-test", Capabilities: []string{labeler.CapabilityFeatureAnnotations}}
+			mockClient.On("GetCapabilities", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.GetCapabilitiesRequest")).Return(reply, nil)
+			caps, err := getMasterCapabilities(mockClient)
+			So(err, ShouldBeNil)
+			So(caps, ShouldResemble, map[string]bool{labeler.CapabilityFeatureAnnotations: true})
+		})
+		Convey("An old nfd-master should have no capabilities", func() {
+			mockErr := status.Error(codes.Unimplemented, "unknown method GetCapabilities")
+			mockClient.On("GetCapabilities", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.GetCapabilitiesRequest")).Return(nil, mockErr)
+			caps, err := getMasterCapabilities(mockClient)
+			So(err, ShouldBeNil)
+			So(caps, ShouldBeEmpty)
+		})
+		Convey("Other errors should be returned", func() {
+			mockErr := errors.New("mock-error")
+			mockClient.On("GetCapabilities", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.GetCapabilitiesRequest")).Return(nil, mockErr)
+			_, err := getMasterCapabilities(mockClient)
+			So(err, ShouldEqual, mockErr)
+		})
+	})
+}
+
+func TestGetPublishedFeatures(t *testing.T) {
+	Convey("When querying the published features", t, func() {
+		mockClient := &labeler.MockLabelerClient{}
+
+		Convey("The features published by nfd-master should be returned", func() {
+			reply := &labeler.GetLabelsReply{Labels: map[string]string{"feature.node.kubernetes.io/feature-1": "true"}}
+			mockClient.On("GetLabels", mock.AnythingOfType("*context.timerCtx"), &labeler.GetLabelsRequest{NodeName: nodeName}).Return(reply, nil)
+			features, err := getPublishedFeatures(mockClient)
+			So(err, ShouldBeNil)
+			So(features, ShouldResemble, publishedFeatures{
+				Labels:            Labels{"feature.node.kubernetes.io/feature-1": "true"},
+				Annotations:       Annotations{},
+				ExtendedResources: ExtendedResources{},
+			})
+		})
+		Convey("Errors should be returned", func() {
+			mockErr := errors.New("mock-error")
+			mockClient.On("GetLabels", mock.AnythingOfType("*context.timerCtx"), mock.AnythingOfType("*labeler.GetLabelsRequest")).Return(nil, mockErr)
+			_, err := getPublishedFeatures(mockClient)
+			So(err, ShouldEqual, mockErr)
+		})
+	})
+}
+
+// fakeTaintSource is a feature source requesting a pre-defined set of taints
+type fakeTaintSource struct {
+	*source.MockFeatureSource
+	taints []source.Taint
+}
+
+func (s *fakeTaintSource) Taints() []source.Taint { return s.taints }
+
+// fakeWatchFeaturesClient is a client stream of WatchFeatures returning a
+// pre-defined set of events
+type fakeWatchFeaturesClient struct {
+	grpc.ClientStream
+	events []*labeler.FeatureEvent
+}
+
+func (c *fakeWatchFeaturesClient) Recv() (*labeler.FeatureEvent, error) {
+	if len(c.events) == 0 {
+		return nil, io.EOF
+	}
+	e := c.events[0]
+	c.events = c.events[1:]
+	return e, nil
+}
+
+func TestReceiveFeatureEvents(t *testing.T) {
+	Convey("When receiving feature events", t, func() {
+		mockClient := &labeler.MockLabelerClient{}
+		republish := make(chan struct{}, 1)
+
+		Convey("Re-publishing should be requested", func() {
+			stream := &fakeWatchFeaturesClient{events: []*labeler.FeatureEvent{
+				{NodeName: nodeName, Reason: "modified"},
+				{NodeName: nodeName, Reason: "modified"},
+			}}
+			mockClient.On("WatchFeatures", mock.Anything, mock.AnythingOfType("*labeler.WatchFeaturesRequest")).Return(stream, nil)
+			err := receiveFeatureEvents(context.Background(), mockClient, republish)
+			So(err, ShouldEqual, io.EOF)
+			So(len(republish), ShouldEqual, 1)
+		})
+		Convey("Failure to start watching should be returned", func() {
+			mockErr := errors.New("mock-error")
+			mockClient.On("WatchFeatures", mock.Anything, mock.AnythingOfType("*labeler.WatchFeaturesRequest")).Return(nil, mockErr)
+			err := receiveFeatureEvents(context.Background(), mockClient, republish)
+			So(err, ShouldEqual, mockErr)
+			So(len(republish), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSourceVerbosity(t *testing.T) {
+	Convey("When per-source verbosity is specified", t, func() {
+		sourceVerbosity = map[string]int{"custom": 2, "local": 0}
+		defer func() { sourceVerbosity = map[string]int{} }()
+
+		Convey("The level of the source should override the global verbosity", func() {
+			So(sourceV("custom", 2), ShouldBeTrue)
+			So(sourceV("custom", 3), ShouldBeFalse)
+			So(sourceV("local", 1), ShouldBeFalse)
+		})
+		Convey("The global verbosity should apply to other sources", func() {
+			So(sourceV("cpu", 0), ShouldBeTrue)
+			So(sourceV("cpu", 1), ShouldBeFalse)
+		})
+	})
+}
+
+// writeSelfSignedCert writes a self-signed certificate for the given DNS name,
+// and its private key, to the given files
+func writeSelfSignedCert(certFile, keyFile, name string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), crand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(crand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func TestCertReloader(t *testing.T) {
+	Convey("When using reloadable TLS certificates", t, func() {
+		dir, err := ioutil.TempDir("", "nfd-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		certFile := filepath.Join(dir, "tls.crt")
+		keyFile := filepath.Join(dir, "tls.key")
+		So(writeSelfSignedCert(certFile, keyFile, "nfd-master"), ShouldBeNil)
+
+		r, err := newCertReloader(certFile, keyFile, certFile, "nfd-master")
+		So(err, ShouldBeNil)
+		cert, err := r.getClientCertificate(nil)
+		So(err, ShouldBeNil)
+
+		Convey("The server certificate should be verified against the root certificates and the server name", func() {
+			So(r.verifyPeerCertificate(cert.Certificate, nil), ShouldBeNil)
+
+			other, err := newCertReloader(certFile, keyFile, certFile, "other")
+			So(err, ShouldBeNil)
+			So(other.verifyPeerCertificate(cert.Certificate, nil), ShouldNotBeNil)
+		})
+
+		Convey("Rotated certificates should be reloaded", func() {
+			So(writeSelfSignedCert(certFile, keyFile, "nfd-master"), ShouldBeNil)
+			future := time.Now().Add(time.Minute)
+			for _, f := range []string{certFile, keyFile} {
+				So(os.Chtimes(f, future, future), ShouldBeNil)
+			}
+
+			newCert, err := r.getClientCertificate(nil)
+			So(err, ShouldBeNil)
+			So(newCert.Certificate[0], ShouldNotResemble, cert.Certificate[0])
+			// The old server certificate is no longer trusted
+			So(r.verifyPeerCertificate(cert.Certificate, nil), ShouldNotBeNil)
+			So(r.verifyPeerCertificate(newCert.Certificate, nil), ShouldBeNil)
+		})
+
+		Convey("The previous certificate should be used if reloading fails", func() {
+			So(os.Remove(keyFile), ShouldBeNil)
+			c, err := r.getClientCertificate(nil)
+			So(err, ShouldBeNil)
+			So(c, ShouldEqual, cert)
+		})
+	})
+}
@@ -17,27 +17,41 @@ limitations under the License.
 package nfdworker
 
 import (
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math/rand"
+	"net"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
 	pb "sigs.k8s.io/node-feature-discovery/pkg/labeler"
 	"sigs.k8s.io/node-feature-discovery/pkg/version"
 	"sigs.k8s.io/node-feature-discovery/source"
 	"sigs.k8s.io/node-feature-discovery/source/cpu"
 	"sigs.k8s.io/node-feature-discovery/source/custom"
+	"sigs.k8s.io/node-feature-discovery/source/deviceplugin"
 	"sigs.k8s.io/node-feature-discovery/source/fake"
+	"sigs.k8s.io/node-feature-discovery/source/gpu"
 	"sigs.k8s.io/node-feature-discovery/source/iommu"
 	"sigs.k8s.io/node-feature-discovery/source/kernel"
 	"sigs.k8s.io/node-feature-discovery/source/local"
@@ -45,21 +59,69 @@ import (
 	"sigs.k8s.io/node-feature-discovery/source/network"
 	"sigs.k8s.io/node-feature-discovery/source/panic_fake"
 	"sigs.k8s.io/node-feature-discovery/source/pci"
+	"sigs.k8s.io/node-feature-discovery/source/power"
 	"sigs.k8s.io/node-feature-discovery/source/storage"
 	"sigs.k8s.io/node-feature-discovery/source/system"
 	"sigs.k8s.io/node-feature-discovery/source/usb"
+	"sigs.k8s.io/node-feature-discovery/source/virt"
 	"sigs.k8s.io/yaml"
 )
 
 var (
-	stdoutLogger = log.New(os.Stdout, "", log.LstdFlags)
-	stderrLogger = log.New(os.Stderr, "", log.LstdFlags)
-	nodeName     = os.Getenv("NODE_NAME")
+	nodeName = os.Getenv("NODE_NAME")
 )
 
+// Sources that enumerate and keep all devices of the system in memory. These
+// are disabled in low-memory mode.
+var heavyweightSources = map[string]struct{}{
+	"custom": {},
+	"pci":    {},
+	"usb":    {},
+}
+
+// Garbage collector target percentage used in low-memory mode
+const lowMemoryGCPercent = 20
+
+// Initial delay before retrying a failed connection or request to nfd-master
+const retryInitialInterval = time.Second
+
+// Maximum delay between retries used if none is specified
+const defaultMaxRetryInterval = 5 * time.Minute
+
 // Global config
 type NFDConfig struct {
+	Core    coreConfig
 	Sources sourcesConfig
+
+	// sourceEnabled contains the per-source enabled settings
+	sourceEnabled map[string]bool
+	// sourceLabelFilters contains the per-source label filtering settings
+	sourceLabelFilters map[string]labelFilterConfig
+}
+
+// labelFilterConfig contains the label filtering settings of a feature source
+type labelFilterConfig struct {
+	WhiteList string
+	DenyList  string
+}
+
+// labelFilter filters the labels of one feature source. Labels must match
+// the whitelist, if set, and must not match the denylist, if set.
+type labelFilter struct {
+	whiteList *regexp.Regexp
+	denyList  *regexp.Regexp
+}
+
+// coreConfig contains the settings of nfd-worker itself. Settings specified
+// in the config take precedence over the corresponding command line flags.
+type coreConfig struct {
+	LabelSources   []string          `json:"labelSources,omitempty"`
+	LabelWhiteList string            `json:"labelWhiteList,omitempty"`
+	SleepInterval  *meta_v1.Duration `json:"sleepInterval,omitempty"`
+	SleepJitter    int               `json:"sleepJitter,omitempty"`
+
+	// Deprecated: Sources is the old name of LabelSources
+	Sources []string `json:"sources,omitempty"`
 }
 
 type sourcesConfig map[string]source.Config
@@ -67,20 +129,69 @@ type sourcesConfig map[string]source.Config
 // Labels are a Kubernetes representation of discovered features.
 type Labels map[string]string
 
+// Annotations are discovered features that are published as node annotations
+type Annotations map[string]string
+
+// ExtendedResources are discovered features that are published as node
+// extended resources
+type ExtendedResources map[string]string
+
+// Taints are node taints requested by the discovered features
+type Taints []*pb.Taint
+
+// nodeFeatures are the labels, annotations, extended resources and taints
+// created from the discovered features
+type nodeFeatures struct {
+	Labels            Labels            `json:"labels"`
+	Annotations       Annotations       `json:"annotations"`
+	ExtendedResources ExtendedResources `json:"extendedResources"`
+	Taints            Taints            `json:"taints"`
+	// RawFeatures are the features as reported by each feature source
+	RawFeatures map[string]source.Features `json:"rawFeatures"`
+}
+
+func newNodeFeatures() nodeFeatures {
+	return nodeFeatures{
+		Labels:            Labels{},
+		Annotations:       Annotations{},
+		ExtendedResources: ExtendedResources{},
+		Taints:            Taints{},
+		RawFeatures:       map[string]source.Features{},
+	}
+}
+
 // Command line arguments
 type Args struct {
 	LabelWhiteList     string
 	CaFile             string
 	CertFile           string
+	Compression        string
 	KeyFile            string
 	ConfigFile         string
+	GetLabels          bool
+	LowMemory          bool
+	MaxRetryInterval   time.Duration
 	NoPublish          bool
 	Options            string
 	Oneshot            bool
+	Output             string
 	Server             string
 	ServerNameOverride string
 	SleepInterval      time.Duration
+	SourceVerbosity    map[string]int
 	Sources            []string
+	Verbosity          int
+}
+
+// DiscoveryError is returned in one-shot mode if feature discovery failed for
+// some of the enabled sources. Features of the other sources are published
+// nevertheless.
+type DiscoveryError struct {
+	Sources []string
+}
+
+func (e *DiscoveryError) Error() string {
+	return fmt.Sprintf("feature discovery failed for sources: %s", strings.Join(e.Sources, ", "))
 }
 
 type NfdWorker interface {
@@ -93,7 +204,18 @@ type nfdWorker struct {
 	client         pb.LabelerClient
 	config         NFDConfig
 	sources        []source.FeatureSource
+	sourceNames    []string
 	labelWhiteList *regexp.Regexp
+	// labelFilters are the per-source label filters
+	labelFilters  map[string]*labelFilter
+	sleepInterval time.Duration
+	// rand is used for randomizing the sleep and retry intervals
+	rand      *rand.Rand
+	randMutex sync.Mutex
+	// masterCapabilities are the capabilities advertised by nfd-master
+	masterCapabilities map[string]bool
+	// clock is used for all time related operations, replaceable in tests
+	clock clock.Clock
 }
 
 // Create new NfdWorker instance.
@@ -101,11 +223,23 @@ func NewNfdWorker(args Args) (NfdWorker, error) {
 	nfd := &nfdWorker{
 		args:    args,
 		sources: []source.FeatureSource{},
+		clock:   clock.RealClock{},
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	// Route the logging of the feature sources to klog
+	if args.SourceVerbosity != nil {
+		sourceVerbosity = args.SourceVerbosity
+	}
+	setSourceLogger("")
+
+	if args.MaxRetryInterval <= 0 {
+		nfd.args.MaxRetryInterval = defaultMaxRetryInterval
 	}
 
 	if args.SleepInterval > 0 && args.SleepInterval < time.Second {
-		stderrLogger.Printf("WARNING: too short sleep-intervall specified (%s), forcing to 1s", args.SleepInterval.String())
-		args.SleepInterval = time.Second
+		klog.Warningf("too short sleep-intervall specified (%s), forcing to 1s", args.SleepInterval.String())
+		nfd.args.SleepInterval = time.Second
 	}
 
 	// Check TLS related args
@@ -121,82 +255,201 @@ func NewNfdWorker(args Args) (NfdWorker, error) {
 		}
 	}
 
-	// Figure out active sources
-	allSources := []source.FeatureSource{
+	if args.GetLabels && args.NoPublish {
+		return nfd, fmt.Errorf("--get-labels cannot be used together with --no-publish")
+	}
+
+	nfd.sources = enabledSources(args.Sources, args.LowMemory)
+	nfd.sourceNames = args.Sources
+
+	if args.LowMemory {
+		debug.SetGCPercent(lowMemoryGCPercent)
+	}
+
+	// Compile labelWhiteList regex
+	var err error
+	nfd.labelWhiteList, err = regexp.Compile(args.LabelWhiteList)
+	if err != nil {
+		return nfd, fmt.Errorf("error parsing label whitelist regex (%s): %s", args.LabelWhiteList, err)
+	}
+
+	return nfd, nil
+}
+
+// allSources returns new instances of all available feature sources
+func allSources() []source.FeatureSource {
+	return []source.FeatureSource{
 		&cpu.Source{},
+		&deviceplugin.Source{},
 		&fake.Source{},
+		&gpu.Source{},
 		&iommu.Source{},
 		&kernel.Source{},
 		&memory.Source{},
 		&network.Source{},
 		&panicfake.Source{},
 		&pci.Source{},
+		&power.Source{},
 		&storage.Source{},
 		&system.Source{},
 		&usb.Source{},
+		&virt.Source{},
 		&custom.Source{},
 		// local needs to be the last source so that it is able to override
 		// labels from other sources
 		&local.Source{},
 	}
+}
 
+// enabledSources returns the feature sources with the given names
+func enabledSources(names []string, lowMemory bool) []source.FeatureSource {
 	sourceWhiteList := map[string]struct{}{}
-	for _, s := range args.Sources {
+	for _, s := range names {
 		sourceWhiteList[strings.TrimSpace(s)] = struct{}{}
 	}
 
-	nfd.sources = []source.FeatureSource{}
-	for _, s := range allSources {
+	sources := []source.FeatureSource{}
+	for _, s := range allSources() {
 		if _, enabled := sourceWhiteList[s.Name()]; enabled {
-			nfd.sources = append(nfd.sources, s)
+			if _, heavy := heavyweightSources[s.Name()]; heavy && lowMemory {
+				klog.Infof("low-memory mode: disabling source %q", s.Name())
+				continue
+			}
+			sources = append(sources, s)
 		}
 	}
-
-	// Compile labelWhiteList regex
-	var err error
-	nfd.labelWhiteList, err = regexp.Compile(args.LabelWhiteList)
-	if err != nil {
-		return nfd, fmt.Errorf("error parsing label whitelist regex (%s): %s", args.LabelWhiteList, err)
-	}
-
-	return nfd, nil
+	return sources
 }
 
 // Run NfdWorker client. Returns if a fatal error is encountered, or, after
 // one request if OneShot is set to 'true' in the worker args.
 func (w *nfdWorker) Run() error {
-	stdoutLogger.Printf("Node Feature Discovery Worker %s", version.Get())
-	stdoutLogger.Printf("NodeName: '%s'", nodeName)
+	klog.Infof("Node Feature Discovery Worker %s", version.Get())
+	klog.Infof("NodeName: '%s'", nodeName)
 
-	// Connect to NFD master
-	err := w.connect()
-	if err != nil {
-		return fmt.Errorf("failed to connect: %v", err)
+	// Connect to NFD master, retrying with a backoff unless in one-shot mode
+	for failures := 0; ; failures++ {
+		err := w.connectToMaster()
+		if err == nil {
+			break
+		}
+		if w.args.Oneshot || w.args.GetLabels {
+			return err
+		}
+		d := w.retryInterval(failures)
+		klog.Warningf("%v, retrying in %v", err, d)
+		<-w.clock.After(d)
 	}
 	defer w.disconnect()
 
+	// Only query the published features, without running feature discovery
+	if w.args.GetLabels {
+		if !w.masterCapabilities[pb.CapabilityGetLabels] {
+			return fmt.Errorf("nfd-master does not support querying the published features")
+		}
+		features, err := getPublishedFeatures(w.client)
+		if err != nil {
+			return fmt.Errorf("failed to get published features: %v", err)
+		}
+		return writeJSON(features, w.args.Output)
+	}
+
+	// Re-publish promptly if the features are modified by someone else
+	republish := make(chan struct{}, 1)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if w.client != nil && w.masterCapabilities[pb.CapabilityWatchFeatures] && !w.args.Oneshot {
+		go w.watchFeatures(watchCtx, w.client, republish)
+	}
+
+	// Re-configure promptly if the config file is changed
+	configChanged := make(chan struct{}, 1)
+	if w.args.ConfigFile != "" && !w.args.Oneshot {
+		watcher, err := watchConfigFile(w.args.ConfigFile, configChanged)
+		if err != nil {
+			klog.Warningf("failed to watch config file for changes: %v", err)
+		} else {
+			defer watcher.Close()
+		}
+	}
+	if !w.args.Oneshot {
+		watcher, err := watchDropinDir(custom.DropinDir, configChanged)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				klog.Warningf("failed to watch custom rules directory for changes: %v", err)
+			}
+		} else {
+			defer watcher.Close()
+		}
+	}
+
+	failures := 0
 	for {
 		// Parse and apply configuration
 		w.configure(w.args.ConfigFile, w.args.Options)
 
-		// Get the set of feature labels.
-		labels := createFeatureLabels(w.sources, w.labelWhiteList)
+		// Get the set of feature labels, annotations, extended resources and
+		// taints.
+		features, failedSources := createFeatureLabels(w.sources, w.labelWhiteList, w.labelFilters)
 
-		// Update the node with the feature labels.
-		if w.client != nil {
-			err := advertiseFeatureLabels(w.client, labels)
+		// Write the features out in dry-run mode, update the node otherwise
+		if w.args.NoPublish {
+			if err := writeFeatures(features, w.args.Output); err != nil {
+				if w.args.Oneshot {
+					return err
+				}
+				klog.Errorf("%v", err)
+			}
+		} else if w.client != nil {
+			if len(features.Annotations) > 0 && !w.masterCapabilities[pb.CapabilityFeatureAnnotations] {
+				klog.Warningf("nfd-master does not support feature annotations, not advertising %d annotations", len(features.Annotations))
+				features.Annotations = Annotations{}
+			}
+			if len(features.ExtendedResources) > 0 && !w.masterCapabilities[pb.CapabilityExtendedResources] {
+				klog.Warningf("nfd-master does not support extended resources, not advertising %d extended resources", len(features.ExtendedResources))
+				features.ExtendedResources = ExtendedResources{}
+			}
+			if len(features.Taints) > 0 && !w.masterCapabilities[pb.CapabilityTaints] {
+				klog.Warningf("nfd-master does not support taints or has them disabled, not requesting %d taints", len(features.Taints))
+				features.Taints = Taints{}
+			}
+			err := advertiseFeatureLabels(w.client, features)
 			if err != nil {
-				return fmt.Errorf("failed to advertise labels: %s", err.Error())
+				if w.args.Oneshot {
+					return fmt.Errorf("failed to advertise labels: %s", err.Error())
+				}
+				// Retry with a backoff, gRPC re-establishes the connection
+				d := w.retryInterval(failures)
+				failures++
+				klog.Errorf("failed to advertise labels: %v, retrying in %v", err, d)
+				<-w.clock.After(d)
+				continue
 			}
+			failures = 0
 		}
 
 		if w.args.Oneshot {
+			if len(failedSources) > 0 {
+				return &DiscoveryError{Sources: failedSources}
+			}
 			break
 		}
 
-		if w.args.SleepInterval > 0 {
-			time.Sleep(w.args.SleepInterval)
+		if w.args.LowMemory {
+			// Return memory used during discovery back to the OS
+			debug.FreeOSMemory()
+		}
+
+		if w.sleepInterval > 0 {
+			select {
+			case <-w.clock.After(w.jitteredInterval(w.sleepInterval, w.config.Core.SleepJitter)):
+			case <-republish:
+				klog.Infof("re-publishing features")
+			case <-configChanged:
+				klog.Infof("configuration changed, re-labeling")
+			}
 		} else {
+			stopWatch()
 			w.disconnect()
 			// Sleep forever
 			select {}
@@ -205,6 +458,48 @@ func (w *nfdWorker) Run() error {
 	return nil
 }
 
+// writeFeatures writes the features as JSON to the given file, or to stdout if
+// the path is empty. The file is replaced on each call.
+func writeFeatures(features nodeFeatures, path string) error {
+	return writeJSON(features, path)
+}
+
+// writeJSON writes a value as indented JSON to the given file, or to stdout if
+// the path is empty
+func writeJSON(v interface{}, path string) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode features: %v", err)
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write features: %v", err)
+	}
+	return nil
+}
+
+// connectToMaster connects to nfd-master and queries its capabilities
+func (w *nfdWorker) connectToMaster() error {
+	if err := w.connect(); err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	if w.client != nil {
+		caps, err := getMasterCapabilities(w.client)
+		if err != nil {
+			w.disconnect()
+			return fmt.Errorf("failed to query nfd-master capabilities: %v", err)
+		}
+		w.masterCapabilities = caps
+	}
+	return nil
+}
+
 // connect creates a client connection to the NFD master
 func (w *nfdWorker) connect() error {
 	// Return a dummy connection in case of dry-run
@@ -220,32 +515,26 @@ func (w *nfdWorker) connect() error {
 	// Dial and create a client
 	dialCtx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
-	dialOpts := []grpc.DialOption{grpc.WithBlock()}
+	dialOpts := []grpc.DialOption{grpc.WithBlock(), grpc.WithBackoffMaxDelay(w.args.MaxRetryInterval)}
 	if w.args.CaFile != "" || w.args.CertFile != "" || w.args.KeyFile != "" {
-		// Load client cert for client authentication
-		cert, err := tls.LoadX509KeyPair(w.args.CertFile, w.args.KeyFile)
-		if err != nil {
-			return fmt.Errorf("failed to load client certificate: %v", err)
+		// The server certificate is verified against the name of the
+		// server, unless overridden
+		serverName := w.args.ServerNameOverride
+		if serverName == "" {
+			serverName, _, _ = net.SplitHostPort(w.args.Server)
 		}
-		// Load CA cert for server cert verification
-		caCert, err := ioutil.ReadFile(w.args.CaFile)
+		// Certificates are reloaded from disk when rotated
+		reloader, err := newCertReloader(w.args.CertFile, w.args.KeyFile, w.args.CaFile, serverName)
 		if err != nil {
-			return fmt.Errorf("failed to read root certificate file: %v", err)
-		}
-		caPool := x509.NewCertPool()
-		if ok := caPool.AppendCertsFromPEM(caCert); !ok {
-			return fmt.Errorf("failed to add certificate from '%s'", w.args.CaFile)
+			return err
 		}
-		// Create TLS config
-		tlsConfig := &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			RootCAs:      caPool,
-			ServerName:   w.args.ServerNameOverride,
-		}
-		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(reloader.tlsConfig())))
 	} else {
 		dialOpts = append(dialOpts, grpc.WithInsecure())
 	}
+	if w.args.Compression == gzip.Name {
+		dialOpts = append(dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
 	conn, err := grpc.DialContext(dialCtx, w.args.Server, dialOpts...)
 	if err != nil {
 		return err
@@ -265,146 +554,649 @@ func (w *nfdWorker) disconnect() {
 	w.client = nil
 }
 
+// watchConfigFile watches the given config file for changes, signalling them
+// through the given channel. The parent directory is watched so that updates
+// of Kubernetes ConfigMap mounts, done by swapping symlinks, are detected.
+func watchConfigFile(path string, changed chan<- struct{}) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// ConfigMap mounts are updated by replacing the ..data symlink
+				name := filepath.Base(e.Name)
+				if name == filepath.Base(path) || name == "..data" {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("error watching config file: %v", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+// watchDropinDir watches a drop-in directory and its immediate
+// subdirectories, e.g. ConfigMap mounts, signalling any changes through the
+// given channel
+func watchDropinDir(dir string, changed chan<- struct{}) (*fsnotify.Watcher, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	addWatch := func(path string) error {
+		if stat, err := os.Stat(path); err != nil || !stat.IsDir() {
+			return err
+		}
+		return watcher.Add(path)
+	}
+	if err := addWatch(dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") {
+			if err := addWatch(filepath.Join(dir, e.Name())); err != nil {
+				klog.Warningf("failed to watch %s: %v", e.Name(), err)
+			}
+		}
+	}
+
+	go func() {
+		for {
+			select {
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// Start watching new subdirectories
+				if e.Op&fsnotify.Create != 0 && filepath.Dir(e.Name) == filepath.Clean(dir) &&
+					!strings.HasPrefix(filepath.Base(e.Name), ".") {
+					if err := addWatch(e.Name); err != nil {
+						klog.Warningf("failed to watch %s: %v", e.Name, err)
+					}
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				klog.Warningf("error watching %s: %v", dir, err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
 // Parse configuration options
 func (w *nfdWorker) configure(filepath string, overrides string) {
-	// Create a new default config
-	c := NFDConfig{Sources: make(map[string]source.Config, len(w.sources))}
-	for _, s := range w.sources {
+	// Create a new default config. Defaults are created for all sources as
+	// the set of enabled sources may be changed in the config.
+	c := NFDConfig{Sources: sourcesConfig{}}
+	for _, s := range allSources() {
 		c.Sources[s.Name()] = s.NewConfig()
 	}
 
 	// Try to read and parse config file
 	data, err := ioutil.ReadFile(filepath)
 	if err != nil {
-		stderrLogger.Printf("Failed to read config file: %s", err)
+		klog.Errorf("Failed to read config file: %s", err)
 	} else {
 		err = yaml.Unmarshal(data, &c)
 		if err != nil {
-			stderrLogger.Printf("Failed to parse config file: %s", err)
+			klog.Errorf("Failed to parse config file: %s", err)
 		} else {
-			stdoutLogger.Printf("Configuration successfully loaded from %q", filepath)
+			klog.Infof("Configuration successfully loaded from %q", filepath)
 		}
 	}
 
 	// Parse config overrides
 	err = yaml.Unmarshal([]byte(overrides), &c)
 	if err != nil {
-		stderrLogger.Printf("Failed to parse --options: %s", err)
+		klog.Errorf("Failed to parse --options: %s", err)
 	}
 
 	w.config = c
 
+	// Settings of the config file override command line flags
+	sourceNames := w.args.Sources
+	if len(c.Core.LabelSources) > 0 {
+		sourceNames = c.Core.LabelSources
+	} else if len(c.Core.Sources) > 0 {
+		klog.Warningf("core.sources is deprecated, use core.labelSources instead")
+		sourceNames = c.Core.Sources
+	}
+	sourceNames = c.applySourceSwitches(sourceNames)
+	if !reflect.DeepEqual(sourceNames, w.sourceNames) {
+		klog.Infof("enabling sources %s", strings.Join(sourceNames, ","))
+		w.sources = enabledSources(sourceNames, w.args.LowMemory)
+		w.sourceNames = sourceNames
+	}
+
+	whiteList := w.args.LabelWhiteList
+	if c.Core.LabelWhiteList != "" {
+		whiteList = c.Core.LabelWhiteList
+	}
+	if whiteList != w.labelWhiteList.String() {
+		if re, err := regexp.Compile(whiteList); err != nil {
+			klog.Errorf("Failed to parse label whitelist %q: %s", whiteList, err)
+		} else {
+			w.labelWhiteList = re
+		}
+	}
+
+	labelFilters := map[string]*labelFilter{}
+	for name, fc := range c.sourceLabelFilters {
+		f, err := newLabelFilter(fc)
+		if err != nil {
+			klog.Errorf("Failed to parse label filters of source %q: %s", name, err)
+			f = w.labelFilters[name]
+		}
+		if f != nil {
+			labelFilters[name] = f
+		}
+	}
+	w.labelFilters = labelFilters
+
+	w.sleepInterval = w.args.SleepInterval
+	if c.Core.SleepInterval != nil {
+		w.sleepInterval = c.Core.SleepInterval.Duration
+		if w.sleepInterval > 0 && w.sleepInterval < time.Second {
+			klog.Warningf("too short sleepInterval specified (%s), forcing to 1s", w.sleepInterval)
+			w.sleepInterval = time.Second
+		}
+	}
+	if c.Core.SleepJitter < 0 || c.Core.SleepJitter > 100 {
+		klog.Errorf("Invalid sleepJitter %d, must be between 0 and 100", c.Core.SleepJitter)
+		w.config.Core.SleepJitter = 0
+	}
+
 	// (Re-)configure all sources
 	for _, s := range w.sources {
 		s.SetConfig(c.Sources[s.Name()])
 	}
 }
 
-// createFeatureLabels returns the set of feature labels from the enabled
-// sources and the whitelist argument.
-func createFeatureLabels(sources []source.FeatureSource, labelWhiteList *regexp.Regexp) (labels Labels) {
-	labels = Labels{}
+// createFeatureLabels returns the set of feature labels, annotations, extended
+// resources and taints from the enabled sources, filtered by the whitelist
+// argument and the per-source label filters. The names of the sources whose
+// discovery failed are also returned.
+func createFeatureLabels(sources []source.FeatureSource, labelWhiteList *regexp.Regexp, labelFilters map[string]*labelFilter) (features nodeFeatures, failed []string) {
+	features = newNodeFeatures()
 
 	// Do feature discovery from all configured sources.
 	for _, source := range sources {
-		labelsFromSource, err := getFeatureLabels(source, labelWhiteList)
+		fromSource, err := getFeatureLabels(source, labelWhiteList, labelFilters[source.Name()])
 		if err != nil {
-			stderrLogger.Printf("discovery failed for source [%s]: %s", source.Name(), err.Error())
-			stderrLogger.Printf("continuing ...")
+			klog.Errorf("discovery failed for source [%s]: %s", source.Name(), err.Error())
+			failed = append(failed, source.Name())
 			continue
 		}
 
-		for name, value := range labelsFromSource {
+		for name, value := range fromSource.Labels {
 			// Log discovered feature.
-			stdoutLogger.Printf("%s = %s", name, value)
-			labels[name] = value
+			if sourceV(source.Name(), 1) {
+				klog.Infof("%s = %s", name, value)
+			}
+			features.Labels[name] = value
 		}
+		for name, value := range fromSource.Annotations {
+			if sourceV(source.Name(), 1) {
+				klog.Infof("%s = %q (annotation)", name, value)
+			}
+			features.Annotations[name] = value
+		}
+		for name, value := range fromSource.ExtendedResources {
+			if sourceV(source.Name(), 1) {
+				klog.Infof("%s = %s (extended resource)", name, value)
+			}
+			features.ExtendedResources[name] = value
+		}
+		for _, t := range fromSource.Taints {
+			if sourceV(source.Name(), 1) {
+				klog.Infof("%s=%s:%s (taint)", t.Key, t.Value, t.Effect)
+			}
+			features.Taints = append(features.Taints, t)
+		}
+		features.RawFeatures[source.Name()] = fromSource.RawFeatures[source.Name()]
 	}
-	return labels
+	return features, failed
 }
 
-// getFeatureLabels returns node labels for features discovered by the
-// supplied source.
-func getFeatureLabels(source source.FeatureSource, labelWhiteList *regexp.Regexp) (labels Labels, err error) {
+// getFeatureLabels returns node labels, annotations, extended resources and
+// taints for features discovered by the supplied source. The optional filter
+// is applied in addition to the whitelist.
+func getFeatureLabels(fs source.FeatureSource, labelWhiteList *regexp.Regexp, filter *labelFilter) (nf nodeFeatures, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			stderrLogger.Printf("panic occurred during discovery of source [%s]: %v", source.Name(), r)
+			klog.Errorf("panic occurred during discovery of source [%s]: %v", fs.Name(), r)
 			err = fmt.Errorf("%v", r)
 		}
 	}()
 
-	labels = Labels{}
-	features, err := source.Discover()
+	// Attribute the log messages of the source to it
+	setSourceLogger(fs.Name())
+	defer setSourceLogger("")
+
+	nf = newNodeFeatures()
+	features, err := fs.Discover()
 	if err != nil {
-		return nil, err
+		return nodeFeatures{}, err
 	}
+	nf.RawFeatures[fs.Name()] = features
 
 	// Prefix for labels in the default namespace
-	prefix := source.Name() + "-"
-	switch source.(type) {
+	prefix := fs.Name() + "-"
+	switch fs.(type) {
 	case *local.Source:
 		// Do not prefix labels from the hooks
 		prefix = ""
 	}
 
 	for k, v := range features {
-		// Split label name into namespace and name compoents. Use dummy 'ns'
-		// default namespace because there is no function to validate just
-		// the name part
-		split := strings.SplitN(k, "/", 2)
-
-		label := prefix + split[0]
-		nameForValidation := "ns/" + label
-		nameForWhiteListing := label
-
-		if len(split) == 2 {
-			label = k
-			nameForValidation = label
-			nameForWhiteListing = split[1]
+		label, ok := featureName(fs.Name(), prefix, k, labelWhiteList, filter)
+		if !ok {
+			continue
 		}
 
-		// Validate label name.
-		errs := validation.IsQualifiedName(nameForValidation)
-		if len(errs) > 0 {
-			stderrLogger.Printf("Ignoring invalid feature name '%s': %s", label, errs)
+		// Annotation values are not restricted like label values
+		if a, ok := v.(source.AnnotationFeatureValue); ok {
+			nf.Annotations[label] = string(a)
+			continue
+		}
+		if r, ok := v.(source.ExtendedResourceFeatureValue); ok {
+			nf.ExtendedResources[label] = strconv.FormatInt(int64(r), 10)
 			continue
 		}
-
 		value := fmt.Sprintf("%v", v)
-		// Validate label value
-		errs = validation.IsValidLabelValue(value)
+		// Validate label value, sanitizing invalid values so that one bad
+		// feature does not cause the whole update to be rejected
+		errs := validation.IsValidLabelValue(value)
 		if len(errs) > 0 {
-			stderrLogger.Printf("Ignoring invalid feature value %s=%s: %s", label, value, errs)
-			continue
+			sanitized := sanitizeLabelValue(value)
+			if sanitized == "" {
+				klog.Warningf("Ignoring invalid feature value %s=%s: %s", label, value, errs)
+				continue
+			}
+			klog.Warningf("Sanitized invalid feature value %s=%q into %q: %s", label, value, sanitized, errs)
+			value = sanitized
 		}
 
-		// Skip if label doesn't match labelWhiteList
-		if !labelWhiteList.MatchString(nameForWhiteListing) {
-			stderrLogger.Printf("%q does not match the whitelist (%s) and will not be published.", nameForWhiteListing, labelWhiteList.String())
-			continue
+		nf.Labels[label] = value
+	}
+
+	// Taint keys are named like feature labels
+	if ts, ok := fs.(source.TaintSource); ok {
+		for _, t := range ts.Taints() {
+			key, ok := featureName(fs.Name(), prefix, t.Key, labelWhiteList, filter)
+			if !ok {
+				continue
+			}
+			nf.Taints = append(nf.Taints, &pb.Taint{Key: key, Value: t.Value, Effect: t.Effect})
+		}
+	}
+	return nf, nil
+}
+
+// featureName returns the label name of a feature discovered by a source,
+// prefixing names without a namespace. False is returned if the name is
+// invalid or filtered out by the whitelist or the optional per-source filter.
+func featureName(sourceName, prefix, k string, labelWhiteList *regexp.Regexp, filter *labelFilter) (string, bool) {
+	// Split label name into namespace and name compoents. Use dummy 'ns'
+	// default namespace because there is no function to validate just
+	// the name part
+	split := strings.SplitN(k, "/", 2)
+
+	label := prefix + split[0]
+	nameForValidation := "ns/" + label
+	nameForWhiteListing := label
+
+	if len(split) == 2 {
+		label = k
+		nameForValidation = label
+		nameForWhiteListing = split[1]
+	}
+
+	// Validate label name.
+	errs := validation.IsQualifiedName(nameForValidation)
+	if len(errs) > 0 {
+		klog.Warningf("Ignoring invalid feature name '%s': %s", label, errs)
+		return "", false
+	}
+
+	// Skip if label doesn't match labelWhiteList
+	if !labelWhiteList.MatchString(nameForWhiteListing) {
+		klog.Warningf("%q does not match the whitelist (%s) and will not be published.", nameForWhiteListing, labelWhiteList.String())
+		return "", false
+	}
+	// Skip if label is filtered out by the per-source filter
+	if filter != nil && !filter.match(nameForWhiteListing) {
+		if sourceV(sourceName, 1) {
+			klog.Infof("%q is filtered out by the label filters of source [%s] and will not be published.", nameForWhiteListing, sourceName)
 		}
+		return "", false
+	}
+	return label, true
+}
 
-		labels[label] = value
+// sanitizeLabelValue turns an arbitrary string into a valid label value by
+// replacing disallowed characters with underscores, truncating it to the
+// maximum length and trimming non-alphanumeric characters from both ends. An
+// empty string is returned if nothing remains.
+func sanitizeLabelValue(value string) string {
+	b := []byte(value)
+	for i, c := range b {
+		if !isAlphaNum(c) && c != '-' && c != '_' && c != '.' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > validation.LabelValueMaxLength {
+		b = b[:validation.LabelValueMaxLength]
+	}
+	for len(b) > 0 && !isAlphaNum(b[0]) {
+		b = b[1:]
 	}
-	return labels, nil
+	for len(b) > 0 && !isAlphaNum(b[len(b)-1]) {
+		b = b[:len(b)-1]
+	}
+	return string(b)
+}
+
+func isAlphaNum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
 
-// advertiseFeatureLabels advertises the feature labels to a Kubernetes node
-// via the NFD server.
-func advertiseFeatureLabels(client pb.LabelerClient, labels Labels) error {
+// getMasterCapabilities queries the capabilities of nfd-master. Masters that
+// do not implement the query are considered to have no capabilities.
+func getMasterCapabilities(client pb.LabelerClient) (map[string]bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	stdoutLogger.Printf("Sending labeling request to nfd-master")
+	caps := map[string]bool{}
+	reply, err := client.GetCapabilities(ctx, &pb.GetCapabilitiesRequest{NfdVersion: version.Get()})
+	if status.Code(err) == codes.Unimplemented {
+		klog.Warningf("nfd-master does not support capability discovery, assuming an old version")
+		return caps, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("nfd-master %s capabilities: %s", reply.NfdVersion, strings.Join(reply.Capabilities, ", "))
+	for _, c := range reply.Capabilities {
+		caps[c] = true
+	}
+	return caps, nil
+}
+
+// jitteredInterval randomizes an interval by up to the given percentage in
+// either direction. This prevents large numbers of workers from labeling
+// their nodes simultaneously.
+func (w *nfdWorker) jitteredInterval(interval time.Duration, jitter int) time.Duration {
+	max := int64(interval) * int64(jitter) / 100
+	if max <= 0 {
+		return interval
+	}
+	return interval + time.Duration(w.randInt63n(2*max+1)-max)
+}
+
+// retryInterval returns the delay before retrying a failed connection or
+// request to nfd-master. The delay grows exponentially with the number of
+// consecutive failures, up to the maximum retry interval, and is randomized
+// between half and full length so that workers do not retry in lockstep e.g.
+// after a restart of nfd-master.
+func (w *nfdWorker) retryInterval(failures int) time.Duration {
+	interval := w.args.MaxRetryInterval
+	if failures < 32 {
+		if d := retryInitialInterval << uint(failures); d < interval {
+			interval = d
+		}
+	}
+	half := int64(interval) / 2
+	if half <= 0 {
+		return interval
+	}
+	return time.Duration(half + w.randInt63n(half+1))
+}
+
+// randInt63n returns a random number in [0,n), safe for concurrent use
+func (w *nfdWorker) randInt63n(n int64) int64 {
+	w.randMutex.Lock()
+	defer w.randMutex.Unlock()
+	return w.rand.Int63n(n)
+}
 
-	labelReq := pb.SetLabelsRequest{Labels: labels,
-		NfdVersion: version.Get(),
-		NodeName:   nodeName}
-	_, err := client.SetLabels(ctx, &labelReq)
+// watchFeatures watches the features published for this node until the
+// context is cancelled, signalling the need to re-publish through the given
+// channel whenever they are modified by someone else. The watch is renewed
+// if it fails.
+func (w *nfdWorker) watchFeatures(ctx context.Context, client pb.LabelerClient, republish chan<- struct{}) {
+	failures := 0
+	for {
+		start := w.clock.Now()
+		err := receiveFeatureEvents(ctx, client, republish)
+		if ctx.Err() != nil {
+			return
+		}
+		// Start over with the backoff if the watch worked for a while
+		if w.clock.Since(start) > w.args.MaxRetryInterval {
+			failures = 0
+		}
+		d := w.retryInterval(failures)
+		failures++
+		klog.Warningf("watching node features failed: %v, retrying in %v", err, d)
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.clock.After(d):
+		}
+	}
+}
+
+// receiveFeatureEvents receives notifications about modifications of the
+// features published for this node until the stream ends
+func receiveFeatureEvents(ctx context.Context, client pb.LabelerClient, republish chan<- struct{}) error {
+	stream, err := client.WatchFeatures(ctx, &pb.WatchFeaturesRequest{NodeName: nodeName})
+	if err != nil {
+		return err
+	}
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		klog.Infof("features of node %q %s by someone else", e.NodeName, e.Reason)
+		select {
+		case republish <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// advertiseFeatureLabels advertises the feature labels, annotations, extended
+// resources and taints to a Kubernetes node via the NFD server.
+func advertiseFeatureLabels(client pb.LabelerClient, features nodeFeatures) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	klog.Infof("Sending labeling request to nfd-master")
+
+	labelReq := pb.SetLabelsRequest{Labels: features.Labels,
+		Annotations:       features.Annotations,
+		ExtendedResources: features.ExtendedResources,
+		Taints:            features.Taints,
+		NfdVersion:        version.Get(),
+		NodeName:          nodeName}
+	reply, err := client.SetLabels(ctx, &labelReq)
+	if err != nil {
+		klog.Errorf("failed to set node labels: %v", err)
+		return err
+	}
+	for _, r := range reply.GetRejected() {
+		klog.Warningf("%s '%s' was rejected by nfd-master: %s", r.Kind, r.Name, r.Reason)
+	}
+
+	return nil
+}
+
+// publishedFeatures are the features nfd-master has published for a node
+type publishedFeatures struct {
+	Labels            Labels            `json:"labels"`
+	Annotations       Annotations       `json:"annotations"`
+	ExtendedResources ExtendedResources `json:"extendedResources"`
+}
+
+// getPublishedFeatures queries the feature labels, feature annotations and
+// extended resources published for this node from nfd-master. All names are
+// fully qualified.
+func getPublishedFeatures(client pb.LabelerClient) (publishedFeatures, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	reply, err := client.GetLabels(ctx, &pb.GetLabelsRequest{NodeName: nodeName})
 	if err != nil {
-		stderrLogger.Printf("failed to set node labels: %v", err)
+		return publishedFeatures{}, err
+	}
+	f := publishedFeatures{
+		Labels:            Labels(reply.Labels),
+		Annotations:       Annotations(reply.Annotations),
+		ExtendedResources: ExtendedResources(reply.ExtendedResources),
+	}
+	if f.Labels == nil {
+		f.Labels = Labels{}
+	}
+	if f.Annotations == nil {
+		f.Annotations = Annotations{}
+	}
+	if f.ExtendedResources == nil {
+		f.ExtendedResources = ExtendedResources{}
+	}
+	return f, nil
+}
+
+// applySourceSwitches applies the per-source enabled settings on a list of
+// source names
+func (c *NFDConfig) applySourceSwitches(names []string) []string {
+	ret := []string{}
+	listed := map[string]bool{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		listed[n] = true
+		if enabled, ok := c.sourceEnabled[n]; !ok || enabled {
+			ret = append(ret, n)
+		}
+	}
+
+	extra := []string{}
+	for n, enabled := range c.sourceEnabled {
+		if enabled && !listed[n] {
+			extra = append(extra, n)
+		}
+	}
+	sort.Strings(extra)
+
+	return append(ret, extra...)
+}
+
+// newLabelFilter compiles the label filtering settings of a source
+func newLabelFilter(c labelFilterConfig) (*labelFilter, error) {
+	f := &labelFilter{}
+	var err error
+	if c.WhiteList != "" {
+		if f.whiteList, err = regexp.Compile(c.WhiteList); err != nil {
+			return nil, fmt.Errorf("invalid labelWhiteList: %v", err)
+		}
+	}
+	if c.DenyList != "" {
+		if f.denyList, err = regexp.Compile(c.DenyList); err != nil {
+			return nil, fmt.Errorf("invalid labelDenyList: %v", err)
+		}
+	}
+	return f, nil
+}
+
+// match returns true if a label name passes the filter
+func (f *labelFilter) match(name string) bool {
+	if f.whiteList != nil && !f.whiteList.MatchString(name) {
+		return false
+	}
+	return f.denyList == nil || !f.denyList.MatchString(name)
+}
+
+// UnmarshalJSON implements the Unmarshaler interface from "encoding/json"
+func (c *NFDConfig) UnmarshalJSON(data []byte) error {
+	// Parse everything but the per-source enabled and label filter settings
+	type config NFDConfig
+	if err := json.Unmarshal(data, (*config)(c)); err != nil {
+		return err
+	}
+
+	raw := struct {
+		Sources map[string]json.RawMessage
+	}{}
+	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
+	for name, rawv := range raw.Sources {
+		// Not all source configs are objects, e.g. that of the custom source
+		s := struct {
+			Enabled        *bool   `json:"enabled"`
+			LabelWhiteList *string `json:"labelWhiteList"`
+			LabelDenyList  *string `json:"labelDenyList"`
+		}{}
+		if err := json.Unmarshal(rawv, &s); err != nil {
+			continue
+		}
+		if s.Enabled != nil {
+			if c.sourceEnabled == nil {
+				c.sourceEnabled = map[string]bool{}
+			}
+			c.sourceEnabled[name] = *s.Enabled
+		}
+		if s.LabelWhiteList != nil || s.LabelDenyList != nil {
+			if c.sourceLabelFilters == nil {
+				c.sourceLabelFilters = map[string]labelFilterConfig{}
+			}
+			f := c.sourceLabelFilters[name]
+			if s.LabelWhiteList != nil {
+				f.WhiteList = *s.LabelWhiteList
+			}
+			if s.LabelDenyList != nil {
+				f.DenyList = *s.LabelDenyList
+			}
+			c.sourceLabelFilters[name] = f
+		}
+	}
 
 	return nil
 }
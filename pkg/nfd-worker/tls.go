@@ -0,0 +1,164 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nfdworker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// certReloader keeps the client certificate and the root certificates of
+// nfd-worker up to date, reloading them whenever the files are modified. This
+// makes it possible to rotate short-lived certificates, e.g. issued by
+// cert-manager, without restarting nfd-worker.
+type certReloader struct {
+	certFile   string
+	keyFile    string
+	caFile     string
+	serverName string
+
+	sync.Mutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	modTimes []time.Time
+}
+
+// newCertReloader creates a new certReloader, loading the certificates. The
+// server certificate is verified against the given server name.
+func newCertReloader(certFile, keyFile, caFile, serverName string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, serverName: serverName}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// tlsConfig returns a TLS config using the current certificates on each
+// handshake. The server certificate is verified in VerifyPeerCertificate as
+// the root certificates of a TLS config cannot be changed afterwards.
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetClientCertificate:  r.getClientCertificate,
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: r.verifyPeerCertificate,
+	}
+}
+
+// reload loads the certificates if any of the files has been modified since
+// the last load
+func (r *certReloader) reload() error {
+	r.Lock()
+	defer r.Unlock()
+
+	modTimes := make([]time.Time, 0, 3)
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		stat, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes = append(modTimes, stat.ModTime())
+	}
+	if r.modTimes != nil && !modTimesChanged(r.modTimes, modTimes) {
+		return nil
+	}
+
+	// Load client cert for client authentication
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load client certificate: %v", err)
+	}
+	// Load CA cert for server cert verification
+	caCert, err := ioutil.ReadFile(r.caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read root certificate file: %v", err)
+	}
+	caPool := x509.NewCertPool()
+	if ok := caPool.AppendCertsFromPEM(caCert); !ok {
+		return fmt.Errorf("failed to add certificate from '%s'", r.caFile)
+	}
+
+	if r.modTimes != nil {
+		klog.Infof("reloaded TLS certificates")
+	}
+	r.cert = &cert
+	r.caPool = caPool
+	r.modTimes = modTimes
+	return nil
+}
+
+// modTimesChanged returns true if any of the modification times differ
+func modTimesChanged(prev, cur []time.Time) bool {
+	for i := range prev {
+		if !prev[i].Equal(cur[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// getClientCertificate implements the GetClientCertificate callback of
+// tls.Config. The previous certificate is used if reloading fails.
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if err := r.reload(); err != nil {
+		klog.Warningf("failed to reload TLS certificates, using the previous ones: %v", err)
+	}
+	r.Lock()
+	defer r.Unlock()
+	return r.cert, nil
+}
+
+// verifyPeerCertificate implements the VerifyPeerCertificate callback of
+// tls.Config, verifying the server certificate against the current root
+// certificates
+func (r *certReloader) verifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if err := r.reload(); err != nil {
+		klog.Warningf("failed to reload TLS certificates, using the previous ones: %v", err)
+	}
+	r.Lock()
+	caPool := r.caPool
+	r.Unlock()
+
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("failed to parse server certificate: %v", err)
+		}
+		certs[i] = cert
+	}
+	if len(certs) == 0 {
+		return fmt.Errorf("no server certificate received")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         caPool,
+		DNSName:       r.serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
@@ -28,6 +28,8 @@ var (
 	EtcDir = HostDir(pathPrefix + "etc")
 	// SysfsPath is where the /sys directory of the system to be inspected is located
 	SysfsDir = HostDir(pathPrefix + "sys")
+	// VarDir is where the /var directory of the system to be inspected is located
+	VarDir = HostDir(pathPrefix + "var")
 )
 
 // HostDir is a helper for handling host system directories
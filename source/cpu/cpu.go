@@ -31,13 +31,14 @@ type cpuidConfig struct {
 }
 
 type Config struct {
-	Cpuid cpuidConfig `json:"cpuid,omitempty"`
+	Cpuid       cpuidConfig `json:"cpuid,omitempty"`
+	ModelLabels bool        `json:"modelLabels,omitempty"`
 }
 
 // newDefaultConfig returns a new config with pre-populated defaults
 func newDefaultConfig() *Config {
 	return &Config{
-		cpuidConfig{
+		Cpuid: cpuidConfig{
 			AttributeBlacklist: []string{
 				"BMI1",
 				"BMI2",
@@ -127,6 +128,23 @@ func (s *Source) Discover() (source.Features, error) {
 		}
 	}
 
+	// Detect CPU model, disabled by default to limit the number of labels
+	if s.config.ModelLabels {
+		for k, v := range discoverModel() {
+			features["model."+k] = v
+		}
+	}
+
+	// Detect cstate configuration
+	cstate, err := detectCstate()
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+	} else {
+		for k, v := range cstate {
+			features["cstate."+k] = v
+		}
+	}
+
 	// Detect pstate features
 	pstate, err := detectPstate()
 	if err != nil {
@@ -137,6 +155,24 @@ func (s *Source) Discover() (source.Features, error) {
 		}
 	}
 
+	// Detect mitigation state of cpu vulnerabilities
+	vulns, err := detectVulnerabilities()
+	if err != nil {
+		log.Printf("ERROR: %v", err)
+	} else {
+		for k, v := range vulns {
+			features["vuln."+k] = v
+		}
+	}
+
+	// Detect SGX features
+	if enabled, epcSize := discoverSGX(); enabled {
+		features["sgx.enabled"] = true
+		if epcSize > 0 {
+			features["sgx.epc"] = epcSize
+		}
+	}
+
 	// Detect RDT features
 	rdt := discoverRDT()
 	for _, f := range rdt {
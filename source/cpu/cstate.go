@@ -0,0 +1,52 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+)
+
+// Discover if c-states are enabled
+func detectCstate() (map[string]string, error) {
+	// Only the intel_idle driver is supported, skip other architectures
+	if runtime.GOARCH != "amd64" && runtime.GOARCH != "386" {
+		return nil, nil
+	}
+
+	driver, err := readSysfsAttr("devices/system/cpu/cpuidle/current_driver")
+	if err != nil {
+		return nil, fmt.Errorf("can't detect cpuidle driver: %v", err)
+	}
+	if driver != "intel_idle" {
+		// C-state configuration is only detected with the intel_idle driver
+		return nil, nil
+	}
+
+	// Deep c-states are disabled if max_cstate is set to zero
+	val, err := readSysfsAttr("module/intel_idle/parameters/max_cstate")
+	if err != nil {
+		return nil, fmt.Errorf("can't detect whether c-states are enabled: %v", err)
+	}
+	maxCstate, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_cstate value %q: %v", val, err)
+	}
+
+	return map[string]string{"enabled": strconv.FormatBool(maxCstate > 0)}, nil
+}
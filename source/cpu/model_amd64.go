@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/pkg/cpuid"
+)
+
+const (
+	// CPUID EAX input values
+	LEAF_VERSION_INFO = 0x01
+)
+
+// Short names of well-known CPU vendors
+var cpuVendors = map[string]string{
+	"GenuineIntel": "Intel",
+	"AuthenticAMD": "AMD",
+	"HygonGenuine": "Hygon",
+}
+
+// discoverModel returns the vendor, family, model and stepping of the CPU
+func discoverModel() map[string]string {
+	// The vendor string is stored in EBX, EDX and ECX
+	r := cpuid.Cpuid(LEAF_MAX_BASIC, 0)
+	buf := make([]byte, 12)
+	binary.LittleEndian.PutUint32(buf[0:], r.EBX)
+	binary.LittleEndian.PutUint32(buf[4:], r.EDX)
+	binary.LittleEndian.PutUint32(buf[8:], r.ECX)
+	vendor := strings.TrimSpace(string(buf))
+	if v, ok := cpuVendors[vendor]; ok {
+		vendor = v
+	}
+
+	// Extended family and model are only used with certain base families
+	eax := cpuid.Cpuid(LEAF_VERSION_INFO, 0).EAX
+	stepping := eax & 0xf
+	model := (eax >> 4) & 0xf
+	family := (eax >> 8) & 0xf
+	if family == 0x6 || family == 0xf {
+		model += ((eax >> 16) & 0xf) << 4
+	}
+	if family == 0xf {
+		family += (eax >> 20) & 0xff
+	}
+
+	return map[string]string{
+		"vendor_id": vendor,
+		"family":    strconv.Itoa(int(family)),
+		"id":        strconv.Itoa(int(model)),
+		"stepping":  strconv.Itoa(int(stepping)),
+	}
+}
@@ -20,11 +20,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"runtime"
+	"strconv"
+	"strings"
 
 	"sigs.k8s.io/node-feature-discovery/source"
 )
 
-// Discover p-state related features such as turbo boost.
+// Discover p-state related features such as turbo boost, the cpufreq scaling
+// driver and the scaling governor.
 func detectPstate() (map[string]string, error) {
 	// On other platforms, the frequency boost mechanism is software-based.
 	// So skip pstate detection on other architectures.
@@ -32,15 +35,36 @@ func detectPstate() (map[string]string, error) {
 		return nil, nil
 	}
 
-	// Only looking for turbo boost for now...
-	bytes, err := ioutil.ReadFile(source.SysfsDir.Path("devices/system/cpu/intel_pstate/no_turbo"))
-	if err != nil {
-		return nil, fmt.Errorf("can't detect whether turbo boost is enabled: %s", err.Error())
+	features := map[string]string{}
+
+	// The scaling driver and governor are read from the first CPU
+	if driver, err := readSysfsAttr("devices/system/cpu/cpu0/cpufreq/scaling_driver"); err == nil {
+		features["scaling_driver"] = driver
+	}
+	if governor, err := readSysfsAttr("devices/system/cpu/cpu0/cpufreq/scaling_governor"); err == nil {
+		features["scaling_governor"] = governor
+	}
+
+	// Turbo boost is controlled by intel_pstate or, with other drivers like
+	// acpi-cpufreq, by the generic cpufreq boost setting
+	if noTurbo, err := readSysfsAttr("devices/system/cpu/intel_pstate/no_turbo"); err == nil {
+		features["turbo"] = strconv.FormatBool(noTurbo == "0")
+	} else if boost, err := readSysfsAttr("devices/system/cpu/cpufreq/boost"); err == nil {
+		features["turbo"] = strconv.FormatBool(boost == "1")
 	}
-	features := map[string]string{"turbo": "false"}
-	if bytes[0] == byte('0') {
-		features["turbo"] = "true"
+
+	if len(features) == 0 {
+		return nil, fmt.Errorf("can't detect pstate features: cpufreq not available")
 	}
 
 	return features, nil
 }
+
+// readSysfsAttr reads a single-line attribute from sysfs
+func readSysfsAttr(path string) (string, error) {
+	data, err := ioutil.ReadFile(source.SysfsDir.Path(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"os"
+
+	"sigs.k8s.io/node-feature-discovery/pkg/cpuid"
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+const (
+	// CPUID EAX input values
+	LEAF_MAX_BASIC = 0x00
+	LEAF_SGX       = 0x12
+
+	// CPUID ECX input values
+	SGX_SUBLEAF_CAPABILITIES = 0
+	SGX_SUBLEAF_EPC_FIRST    = 2
+
+	// CPUID bitmasks
+	EXT_FEATURE_FLAGS_EBX_SGX  = 1 << 2
+	SGX_CAPABILITIES_EAX_SGX1  = 1 << 0
+	SGX_EPC_EAX_TYPE_MASK      = 0xf
+	SGX_EPC_EAX_TYPE_VALID     = 0x1
+	SGX_EPC_ECX_SIZE_LOW_MASK  = 0xfffff000
+	SGX_EPC_EDX_SIZE_HIGH_MASK = 0xfffff
+)
+
+// Sysfs paths that indicate an SGX driver being loaded: the in-tree driver,
+// the DCAP driver and the legacy out-of-tree driver
+var sgxDriverPaths = []string{
+	"class/misc/sgx_enclave",
+	"module/intel_sgx",
+	"module/isgx",
+}
+
+// discoverSGX detects if SGX is supported by the CPU and enabled by the
+// kernel. The total size of the EPC (Enclave Page Cache) in bytes is returned
+// as well.
+func discoverSGX() (enabled bool, epcSize uint64) {
+	extFeatures := cpuid.Cpuid(LEAF_EXT_FEATURE_FLAGS, 0)
+	if extFeatures.EBX&EXT_FEATURE_FLAGS_EBX_SGX == 0 {
+		return false, 0
+	}
+	if cpuid.Cpuid(LEAF_MAX_BASIC, 0).EAX < LEAF_SGX {
+		return false, 0
+	}
+	if cpuid.Cpuid(LEAF_SGX, SGX_SUBLEAF_CAPABILITIES).EAX&SGX_CAPABILITIES_EAX_SGX1 == 0 {
+		return false, 0
+	}
+
+	driver := false
+	for _, p := range sgxDriverPaths {
+		if _, err := os.Stat(source.SysfsDir.Path(p)); err == nil {
+			driver = true
+			break
+		}
+	}
+	if !driver {
+		return false, 0
+	}
+
+	// Sum up the sizes of all EPC sections, enumeration ends at the first
+	// invalid sub-leaf
+	for i := uint32(SGX_SUBLEAF_EPC_FIRST); ; i++ {
+		epc := cpuid.Cpuid(LEAF_SGX, i)
+		if epc.EAX&SGX_EPC_EAX_TYPE_MASK != SGX_EPC_EAX_TYPE_VALID {
+			break
+		}
+		epcSize += uint64(epc.ECX&SGX_EPC_ECX_SIZE_LOW_MASK) | uint64(epc.EDX&SGX_EPC_EDX_SIZE_HIGH_MASK)<<32
+	}
+
+	return true, epcSize
+}
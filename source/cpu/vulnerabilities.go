@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// Discover the mitigation state of CPU vulnerabilities, as reported by the
+// kernel. The state is one of "not_affected", "mitigated", "vulnerable" or
+// "unknown".
+func detectVulnerabilities() (map[string]string, error) {
+	dir := source.SysfsDir.Path("devices/system/cpu/vulnerabilities")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Not supported by the kernel
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can't detect cpu vulnerabilities: %v", err)
+	}
+
+	vulns := map[string]string{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("can't read status of cpu vulnerability %s: %v", file.Name(), err)
+		}
+
+		status := strings.TrimSpace(string(data))
+		switch {
+		case status == "Not affected":
+			vulns[file.Name()] = "not_affected"
+		case strings.HasPrefix(status, "Mitigation"):
+			vulns[file.Name()] = "mitigated"
+		case strings.HasPrefix(status, "Vulnerable"):
+			vulns[file.Name()] = "vulnerable"
+		default:
+			vulns[file.Name()] = "unknown"
+		}
+	}
+
+	return vulns, nil
+}
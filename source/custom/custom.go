@@ -17,7 +17,11 @@ limitations under the License.
 package custom
 
 import (
+	"bytes"
+	"fmt"
 	"log"
+	"strconv"
+	"text/template"
 
 	"sigs.k8s.io/node-feature-discovery/source"
 	"sigs.k8s.io/node-feature-discovery/source/custom/rules"
@@ -30,11 +34,38 @@ type MatchRule struct {
 	LoadedKMod *rules.LoadedKModRule `json:"loadedKMod,omitempty"`
 	CpuID      *rules.CpuIDRule      `json:"cpuId,omitempty"`
 	Kconfig    *rules.KconfigRule    `json:"kConfig,omitempty"`
+	Nodename   *rules.NodenameRule   `json:"nodename,omitempty"`
 }
 
+// MatchAnyElem is one alternative of the matchAny list of a feature
+type MatchAnyElem struct {
+	MatchFeatures rules.FeatureRules `json:"matchFeatures"`
+}
+
+// TaintSpec is a node taint requested by a custom feature
+type TaintSpec struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
+// FeatureSpec describes a custom feature. The feature is present if all the
+// given match terms match: one of the elements of matchOn, all elements of
+// matchFeatures and one of the elements of matchAny. If NameTemplate is
+// specified, one feature is created for each device matched by matchFeatures,
+// named by executing the template on the attributes of the device.
+// ExtendedResources maps names of node extended resources to capacity
+// templates. The capacity is the sum of the template executed on each matched
+// device, or the template executed once if no devices were matched. Taints
+// are requested for the node if the feature is present.
 type FeatureSpec struct {
-	Name    string      `json:"name"`
-	MatchOn []MatchRule `json:"matchOn"`
+	Name              string             `json:"name"`
+	NameTemplate      string             `json:"nameTemplate,omitempty"`
+	MatchOn           []MatchRule        `json:"matchOn,omitempty"`
+	MatchFeatures     rules.FeatureRules `json:"matchFeatures,omitempty"`
+	MatchAny          []MatchAnyElem     `json:"matchAny,omitempty"`
+	ExtendedResources map[string]string  `json:"extendedResources,omitempty"`
+	Taints            []TaintSpec        `json:"taints,omitempty"`
 }
 
 type config []FeatureSpec
@@ -44,9 +75,10 @@ func newDefaultConfig() *config {
 	return &config{}
 }
 
-// Implements FeatureSource Interface
+// Implements FeatureSource and TaintSource Interfaces
 type Source struct {
 	config *config
+	taints []source.Taint
 }
 
 // Return name of the feature source
@@ -68,29 +100,153 @@ func (s *Source) SetConfig(conf source.Config) {
 	}
 }
 
+// Taints method of the TaintSource interface
+func (s *Source) Taints() []source.Taint { return s.taints }
+
 // Discover features
-func (s Source) Discover() (source.Features, error) {
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
+	s.taints = nil
 	allFeatureConfig := append(getStaticFeatureConfig(), *s.config...)
+	allFeatureConfig = append(allFeatureConfig, getDropinFeatureConfig()...)
 	log.Printf("INFO: Custom features: %+v", allFeatureConfig)
 	// Iterate over features
 	for _, customFeature := range allFeatureConfig {
-		featureExist, err := s.discoverFeature(customFeature)
+		featureExist, instances, err := s.discoverFeature(customFeature)
 		if err != nil {
 			log.Printf("ERROR: failed to discover feature: %q: %s", customFeature.Name, err.Error())
 			continue
 		}
-		if featureExist {
+		if !featureExist {
+			continue
+		}
+		for name, capacityTemplate := range customFeature.ExtendedResources {
+			capacity, err := expandCapacityTemplate(capacityTemplate, instances)
+			if err != nil {
+				log.Printf("ERROR: failed to evaluate capacity of extended resource %q of feature %q: %s", name, customFeature.Name, err.Error())
+				continue
+			}
+			features[name] = source.ExtendedResourceFeatureValue(capacity)
+		}
+		for _, t := range customFeature.Taints {
+			s.taints = append(s.taints, source.Taint{Key: t.Key, Value: t.Value, Effect: t.Effect})
+		}
+		if customFeature.NameTemplate == "" {
 			features[customFeature.Name] = true
+			continue
+		}
+		names, err := expandNameTemplate(customFeature.NameTemplate, instances)
+		if err != nil {
+			log.Printf("ERROR: failed to expand name template of feature %q: %s", customFeature.Name, err.Error())
+			continue
+		}
+		for _, name := range names {
+			features[name] = true
 		}
 	}
 	return features, nil
 }
 
-// Process a single feature by Matching on the defined rules.
-// A feature is present if all defined Rules in a MatchRule return a match.
-func (s Source) discoverFeature(feature FeatureSpec) (bool, error) {
-	for _, rule := range feature.MatchOn {
+// Process a single feature by matching on all the defined match terms. The
+// devices matched by matchFeatures are returned.
+func (s Source) discoverFeature(feature FeatureSpec) (bool, []map[string]string, error) {
+	if len(feature.MatchOn) == 0 && len(feature.MatchFeatures) == 0 && len(feature.MatchAny) == 0 {
+		return false, nil, nil
+	}
+
+	if len(feature.MatchOn) > 0 {
+		match, err := matchOn(feature.MatchOn)
+		if err != nil || !match {
+			return false, nil, err
+		}
+	}
+
+	var instances []map[string]string
+	if len(feature.MatchFeatures) > 0 {
+		var match bool
+		var err error
+		match, instances, err = feature.MatchFeatures.MatchInstances()
+		if err != nil || !match {
+			return false, nil, err
+		}
+	}
+
+	if len(feature.MatchAny) > 0 {
+		match, err := matchAny(feature.MatchAny)
+		if err != nil || !match {
+			return false, nil, err
+		}
+	}
+
+	return true, instances, nil
+}
+
+// expandNameTemplate returns the feature names produced by executing the
+// template on each of the instances. An instance missing an attribute used
+// in the template makes the expansion fail.
+func expandNameTemplate(nameTemplate string, instances []map[string]string) ([]string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, i := range instances {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, i); err != nil {
+			return nil, err
+		}
+		names = append(names, buf.String())
+	}
+	return names, nil
+}
+
+// expandCapacityTemplate returns the capacity of an extended resource,
+// i.e. the sum of the template executed on each of the instances. Without
+// instances the template is executed once without data. As with names, an
+// instance missing an attribute used in the template makes it fail.
+func expandCapacityTemplate(capacityTemplate string, instances []map[string]string) (int64, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(capacityTemplate)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(instances) == 0 {
+		instances = []map[string]string{{}}
+	}
+	var capacity int64
+	for _, i := range instances {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, i); err != nil {
+			return 0, err
+		}
+		n, err := strconv.ParseInt(buf.String(), 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid capacity %q", buf.String())
+		}
+		capacity += n
+	}
+	return capacity, nil
+}
+
+// matchAny returns true if the features of one of the elements match
+func matchAny(elems []MatchAnyElem) (bool, error) {
+	for _, e := range elems {
+		match, err := e.MatchFeatures.Match()
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchOn returns true if one of the MatchRules matches. A MatchRule matches
+// if all defined Rules in it return a match.
+func matchOn(matchRules []MatchRule) (bool, error) {
+	for _, rule := range matchRules {
 		// PCI ID rule
 		if rule.PciID != nil {
 			match, err := rule.PciID.Match()
@@ -141,6 +297,16 @@ func (s Source) discoverFeature(feature FeatureSpec) (bool, error) {
 				continue
 			}
 		}
+		// nodename rule
+		if rule.Nodename != nil {
+			match, err := rule.Nodename.Match()
+			if err != nil {
+				return false, err
+			}
+			if !match {
+				continue
+			}
+		}
 		return true, nil
 	}
 	return false, nil
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testInstances = []map[string]string{
+	{"vendor": "8086", "device": "1572", "sriov_totalvfs": "64", "sriov_numvfs": "8"},
+	{"vendor": "8086", "device": "1572", "sriov_totalvfs": "64", "sriov_numvfs": "4"},
+	{"vendor": "8086", "device": "10fb", "sriov_totalvfs": "63", "sriov_numvfs": "0"},
+}
+
+func TestExpandNameTemplate(t *testing.T) {
+	Convey("When expanding feature name templates", t, func() {
+		Convey("One name should be produced for each instance", func() {
+			names, err := expandNameTemplate("intel-{{ .device }}.present", testInstances)
+			So(err, ShouldBeNil)
+			So(names, ShouldResemble, []string{"intel-1572.present", "intel-1572.present", "intel-10fb.present"})
+		})
+
+		Convey("No names should be produced without instances", func() {
+			names, err := expandNameTemplate("intel-{{ .device }}.present", nil)
+			So(err, ShouldBeNil)
+			So(names, ShouldBeEmpty)
+		})
+
+		Convey("An instance missing an attribute should make the expansion fail", func() {
+			instances := append([]map[string]string{{"vendor": "8086", "device": "1533"}}, testInstances...)
+			_, err := expandNameTemplate("intel-{{ .device }}-{{ .sriov_totalvfs }}", instances)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid templates should produce an error", func() {
+			_, err := expandNameTemplate("intel-{{ .device ", testInstances)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestExpandCapacityTemplate(t *testing.T) {
+	Convey("When expanding extended resource capacity templates", t, func() {
+		Convey("The capacities of all instances should be summed up", func() {
+			capacity, err := expandCapacityTemplate("{{ .sriov_numvfs }}", testInstances)
+			So(err, ShouldBeNil)
+			So(capacity, ShouldEqual, 12)
+		})
+
+		Convey("Constant capacities should be multiplied by the number of instances", func() {
+			capacity, err := expandCapacityTemplate("2", testInstances)
+			So(err, ShouldBeNil)
+			So(capacity, ShouldEqual, 6)
+		})
+
+		Convey("Without instances the template should be executed once", func() {
+			capacity, err := expandCapacityTemplate("3", nil)
+			So(err, ShouldBeNil)
+			So(capacity, ShouldEqual, 3)
+
+			_, err = expandCapacityTemplate("{{ .sriov_numvfs }}", nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("An instance missing an attribute should make the expansion fail", func() {
+			instances := append([]map[string]string{{"vendor": "8086", "device": "1533"}}, testInstances...)
+			_, err := expandCapacityTemplate("{{ .sriov_numvfs }}", instances)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Non-integer and negative capacities should produce an error", func() {
+			_, err := expandCapacityTemplate("{{ .vendor }}x", testInstances)
+			So(err, ShouldNotBeNil)
+			_, err = expandCapacityTemplate("-1", nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Invalid templates should produce an error", func() {
+			_, err := expandCapacityTemplate("{{ .sriov_numvfs ", testInstances)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package custom
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DropinDir is the directory of drop-in files of custom rules
+var DropinDir = "/etc/kubernetes/node-feature-discovery/custom.d/"
+
+// getDropinFeatureConfig reads the custom features specified in the drop-in
+// directory. Files in the directory and in its immediate subdirectories, e.g.
+// mounted from ConfigMaps, are read in lexical order. Hidden files are skipped.
+func getDropinFeatureConfig() []FeatureSpec {
+	features := []FeatureSpec{}
+
+	files, err := readDropinDir(DropinDir, true)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("ERROR: failed to read custom rules directory %s: %v", DropinDir, err)
+		}
+		return features
+	}
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Printf("ERROR: failed to read custom rules file %s: %v", file, err)
+			continue
+		}
+		specs := []FeatureSpec{}
+		if err := yaml.Unmarshal(data, &specs); err != nil {
+			log.Printf("ERROR: failed to parse custom rules file %s: %v", file, err)
+			continue
+		}
+		features = append(features, specs...)
+	}
+	return features
+}
+
+// readDropinDir returns the paths of the regular files in a directory and,
+// optionally, in its subdirectories
+func readDropinDir(dir string, recurse bool) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+
+		// Follow symlinks, used in ConfigMap mounts
+		stat, err := os.Stat(path)
+		if err != nil {
+			log.Printf("ERROR: skipping %s, failed to get stat: %v", path, err)
+			continue
+		}
+		if stat.IsDir() {
+			if recurse {
+				subFiles, err := readDropinDir(path, false)
+				if err != nil {
+					log.Printf("ERROR: skipping %s: %v", path, err)
+					continue
+				}
+				files = append(files, subFiles...)
+			}
+		} else if stat.Mode().IsRegular() {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+
+	pciutils "sigs.k8s.io/node-feature-discovery/source/internal"
+)
+
+// FeatureRule implements Rule, matching a set of expressions against the
+// attributes of one feature of the system. Supported features are cpu.cpuid
+// (cpuid flags), kernel.config (kernel configuration options),
+// kernel.loadedmodule (loaded kernel modules), pci.device and usb.device. The
+// expressions of the device features are matched against the attributes of
+// each device separately.
+type FeatureRule struct {
+	Feature          string             `json:"feature"`
+	MatchExpressions MatchExpressionSet `json:"matchExpressions"`
+}
+
+// FeatureRules implements Rule, matching if all of the rules match
+type FeatureRules []FeatureRule
+
+// Match the expressions against the attributes of the feature
+func (r *FeatureRule) Match() (bool, error) {
+	match, _, err := r.MatchInstances()
+	return match, err
+}
+
+// MatchInstances matches the expressions against the attributes of the
+// feature. For the device features all the matching devices are returned.
+func (r *FeatureRule) MatchInstances() (bool, []map[string]string, error) {
+	switch r.Feature {
+	case "cpu.cpuid":
+		values := make(map[string]string, len(cpuIdFlags))
+		for f := range cpuIdFlags {
+			values[f] = "true"
+		}
+		match, err := r.MatchExpressions.MatchValues(values)
+		return match, nil, err
+	case "kernel.config":
+		match, err := r.MatchExpressions.MatchValues(kconfigValues)
+		return match, nil, err
+	case "kernel.loadedmodule":
+		mods, err := (&LoadedKModRule{}).getLoadedModules()
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to get loaded kernel modules: %v", err)
+		}
+		values := make(map[string]string, len(mods))
+		for m := range mods {
+			values[m] = "true"
+		}
+		match, err := r.MatchExpressions.MatchValues(values)
+		return match, nil, err
+	case "pci.device":
+		devs, err := pciutils.DetectPci(map[string]bool{"class": true, "vendor": true, "device": true, "subsystem_vendor": true, "subsystem_device": true, "sriov_totalvfs": false, "sriov_numvfs": false})
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to detect PCI devices: %v", err)
+		}
+		instances := []map[string]string{}
+		for _, classDevs := range devs {
+			for _, d := range classDevs {
+				instances = append(instances, d)
+			}
+		}
+		matching, err := r.MatchExpressions.MatchingInstances(instances)
+		return len(matching) > 0, matching, err
+	case "usb.device":
+		devs, err := pciutils.DetectUsb(map[string]bool{"class": true, "vendor": true, "device": true})
+		if err != nil {
+			return false, nil, fmt.Errorf("failed to detect USB devices: %v", err)
+		}
+		instances := []map[string]string{}
+		for _, classDevs := range devs {
+			for _, d := range classDevs {
+				instances = append(instances, d)
+			}
+		}
+		matching, err := r.MatchExpressions.MatchingInstances(instances)
+		return len(matching) > 0, matching, err
+	}
+	return false, nil, fmt.Errorf("unknown feature %q", r.Feature)
+}
+
+// Match all the rules
+func (rules *FeatureRules) Match() (bool, error) {
+	match, _, err := rules.MatchInstances()
+	return match, err
+}
+
+// MatchInstances matches all the rules, returning the matching devices of
+// the device features
+func (rules *FeatureRules) MatchInstances() (bool, []map[string]string, error) {
+	instances := []map[string]string{}
+	for i := range *rules {
+		match, matching, err := (*rules)[i].MatchInstances()
+		if err != nil {
+			return false, nil, fmt.Errorf("%s: %v", (*rules)[i].Feature, err)
+		}
+		if !match {
+			return false, nil, nil
+		}
+		instances = append(instances, matching...)
+	}
+	return true, instances, nil
+}
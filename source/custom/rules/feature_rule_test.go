@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// writeFakePciDevice creates a PCI device with the given attributes in a fake
+// sysfs tree
+func writeFakePciDevice(sysfs, addr string, attrs map[string]string) error {
+	dir := filepath.Join(sysfs, "bus/pci/devices", addr)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, value := range attrs {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestFeatureRules(t *testing.T) {
+	Convey("When matching feature rules", t, func() {
+		sysfs, err := ioutil.TempDir("", "feature-rule-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(sysfs)
+
+		origSysfsDir, origCpuIdFlags, origKconfigValues := source.SysfsDir, cpuIdFlags, kconfigValues
+		defer func() { source.SysfsDir, cpuIdFlags, kconfigValues = origSysfsDir, origCpuIdFlags, origKconfigValues }()
+
+		source.SysfsDir = source.HostDir(sysfs)
+		cpuIdFlags = map[string]struct{}{"AVX": {}, "SSE4.2": {}}
+		kconfigValues = map[string]string{"NO_HZ": "true", "LOG_BUF_SHIFT": "17"}
+
+		gpu := map[string]string{"class": "0300", "vendor": "10de", "device": "1db4", "subsystem_vendor": "10de", "subsystem_device": "1212"}
+		nic := map[string]string{"class": "0200", "vendor": "8086", "device": "1572", "subsystem_vendor": "8086", "subsystem_device": "0001", "sriov_totalvfs": "64"}
+		So(writeFakePciDevice(sysfs, "0000:01:00.0", gpu), ShouldBeNil)
+		So(writeFakePciDevice(sysfs, "0000:02:00.0", nic), ShouldBeNil)
+
+		Convey("Non-device features should be matched against their values", func() {
+			rules := FeatureRules{
+				{Feature: "cpu.cpuid", MatchExpressions: MatchExpressionSet{"AVX": &MatchExpression{Op: MatchExists}}},
+				{Feature: "kernel.config", MatchExpressions: MatchExpressionSet{"LOG_BUF_SHIFT": &MatchExpression{Op: MatchGt, Value: []string{"16"}}}},
+			}
+			match, instances, err := rules.MatchInstances()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+			So(instances, ShouldBeEmpty)
+
+			rules[0].MatchExpressions = MatchExpressionSet{"AVX512F": &MatchExpression{Op: MatchExists}}
+			match, err = rules.Match()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeFalse)
+		})
+
+		Convey("Device features should return the matching devices", func() {
+			rule := FeatureRule{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"vendor": &MatchExpression{Op: MatchIn, Value: []string{"8086"}}}}
+			match, instances, err := rule.MatchInstances()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+			So(instances, ShouldResemble, []map[string]string{nic})
+		})
+
+		Convey("Optional device attributes should be missing if not present", func() {
+			rule := FeatureRule{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"sriov_totalvfs": &MatchExpression{Op: MatchDoesNotExist}}}
+			match, instances, err := rule.MatchInstances()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+			So(instances, ShouldResemble, []map[string]string{gpu})
+		})
+
+		Convey("Matching devices of all rules should be merged", func() {
+			rules := FeatureRules{
+				{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"class": &MatchExpression{Op: MatchIn, Value: []string{"0300"}}}},
+				{Feature: "cpu.cpuid", MatchExpressions: MatchExpressionSet{"SSE4.2": &MatchExpression{Op: MatchExists}}},
+				{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"sriov_totalvfs": &MatchExpression{Op: MatchGt, Value: []string{"0"}}}},
+			}
+			match, instances, err := rules.MatchInstances()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+			So(instances, ShouldResemble, []map[string]string{gpu, nic})
+		})
+
+		Convey("No devices should be returned if one of the rules does not match", func() {
+			rules := FeatureRules{
+				{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"class": &MatchExpression{Op: MatchIn, Value: []string{"0300"}}}},
+				{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"vendor": &MatchExpression{Op: MatchIn, Value: []string{"1002"}}}},
+			}
+			match, instances, err := rules.MatchInstances()
+			So(err, ShouldBeNil)
+			So(match, ShouldBeFalse)
+			So(instances, ShouldBeEmpty)
+		})
+
+		Convey("Errors should name the failing feature", func() {
+			rules := FeatureRules{
+				{Feature: "pci.device", MatchExpressions: MatchExpressionSet{"class": &MatchExpression{Op: MatchIn}}},
+			}
+			_, _, err := rules.MatchInstances()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, "pci.device:")
+
+			rules = FeatureRules{{Feature: "foo.bar"}}
+			_, err = rules.Match()
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -26,6 +26,9 @@ type KconfigRule []string
 
 var kConfigs map[string]struct{}
 
+// kconfigValues contains the kernel config options and their values
+var kconfigValues map[string]string
+
 func (kconfigs *KconfigRule) Match() (bool, error) {
 	for _, f := range *kconfigs {
 		if _, ok := kConfigs[f]; !ok {
@@ -40,6 +43,7 @@ func init() {
 
 	kconfig, err := kernelutils.ParseKconfig("")
 	if err == nil {
+		kconfigValues = kconfig
 		for k, v := range kconfig {
 			if v != "true" {
 				kConfigs[fmt.Sprintf("%s=%s", k, v)] = struct{}{}
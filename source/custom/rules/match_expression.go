@@ -0,0 +1,128 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MatchOp is the operator of a match expression
+type MatchOp string
+
+const (
+	MatchIn           MatchOp = "In"
+	MatchNotIn        MatchOp = "NotIn"
+	MatchExists       MatchOp = "Exists"
+	MatchDoesNotExist MatchOp = "DoesNotExist"
+	MatchGt           MatchOp = "Gt"
+	MatchLt           MatchOp = "Lt"
+)
+
+// MatchExpression is a condition on the value of one feature attribute
+type MatchExpression struct {
+	Op    MatchOp  `json:"op"`
+	Value []string `json:"value,omitempty"`
+}
+
+// MatchExpressionSet is a set of expressions, keyed by the name of the
+// attribute they apply to. All expressions must match.
+type MatchExpressionSet map[string]*MatchExpression
+
+// Match evaluates the expression against the value of an attribute. The
+// exists argument tells whether the attribute is present at all.
+func (e *MatchExpression) Match(exists bool, value string) (bool, error) {
+	switch e.Op {
+	case MatchExists, MatchDoesNotExist:
+		if len(e.Value) != 0 {
+			return false, fmt.Errorf("value must be empty for op %q", e.Op)
+		}
+		return exists == (e.Op == MatchExists), nil
+	case MatchIn, MatchNotIn:
+		if len(e.Value) == 0 {
+			return false, fmt.Errorf("value must be non-empty for op %q", e.Op)
+		}
+		return (exists && in(value, e.Value)) == (e.Op == MatchIn), nil
+	case MatchGt, MatchLt:
+		if len(e.Value) != 1 {
+			return false, fmt.Errorf("value must contain exactly one element for op %q", e.Op)
+		}
+		limit, err := strconv.Atoi(e.Value[0])
+		if err != nil {
+			return false, fmt.Errorf("value must be an integer for op %q: %v", e.Op, err)
+		}
+		if !exists {
+			return false, nil
+		}
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			// Non-integer values never match
+			return false, nil
+		}
+		if e.Op == MatchGt {
+			return i > limit, nil
+		}
+		return i < limit, nil
+	}
+	return false, fmt.Errorf("unsupported op %q", e.Op)
+}
+
+// MatchValues evaluates the expressions against a set of attribute values
+func (s MatchExpressionSet) MatchValues(values map[string]string) (bool, error) {
+	for name, e := range s {
+		if e == nil {
+			return false, fmt.Errorf("missing expression for %q", name)
+		}
+		v, ok := values[name]
+		match, err := e.Match(ok, v)
+		if err != nil {
+			return false, fmt.Errorf("invalid expression for %q: %v", name, err)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MatchInstances evaluates the expressions against a list of instances,
+// e.g. PCI devices. The set matches if one of the instances matches all the
+// expressions.
+func (s MatchExpressionSet) MatchInstances(instances []map[string]string) (bool, error) {
+	for _, i := range instances {
+		match, err := s.MatchValues(i)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+// MatchingInstances returns all the instances that match all the expressions
+func (s MatchExpressionSet) MatchingInstances(instances []map[string]string) ([]map[string]string, error) {
+	matching := []map[string]string{}
+	for _, i := range instances {
+		match, err := s.MatchValues(i)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			matching = append(matching, i)
+		}
+	}
+	return matching, nil
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchExpression(t *testing.T) {
+	Convey("When evaluating match expressions", t, func() {
+		tcs := []struct {
+			name   string
+			expr   MatchExpression
+			exists bool
+			value  string
+			match  bool
+		}{
+			{name: "In, value in list", expr: MatchExpression{Op: MatchIn, Value: []string{"a", "b"}}, exists: true, value: "b", match: true},
+			{name: "In, value not in list", expr: MatchExpression{Op: MatchIn, Value: []string{"a", "b"}}, exists: true, value: "c", match: false},
+			{name: "In, missing attribute", expr: MatchExpression{Op: MatchIn, Value: []string{"a"}}, exists: false, match: false},
+			{name: "In, missing attribute with empty value in list", expr: MatchExpression{Op: MatchIn, Value: []string{""}}, exists: false, match: false},
+			{name: "NotIn, value in list", expr: MatchExpression{Op: MatchNotIn, Value: []string{"a", "b"}}, exists: true, value: "a", match: false},
+			{name: "NotIn, value not in list", expr: MatchExpression{Op: MatchNotIn, Value: []string{"a", "b"}}, exists: true, value: "c", match: true},
+			{name: "NotIn, missing attribute", expr: MatchExpression{Op: MatchNotIn, Value: []string{"a"}}, exists: false, match: true},
+			{name: "Exists, present", expr: MatchExpression{Op: MatchExists}, exists: true, value: "", match: true},
+			{name: "Exists, missing", expr: MatchExpression{Op: MatchExists}, exists: false, match: false},
+			{name: "DoesNotExist, present", expr: MatchExpression{Op: MatchDoesNotExist}, exists: true, value: "a", match: false},
+			{name: "DoesNotExist, missing", expr: MatchExpression{Op: MatchDoesNotExist}, exists: false, match: true},
+			{name: "Gt, greater", expr: MatchExpression{Op: MatchGt, Value: []string{"10"}}, exists: true, value: "11", match: true},
+			{name: "Gt, equal", expr: MatchExpression{Op: MatchGt, Value: []string{"10"}}, exists: true, value: "10", match: false},
+			{name: "Gt, non-integer value", expr: MatchExpression{Op: MatchGt, Value: []string{"10"}}, exists: true, value: "eleven", match: false},
+			{name: "Gt, missing attribute", expr: MatchExpression{Op: MatchGt, Value: []string{"-1"}}, exists: false, match: false},
+			{name: "Lt, less", expr: MatchExpression{Op: MatchLt, Value: []string{"10"}}, exists: true, value: "-5", match: true},
+			{name: "Lt, equal", expr: MatchExpression{Op: MatchLt, Value: []string{"10"}}, exists: true, value: "10", match: false},
+			{name: "Lt, missing attribute", expr: MatchExpression{Op: MatchLt, Value: []string{"10"}}, exists: false, match: false},
+		}
+
+		for _, tc := range tcs {
+			Convey(tc.name, func() {
+				match, err := tc.expr.Match(tc.exists, tc.value)
+				So(err, ShouldBeNil)
+				So(match, ShouldEqual, tc.match)
+			})
+		}
+	})
+
+	Convey("When evaluating malformed match expressions", t, func() {
+		tcs := []struct {
+			name string
+			expr MatchExpression
+		}{
+			{name: "In without values", expr: MatchExpression{Op: MatchIn}},
+			{name: "NotIn without values", expr: MatchExpression{Op: MatchNotIn}},
+			{name: "Exists with values", expr: MatchExpression{Op: MatchExists, Value: []string{"a"}}},
+			{name: "DoesNotExist with values", expr: MatchExpression{Op: MatchDoesNotExist, Value: []string{"a"}}},
+			{name: "Gt without values", expr: MatchExpression{Op: MatchGt}},
+			{name: "Gt with multiple values", expr: MatchExpression{Op: MatchGt, Value: []string{"1", "2"}}},
+			{name: "Lt with a non-integer value", expr: MatchExpression{Op: MatchLt, Value: []string{"ten"}}},
+			{name: "Unknown op", expr: MatchExpression{Op: "Equals", Value: []string{"a"}}},
+		}
+
+		for _, tc := range tcs {
+			Convey(tc.name+" should produce an error", func() {
+				// Malformed expressions are rejected regardless of the value
+				_, err := tc.expr.Match(true, "1")
+				So(err, ShouldNotBeNil)
+				_, err = tc.expr.Match(false, "")
+				So(err, ShouldNotBeNil)
+			})
+		}
+	})
+}
+
+func TestMatchExpressionSet(t *testing.T) {
+	Convey("When evaluating a set of match expressions", t, func() {
+		s := MatchExpressionSet{
+			"vendor": &MatchExpression{Op: MatchIn, Value: []string{"8086"}},
+			"sriov":  &MatchExpression{Op: MatchExists},
+		}
+
+		Convey("All expressions should match", func() {
+			match, err := s.MatchValues(map[string]string{"vendor": "8086", "sriov": "8"})
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+
+			match, err = s.MatchValues(map[string]string{"vendor": "10de", "sriov": "8"})
+			So(err, ShouldBeNil)
+			So(match, ShouldBeFalse)
+		})
+
+		Convey("Missing attributes should not match", func() {
+			match, err := s.MatchValues(map[string]string{"vendor": "8086"})
+			So(err, ShouldBeNil)
+			So(match, ShouldBeFalse)
+		})
+
+		Convey("An empty set should match anything", func() {
+			match, err := MatchExpressionSet{}.MatchValues(map[string]string{})
+			So(err, ShouldBeNil)
+			So(match, ShouldBeTrue)
+		})
+
+		Convey("Missing or malformed expressions should produce an error", func() {
+			_, err := MatchExpressionSet{"vendor": nil}.MatchValues(map[string]string{"vendor": "8086"})
+			So(err, ShouldNotBeNil)
+			_, err = MatchExpressionSet{"vendor": &MatchExpression{Op: MatchIn}}.MatchValues(map[string]string{"vendor": "8086"})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When matching against instances", func() {
+			instances := []map[string]string{
+				{"vendor": "10de", "sriov": "8"},
+				{"vendor": "8086"},
+				{"vendor": "8086", "sriov": "4"},
+				{"vendor": "8086", "sriov": "16"},
+			}
+
+			Convey("The set should match if one of the instances matches", func() {
+				match, err := s.MatchInstances(instances)
+				So(err, ShouldBeNil)
+				So(match, ShouldBeTrue)
+
+				match, err = s.MatchInstances(instances[:2])
+				So(err, ShouldBeNil)
+				So(match, ShouldBeFalse)
+
+				match, err = s.MatchInstances(nil)
+				So(err, ShouldBeNil)
+				So(match, ShouldBeFalse)
+			})
+
+			Convey("All matching instances should be returned", func() {
+				matching, err := s.MatchingInstances(instances)
+				So(err, ShouldBeNil)
+				So(matching, ShouldResemble, instances[2:])
+
+				matching, err = s.MatchingInstances(instances[:2])
+				So(err, ShouldBeNil)
+				So(matching, ShouldBeEmpty)
+			})
+
+			Convey("Malformed expressions should produce an error", func() {
+				s := MatchExpressionSet{"vendor": &MatchExpression{Op: MatchGt}}
+				_, err := s.MatchInstances(instances)
+				So(err, ShouldNotBeNil)
+				_, err = s.MatchingInstances(instances)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
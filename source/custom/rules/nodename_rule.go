@@ -0,0 +1,43 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// NodenameRule implements Rule, matching the name of the node against a list
+// of regular expressions
+type NodenameRule []string
+
+var nodeName = os.Getenv("NODE_NAME")
+
+// Match the node name against the regexps, matching if any of them matches
+func (n *NodenameRule) Match() (bool, error) {
+	for _, r := range *n {
+		re, err := regexp.Compile(r)
+		if err != nil {
+			return false, fmt.Errorf("invalid nodename regexp %q: %v", r, err)
+		}
+		if re.MatchString(nodeName) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deviceplugin
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	registerapi "k8s.io/kubelet/pkg/apis/pluginregistration/v1"
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// Timeout for querying the info of one plugin
+const pluginInfoTimeout = time.Second
+
+// Configuration file options
+type Config struct {
+	// RegistrationDir is the plugin registration directory of kubelet
+	RegistrationDir string `json:"registrationDir,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		RegistrationDir: source.VarDir.Path("lib/kubelet/plugins_registry"),
+	}
+}
+
+// Implement FeatureSource interface
+type Source struct {
+	config *Config
+}
+
+func (s Source) Name() string { return "deviceplugin" }
+
+// NewConfig method of the FeatureSource interface
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
+
+// GetConfig method of the FeatureSource interface
+func (s *Source) GetConfig() source.Config { return s.config }
+
+// SetConfig method of the FeatureSource interface
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
+
+func (s Source) Discover() (source.Features, error) {
+	features := source.Features{}
+
+	sockets, err := pluginSockets(s.config.RegistrationDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list plugin sockets: %v", err)
+	}
+
+	// Only plugins that are running answer, stale sockets are skipped
+	for _, socket := range sockets {
+		info, err := getPluginInfo(socket)
+		if err != nil {
+			log.Printf("WARNING: failed to get plugin info from %s: %v", socket, err)
+			continue
+		}
+		if info.Type == registerapi.DevicePlugin {
+			// E.g. vendor.com/device -> vendor.com_device
+			features[strings.Replace(info.Name, "/", "_", -1)+".present"] = true
+		}
+	}
+
+	return features, nil
+}
+
+// pluginSockets returns the sockets found in the plugin registration
+// directory, in the same way as kubelet, i.e. walking the directory tree and
+// skipping files and directories whose name starts with a dot
+func pluginSockets(dir string) ([]string, error) {
+	sockets := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				// No plugins have ever been registered on the node
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if path != dir && strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSocket != 0 {
+			sockets = append(sockets, path)
+		}
+		return nil
+	})
+	return sockets, err
+}
+
+// getPluginInfo queries the info of a plugin over its registration socket
+func getPluginInfo(socket string) (*registerapi.PluginInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginInfoTimeout)
+	defer cancel()
+
+	// Sockets of stopped plugins refuse the connection, fail fast on them
+	conn, err := grpc.DialContext(ctx, socket, grpc.WithInsecure(), grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return registerapi.NewRegistrationClient(conn).GetInfo(ctx, &registerapi.InfoRequest{})
+}
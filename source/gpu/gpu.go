@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gpu
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+	pciutils "sigs.k8s.io/node-feature-discovery/source/internal"
+)
+
+// PCI vendor IDs of the supported GPU vendors, in the order of preference
+// used when GPUs of several vendors are present. Discrete GPUs are preferred
+// over integrated ones.
+var gpuVendors = []struct {
+	id   string
+	name string
+}{
+	{"10de", "nvidia"},
+	{"1002", "amd"},
+	{"8086", "intel"},
+}
+
+// PCI class code prefix of display controllers
+const displayControllerClass = "03"
+
+// Implement FeatureSource interface
+type Source struct{}
+
+// Return name of the feature source
+func (s Source) Name() string { return "gpu" }
+
+// NewConfig method of the FeatureSource interface
+func (s *Source) NewConfig() source.Config { return nil }
+
+// GetConfig method of the FeatureSource interface
+func (s *Source) GetConfig() source.Config { return nil }
+
+// SetConfig method of the FeatureSource interface
+func (s *Source) SetConfig(source.Config) {}
+
+// Discover features
+func (s Source) Discover() (source.Features, error) {
+	features := source.Features{}
+
+	devs, err := pciutils.DetectPci(map[string]bool{"vendor": true, "driver": false})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to detect PCI devices: %s", err.Error())
+	}
+
+	// GPUs of each supported vendor
+	gpus := map[string][]pciutils.PciDeviceInfo{}
+	for class, classDevs := range devs {
+		if !strings.HasPrefix(class, displayControllerClass) {
+			continue
+		}
+		for _, dev := range classDevs {
+			gpus[dev["vendor"]] = append(gpus[dev["vendor"]], dev)
+		}
+	}
+
+	for _, v := range gpuVendors {
+		if len(gpus[v.id]) == 0 {
+			continue
+		}
+		features["present"] = true
+		features["vendor"] = v.name
+		for _, dev := range gpus[v.id] {
+			if driver, ok := dev["driver"]; ok {
+				features["driver"] = driver
+				break
+			}
+		}
+		break
+	}
+
+	return features, nil
+}
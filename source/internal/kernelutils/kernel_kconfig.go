@@ -17,10 +17,10 @@ limitations under the License.
 package kernelutils
 
 import (
-	"bytes"
+	"bufio"
 	"compress/gzip"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -31,29 +31,42 @@ import (
 	"sigs.k8s.io/node-feature-discovery/source"
 )
 
-// Read gzipped kernel config
-func readKconfigGzip(filename string) ([]byte, error) {
-	// Open file for reading
+// Maximum length of a line in the kernel config. Longer lines make the
+// parsing fail instead of growing the read buffer without bounds.
+const kconfigMaxLineLength = 16 * 1024
+
+// gzipReadCloser closes both the gzip stream and the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// Open kernel config for reading, gzipped files are uncompressed on the fly
+func openKconfig(filename string) (io.ReadCloser, error) {
 	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	if filepath.Ext(filename) != ".gz" {
+		return f, nil
+	}
 
-	// Uncompress data
 	r, err := gzip.NewReader(f)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
-	defer r.Close()
-
-	return ioutil.ReadAll(r)
+	return &gzipReadCloser{Reader: r, file: f}, nil
 }
 
 // Read kconfig into a map
 func ParseKconfig(configPath string) (map[string]string, error) {
 	kconfig := map[string]string{}
-	raw := []byte(nil)
 	var err error
 	var searchPaths []string
 
@@ -79,31 +92,28 @@ func ParseKconfig(configPath string) (map[string]string, error) {
 		}
 	}
 
+	var r io.ReadCloser
 	for _, path := range append([]string{configPath}, searchPaths...) {
 		if len(path) > 0 {
-			if ".gz" == filepath.Ext(path) {
-				if raw, err = readKconfigGzip(path); err == nil {
-					break
-				}
-			} else {
-				if raw, err = ioutil.ReadFile(path); err == nil {
-					break
-				}
+			if r, err = openKconfig(path); err == nil {
+				break
 			}
 		}
 	}
 
-	if raw == nil {
+	if r == nil {
 		return nil, fmt.Errorf("Failed to read kernel config from %+v:", append([]string{configPath}, searchPaths...))
 	}
+	defer r.Close()
 
 	// Regexp for matching kconfig flags
 	re := regexp.MustCompile(`^CONFIG_(?P<flag>\w+)=(?P<value>.+)`)
 
-	// Process data, line-by-line
-	lines := bytes.Split(raw, []byte("\n"))
-	for _, line := range lines {
-		if m := re.FindStringSubmatch(string(line)); m != nil {
+	// Process data, line-by-line, without reading the whole file in memory
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), kconfigMaxLineLength)
+	for scanner.Scan() {
+		if m := re.FindStringSubmatch(scanner.Text()); m != nil {
 			if m[2] == "y" || m[2] == "m" {
 				kconfig[m[1]] = "true"
 			} else {
@@ -116,6 +126,9 @@ func ParseKconfig(configPath string) (map[string]string, error) {
 			}
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read kernel config: %v", err)
+	}
 
 	return kconfig, nil
 }
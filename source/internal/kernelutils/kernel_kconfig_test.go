@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernelutils
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testKconfig = `#
+# Automatically generated file; DO NOT EDIT.
+#
+CONFIG_FOO=y
+CONFIG_BAR=m
+# CONFIG_BAZ is not set
+CONFIG_NAME="my kernel"
+CONFIG_NUM=42
+`
+
+func TestParseKconfig(t *testing.T) {
+	Convey("When parsing a kernel config", t, func() {
+		tmpDir, err := ioutil.TempDir("", "kconfig-test-")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(tmpDir)
+
+		expected := map[string]string{
+			"FOO":  "true",
+			"BAR":  "true",
+			"NAME": "my kernel",
+			"NUM":  "42",
+		}
+
+		Convey("A plain text file should be parsed", func() {
+			path := filepath.Join(tmpDir, "config")
+			So(ioutil.WriteFile(path, []byte(testKconfig), 0644), ShouldBeNil)
+
+			kconfig, err := ParseKconfig(path)
+			So(err, ShouldBeNil)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("A gzipped file should be parsed", func() {
+			path := filepath.Join(tmpDir, "config.gz")
+			f, err := os.Create(path)
+			So(err, ShouldBeNil)
+			w := gzip.NewWriter(f)
+			_, err = w.Write([]byte(testKconfig))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			So(f.Close(), ShouldBeNil)
+
+			kconfig, err := ParseKconfig(path)
+			So(err, ShouldBeNil)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("Values exceeding the max label value length should be ignored", func() {
+			path := filepath.Join(tmpDir, "config")
+			data := testKconfig + "CONFIG_LONG=\"" + strings.Repeat("a", 64) + "\"\n"
+			So(ioutil.WriteFile(path, []byte(data), 0644), ShouldBeNil)
+
+			kconfig, err := ParseKconfig(path)
+			So(err, ShouldBeNil)
+			So(kconfig, ShouldResemble, expected)
+		})
+
+		Convey("Lines exceeding the max line length should make the parsing fail", func() {
+			path := filepath.Join(tmpDir, "config")
+			data := testKconfig + "CONFIG_HUGE=\"" + strings.Repeat("a", kconfigMaxLineLength) + "\"\n"
+			So(ioutil.WriteFile(path, []byte(data), 0644), ShouldBeNil)
+
+			_, err := ParseKconfig(path)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path"
 	"strings"
 
@@ -29,11 +30,20 @@ import (
 type PciDeviceInfo map[string]string
 
 var DefaultPciDevAttrs = []string{"class", "vendor", "device", "subsystem_vendor", "subsystem_device"}
-var ExtraPciDevAttrs = []string{"sriov_totalvfs"}
+var ExtraPciDevAttrs = []string{"sriov_totalvfs", "sriov_numvfs"}
 
 // Read a single PCI device attribute
-// A PCI attribute in this context, maps to the corresponding sysfs file
+// A PCI attribute in this context, maps to the corresponding sysfs file. The
+// "driver" attribute is the name of the driver bound to the device.
 func readSinglePciAttribute(devPath string, attrName string) (string, error) {
+	if attrName == "driver" {
+		link, err := os.Readlink(path.Join(devPath, attrName))
+		if err != nil {
+			return "", fmt.Errorf("failed to read device driver: %v", err)
+		}
+		return path.Base(link), nil
+	}
+
 	data, err := ioutil.ReadFile(path.Join(devPath, attrName))
 	if err != nil {
 		return "", fmt.Errorf("failed to read device attribute %s: %v", attrName, err)
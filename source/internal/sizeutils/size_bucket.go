@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sizeutils
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// SizeBucket returns the largest one of the given boundaries (Kubernetes
+// quantities, e.g. "64Gi") not exceeding the size, or "0" if the size is
+// smaller than all boundaries
+func SizeBucket(size int64, boundaries []string) (string, error) {
+	bucket := "0"
+	var bucketSize int64
+	for _, b := range boundaries {
+		q, err := resource.ParseQuantity(b)
+		if err != nil {
+			return "", fmt.Errorf("invalid size bucket %q: %v", b, err)
+		}
+		if v := q.Value(); v <= size && v > bucketSize {
+			bucket = b
+			bucketSize = v
+		}
+	}
+	return bucket, nil
+}
@@ -48,6 +48,7 @@ func (s Source) Discover() (source.Features, error) {
 
 	if len(devices) > 0 {
 		features["enabled"] = true
+		detectPassthrough(features)
 	}
 
 	return features, nil
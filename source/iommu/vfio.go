@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package iommu
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// detectPassthrough detects the readiness of the node for device passthrough
+// with VFIO
+func detectPassthrough(features source.Features) {
+	if groupsValid() {
+		features["groups.valid"] = true
+	}
+
+	// The vfio-pci driver is registered if the module is loaded or built in
+	if _, err := os.Stat(source.SysfsDir.Path("bus/pci/drivers/vfio-pci")); err == nil {
+		features["vfio.capable"] = true
+	}
+
+	data, err := ioutil.ReadFile(source.SysfsDir.Path("module/vfio_iommu_type1/parameters/allow_unsafe_interrupts"))
+	if err == nil && strings.TrimSpace(string(data)) == "Y" {
+		features["vfio.unsafe-interrupts"] = true
+	}
+}
+
+// groupsValid returns true if IOMMU groups have been set up and every PCI
+// device belongs to one of them
+func groupsValid() bool {
+	groups, err := ioutil.ReadDir(source.SysfsDir.Path("kernel/iommu_groups"))
+	if err != nil || len(groups) == 0 {
+		return false
+	}
+
+	devices, err := ioutil.ReadDir(source.SysfsDir.Path("bus/pci/devices"))
+	if err != nil {
+		log.Printf("ERROR: failed to list PCI devices: %v", err)
+		return false
+	}
+	for _, d := range devices {
+		if _, err := os.Stat(filepath.Join(source.SysfsDir.Path("bus/pci/devices"), d.Name(), "iommu_group")); err != nil {
+			log.Printf("INFO: PCI device %s does not belong to an IOMMU group", d.Name())
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"os"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// cgroupV2 returns true if the cgroup v2 unified hierarchy is mounted as the
+// only cgroup hierarchy. The root of the hierarchy contains the
+// cgroup.controllers file only with cgroup v2. Hybrid setups, with the
+// controllers still in cgroup v1 hierarchies, are not considered cgroup v2.
+func cgroupV2() bool {
+	_, err := os.Stat(source.SysfsDir.Path("fs/cgroup/cgroup.controllers"))
+	return err == nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const cmdlineProcfsPath = "/proc/cmdline"
+
+// detectCmdline returns the values of the given kernel command line
+// parameters. Parameters without a value get the value "true". Characters not
+// allowed in label values are replaced with underscores.
+func detectCmdline(params []string) (map[string]string, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	out, err := ioutil.ReadFile(cmdlineProcfsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", cmdlineProcfsPath, err)
+	}
+
+	wanted := map[string]struct{}{}
+	for _, p := range params {
+		wanted[p] = struct{}{}
+	}
+
+	invalid := regexp.MustCompile("[^-A-Za-z0-9_.]")
+	values := map[string]string{}
+	for _, field := range strings.Fields(string(out)) {
+		split := strings.SplitN(field, "=", 2)
+		if _, ok := wanted[split[0]]; !ok {
+			continue
+		}
+		// The last occurrence of a parameter takes precedence
+		value := "true"
+		if len(split) == 2 {
+			value = invalid.ReplaceAllString(split[1], "_")
+		}
+		values[split[0]] = value
+	}
+	return values, nil
+}
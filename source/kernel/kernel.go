@@ -26,8 +26,10 @@ import (
 
 // Configuration file options
 type Config struct {
-	KconfigFile string
-	ConfigOpts  []string `json:"configOpts,omitempty"`
+	KconfigFile   string
+	ConfigOpts    []string `json:"configOpts,omitempty"`
+	LoadedModules []string `json:"loadedModules,omitempty"`
+	CmdlineOpts   []string `json:"cmdlineOpts,omitempty"`
 }
 
 // newDefaultConfig returns a new config with pre-populated defaults
@@ -40,6 +42,8 @@ func newDefaultConfig() *Config {
 			"NO_HZ_FULL",
 			"PREEMPT",
 		},
+		LoadedModules: []string{},
+		CmdlineOpts:   []string{},
 	}
 }
 
@@ -92,11 +96,42 @@ func (s *Source) Discover() (source.Features, error) {
 		}
 	}
 
-	selinux, err := SelinuxEnabled()
+	// Check kernel modules
+	modules, err := detectLoadedModules(s.config.LoadedModules)
+	if err != nil {
+		log.Printf("ERROR: Failed to detect loaded kernel modules: %s", err)
+	} else {
+		for _, m := range modules {
+			features["loadedmodule."+m] = true
+		}
+	}
+
+	// Check kernel command line
+	cmdline, err := detectCmdline(s.config.CmdlineOpts)
+	if err != nil {
+		log.Printf("ERROR: Failed to read kernel command line: %s", err)
+	} else {
+		for k, v := range cmdline {
+			features["cmdline."+k] = v
+		}
+	}
+
+	if sctpEnabled(kconfig) {
+		features["sctp.enabled"] = true
+	}
+
+	if cgroupV2() {
+		features["cgroup.v2"] = true
+	}
+
+	selinux, err := SelinuxMode()
 	if err != nil {
 		log.Print(err)
-	} else if selinux {
-		features["selinux.enabled"] = true
+	} else if selinux != "" {
+		if selinux == "enforcing" {
+			features["selinux.enabled"] = true
+		}
+		features["selinux.mode"] = selinux
 	}
 
 	return features, nil
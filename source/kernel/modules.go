@@ -0,0 +1,78 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kernel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+const kmodProcfsPath = "/proc/modules"
+
+// detectLoadedModules returns the subset of the given kernel modules that are
+// loaded or built into the kernel
+func detectLoadedModules(names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	out, err := ioutil.ReadFile(kmodProcfsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", kmodProcfsPath, err)
+	}
+	loaded := map[string]struct{}{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			loaded[fields[0]] = struct{}{}
+		}
+	}
+
+	found := []string{}
+	for _, name := range names {
+		// The kernel uses underscores in module names
+		kmod := strings.Replace(name, "-", "_", -1)
+		if _, ok := loaded[kmod]; ok {
+			found = append(found, name)
+			continue
+		}
+		// Built-in modules (with parameters) are visible in sysfs
+		if _, err := os.Stat(source.SysfsDir.Path("module", kmod)); err == nil {
+			found = append(found, name)
+		}
+	}
+	return found, nil
+}
+
+// sctpEnabled returns true if SCTP is supported, i.e. the sctp module is
+// loaded or built in, or it is enabled in the kernel config and thus can be
+// loaded on demand
+func sctpEnabled(kconfig map[string]string) bool {
+	if kconfig["IP_SCTP"] == "true" {
+		return true
+	}
+	loaded, err := detectLoadedModules([]string{"sctp"})
+	if err != nil {
+		log.Printf("ERROR: Failed to detect loaded kernel modules: %s", err)
+		return false
+	}
+	return len(loaded) > 0
+}
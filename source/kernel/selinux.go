@@ -19,19 +19,33 @@ package kernel
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 
 	"sigs.k8s.io/node-feature-discovery/source"
 )
 
 // Detect if selinux has been enabled in the kernel
 func SelinuxEnabled() (bool, error) {
+	mode, err := SelinuxMode()
+	if err != nil {
+		return false, err
+	}
+	return mode == "enforcing", nil
+}
+
+// SelinuxMode returns the mode of selinux, i.e. "enforcing" or "permissive".
+// An empty string is returned if selinux is not supported by the kernel.
+func SelinuxMode() (string, error) {
+	if _, err := os.Stat(source.SysfsDir.Path("fs/selinux")); os.IsNotExist(err) {
+		return "", nil
+	}
+
 	status, err := ioutil.ReadFile(source.SysfsDir.Path("fs/selinux/enforce"))
 	if err != nil {
-		return false, fmt.Errorf("Failed to detect the status of selinux, please check if the system supports selinux and make sure /sys on the host is mounted into the container: %s", err.Error())
+		return "", fmt.Errorf("Failed to detect the status of selinux, please check if the system supports selinux and make sure /sys on the host is mounted into the container: %s", err.Error())
 	}
-	if status[0] == byte('1') {
-		// selinux is enabled.
-		return true, nil
+	if len(status) > 0 && status[0] == byte('1') {
+		return "enforcing", nil
 	}
-	return false, nil
+	return "permissive", nil
 }
@@ -25,7 +25,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/node-feature-discovery/source"
 )
 
@@ -35,24 +38,51 @@ var (
 	hookDir         = "/etc/kubernetes/node-feature-discovery/source.d/"
 )
 
+// Configuration file options
+type Config struct {
+	HooksEnabled bool             `json:"hooksEnabled"`
+	HooksTimeout meta_v1.Duration `json:"hooksTimeout,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		HooksEnabled: true,
+		HooksTimeout: meta_v1.Duration{Duration: 10 * time.Second},
+	}
+}
+
 // Implement FeatureSource interface
-type Source struct{}
+type Source struct {
+	config *Config
+}
 
 func (s Source) Name() string { return "local" }
 
 // NewConfig method of the FeatureSource interface
-func (s *Source) NewConfig() source.Config { return nil }
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
 // GetConfig method of the FeatureSource interface
-func (s *Source) GetConfig() source.Config { return nil }
+func (s *Source) GetConfig() source.Config { return s.config }
 
 // SetConfig method of the FeatureSource interface
-func (s *Source) SetConfig(source.Config) {}
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
 
-func (s Source) Discover() (source.Features, error) {
-	featuresFromHooks, err := getFeaturesFromHooks()
-	if err != nil {
-		log.Printf("%v", err)
+func (s *Source) Discover() (source.Features, error) {
+	featuresFromHooks := source.Features{}
+	if s.config.HooksEnabled {
+		var err error
+		featuresFromHooks, err = getFeaturesFromHooks(s.config.HooksTimeout.Duration)
+		if err != nil {
+			log.Printf("%v", err)
+		}
 	}
 
 	featuresFromFiles, err := getFeaturesFromFiles()
@@ -77,25 +107,28 @@ func parseFeatures(lines [][]byte, prefix string) source.Features {
 
 	for _, line := range lines {
 		if len(line) > 0 {
-			lineSplit := strings.SplitN(string(line), "=", 2)
-
-			// Check if we need to add prefix
-			var key string
-			if strings.Contains(lineSplit[0], "/") {
-				if lineSplit[0][0] == '/' {
-					key = lineSplit[0][1:]
-				} else {
-					key = lineSplit[0]
+			// Features prefixed with '@' are published as annotations
+			annotation := false
+			if line[0] == '@' {
+				annotation = true
+				line = line[1:]
+				if len(line) == 0 {
+					continue
 				}
-			} else {
-				key = prefix + "-" + lineSplit[0]
 			}
 
+			lineSplit := strings.SplitN(string(line), "=", 2)
+			key := featureKey(lineSplit[0], prefix)
+
 			// Check if it's a boolean value
-			if len(lineSplit) == 1 {
-				features[key] = "true"
+			value := "true"
+			if len(lineSplit) == 2 {
+				value = lineSplit[1]
+			}
+			if annotation {
+				features[key] = source.AnnotationFeatureValue(value)
 			} else {
-				features[key] = lineSplit[1]
+				features[key] = value
 			}
 		}
 	}
@@ -103,8 +136,20 @@ func parseFeatures(lines [][]byte, prefix string) source.Features {
 	return features
 }
 
-// Run all hooks and get features
-func getFeaturesFromHooks() (source.Features, error) {
+// featureKey returns the full name of a feature, adding the prefix if needed
+func featureKey(name, prefix string) string {
+	if strings.Contains(name, "/") {
+		if name[0] == '/' {
+			return name[1:]
+		}
+		return name
+	}
+	return prefix + "-" + name
+}
+
+// Run all hooks and get features. Each hook is killed if it does not finish
+// within the given timeout, a non-positive timeout disables the limit.
+func getFeaturesFromHooks(timeout time.Duration) (source.Features, error) {
 	features := source.Features{}
 
 	files, err := ioutil.ReadDir(hookDir)
@@ -118,7 +163,7 @@ func getFeaturesFromHooks() (source.Features, error) {
 
 	for _, file := range files {
 		fileName := file.Name()
-		lines, err := runHook(fileName)
+		lines, err := runHook(fileName, timeout)
 		if err != nil {
 			log.Printf("ERROR: source local failed running hook '%v': %v", fileName, err)
 			continue
@@ -138,7 +183,7 @@ func getFeaturesFromHooks() (source.Features, error) {
 }
 
 // Run one hook
-func runHook(file string) ([][]byte, error) {
+func runHook(file string, timeout time.Duration) ([][]byte, error) {
 	var lines [][]byte
 
 	path := filepath.Join(hookDir, file)
@@ -154,9 +199,34 @@ func runHook(file string) ([][]byte, error) {
 		var stderr bytes.Buffer
 		cmd.Stdout = &stdout
 		cmd.Stderr = &stderr
+		// Run the hook in a process group of its own so that processes
+		// spawned by it can be killed, too. Those would otherwise keep the
+		// output pipes open and block waiting for the hook to finish.
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 		// Run hook
-		err = cmd.Run()
+		if err := cmd.Start(); err != nil {
+			return lines, err
+		}
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+
+		var deadline <-chan time.Time
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		timedOut := false
+		select {
+		case err = <-done:
+		case <-deadline:
+			timedOut = true
+			if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+				log.Printf("ERROR: failed to kill process group of %v: %v", path, err)
+			}
+			<-done
+		}
 
 		// Forward stderr to our logger
 		errLines := bytes.Split(stderr.Bytes(), []byte("\n"))
@@ -169,6 +239,9 @@ func runHook(file string) ([][]byte, error) {
 		}
 
 		// Do not return any lines if an error occurred
+		if timedOut {
+			return lines, fmt.Errorf("timed out after %s", timeout)
+		}
 		if err != nil {
 			return lines, err
 		}
@@ -193,14 +266,29 @@ func getFeaturesFromFiles() (source.Features, error) {
 
 	for _, file := range files {
 		fileName := file.Name()
-		lines, err := getFileContent(fileName)
-		if err != nil {
-			log.Printf("ERROR: source local failed reading file '%v': %v", fileName, err)
-			continue
+		var fileFeatures source.Features
+		structured := false
+		if isStructuredFile(fileName) {
+			fileFeatures, structured, err = parseStructuredFile(fileName)
+			if err != nil {
+				log.Printf("ERROR: source local failed parsing file '%v': %v", fileName, err)
+				continue
+			}
+			if !structured {
+				log.Printf("WARNING: '%v' is not a list of feature entries, reading it as lines", fileName)
+			}
+		}
+		if !structured {
+			lines, err := getFileContent(fileName)
+			if err != nil {
+				log.Printf("ERROR: source local failed reading file '%v': %v", fileName, err)
+				continue
+			}
+			fileFeatures = parseFeatures(lines, fileName)
 		}
 
 		// Append features
-		for k, v := range parseFeatures(lines, fileName) {
+		for k, v := range fileFeatures {
 			if old, ok := features[k]; ok {
 				log.Printf("WARNING: overriding label '%s' from another features.d file (%s): value changed from '%s' to '%s'",
 					k, fileName, old, v)
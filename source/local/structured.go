@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+	"sigs.k8s.io/yaml"
+)
+
+// Types of the entries of structured feature files
+const (
+	entryTypeLabel            = "label"
+	entryTypeAnnotation       = "annotation"
+	entryTypeExtendedResource = "extendedResource"
+)
+
+// featureEntry is one entry of a structured (JSON or YAML) feature file
+type featureEntry struct {
+	Name  string     `json:"name"`
+	Type  string     `json:"type,omitempty"`
+	Value entryValue `json:"value,omitempty"`
+}
+
+// entryValue is the value of a feature entry. Numbers and booleans are
+// accepted in addition to strings.
+type entryValue string
+
+// UnmarshalJSON implements the Unmarshaler interface
+func (v *entryValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = entryValue(s)
+		return nil
+	}
+
+	var i interface{}
+	if err := json.Unmarshal(data, &i); err != nil {
+		return err
+	}
+	switch i.(type) {
+	case float64, bool:
+		*v = entryValue(data)
+	case nil:
+		*v = ""
+	default:
+		return fmt.Errorf("invalid value %s: must be a string, number or boolean", data)
+	}
+	return nil
+}
+
+// isStructuredFile returns true if the feature file is in JSON or YAML format
+func isStructuredFile(fileName string) bool {
+	switch filepath.Ext(fileName) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// parseStructuredFile reads the features of a JSON or YAML feature file. The
+// file name without the extension is used as the prefix of the feature names.
+// False is returned if the file does not contain a list of feature entries,
+// e.g. it is a file of feature lines that happens to have a JSON or YAML
+// extension, in which case the caller falls back to reading it as lines.
+func parseStructuredFile(fileName string) (source.Features, bool, error) {
+	features := source.Features{}
+
+	path := filepath.Join(featureFilesDir, fileName)
+	filestat, err := os.Stat(path)
+	if err != nil {
+		return nil, true, err
+	}
+	if !filestat.Mode().IsRegular() {
+		return features, true, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, true, err
+	}
+	entries := []featureEntry{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, false, nil
+	}
+
+	prefix := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	for _, e := range entries {
+		if e.Name == "" || e.Name == "/" {
+			return nil, true, fmt.Errorf("feature name must be non-empty")
+		}
+		key := featureKey(e.Name, prefix)
+		value := string(e.Value)
+
+		switch e.Type {
+		case "", entryTypeLabel:
+			if value == "" {
+				value = "true"
+			}
+			features[key] = value
+		case entryTypeAnnotation:
+			features[key] = source.AnnotationFeatureValue(value)
+		case entryTypeExtendedResource:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n < 0 {
+				return nil, true, fmt.Errorf("invalid capacity %q of extended resource %q", value, e.Name)
+			}
+			features[key] = source.ExtendedResourceFeatureValue(n)
+		default:
+			return nil, true, fmt.Errorf("unknown type %q of feature %q", e.Type, e.Name)
+		}
+	}
+
+	return features, true, nil
+}
@@ -17,39 +17,98 @@ limitations under the License.
 package memory
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"sigs.k8s.io/node-feature-discovery/source"
+	"sigs.k8s.io/node-feature-discovery/source/internal/sizeutils"
 )
 
+const meminfoProcfsPath = "/proc/meminfo"
+
+// Configuration file options
+type Config struct {
+	HugepageCounts bool     `json:"hugepageCounts,omitempty"`
+	SizeBuckets    []string `json:"sizeBuckets,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		HugepageCounts: false,
+		SizeBuckets:    []string{},
+	}
+}
+
 // Source implements FeatureSource.
-type Source struct{}
+type Source struct {
+	config *Config
+}
 
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "memory" }
 
 // NewConfig method of the FeatureSource interface
-func (s *Source) NewConfig() source.Config { return nil }
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
 // GetConfig method of the FeatureSource interface
-func (s *Source) GetConfig() source.Config { return nil }
+func (s *Source) GetConfig() source.Config { return s.config }
 
 // SetConfig method of the FeatureSource interface
-func (s *Source) SetConfig(source.Config) {}
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
 
-// Discover returns feature names for memory: numa if more than one memory node is present.
-func (s Source) Discover() (source.Features, error) {
+// Discover returns feature names for memory: numa if more than one memory node
+// is present, the number of memory nodes, hugepage and NVDIMM features.
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
 	// Detect NUMA
-	numa, err := isNuma()
+	nodes, err := numaNodeCount()
 	if err != nil {
 		log.Printf("ERROR: failed to detect NUMA topology: %s", err)
-	} else if numa {
-		features["numa"] = true
+	} else {
+		if nodes > 1 {
+			features["numa"] = true
+		}
+		features["numa.count"] = nodes
+	}
+
+	// Detect hugepages
+	hugepages, err := detectHugepages()
+	if err != nil {
+		log.Printf("ERROR: hugepage detection failed: %s", err)
+	} else {
+		for size, count := range hugepages {
+			features["hugepages."+size] = true
+			if count > 0 {
+				features["hugepages."+size+".preallocated"] = true
+			}
+			if s.config.HugepageCounts {
+				features["hugepages."+size+".count"] = count
+			}
+		}
+	}
+
+	// Categorize total memory size
+	if len(s.config.SizeBuckets) > 0 {
+		bucket, err := detectSizeBucket(s.config.SizeBuckets)
+		if err != nil {
+			log.Printf("ERROR: failed to detect memory size: %s", err)
+		} else {
+			features["size.bucket"] = bucket
+		}
 	}
 
 	// Detect NVDIMM
@@ -65,24 +124,150 @@ func (s Source) Discover() (source.Features, error) {
 	return features, nil
 }
 
-// Detect if the platform has NUMA topology
-func isNuma() (bool, error) {
-	// Find out how many nodes are online
-	// Multiple nodes is a sign of NUMA
+// Get the number of online memory nodes. Multiple nodes is a sign of NUMA.
+func numaNodeCount() (int, error) {
 	bytes, err := ioutil.ReadFile(source.SysfsDir.Path("devices/system/node/online"))
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	// File content is expected to be:
+	// File content is a list of node ranges, e.g.:
 	//   "0\n" in one-node case
 	//   "0-K\n" in N-node case where K=N-1
 	// presence of newline requires TrimSpace
-	if strings.TrimSpace(string(bytes)) != "0" {
-		// more than one node means NUMA
-		return true, nil
+	count := 0
+	for _, r := range strings.Split(strings.TrimSpace(string(bytes)), ",") {
+		ends := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(ends[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid node list %q: %v", string(bytes), err)
+		}
+		last := first
+		if len(ends) == 2 {
+			if last, err = strconv.Atoi(ends[1]); err != nil {
+				return 0, fmt.Errorf("invalid node list %q: %v", string(bytes), err)
+			}
+		}
+		count += last - first + 1
+	}
+	return count, nil
+}
+
+// Detect the supported hugepage sizes and the number of preallocated pages of
+// each size. Sizes are formatted as Kubernetes quantities, e.g. "2Mi".
+func detectHugepages() (map[string]int, error) {
+	hugepages := map[string]int{}
+
+	dirs, err := ioutil.ReadDir(source.SysfsDir.Path("kernel/mm/hugepages"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Hugepages not supported by the kernel
+			return hugepages, nil
+		}
+		return nil, err
+	}
+
+	for _, d := range dirs {
+		// Directory names are of the form "hugepages-<size>kB"
+		kb, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(d.Name(), "hugepages-"), "kB"))
+		if err != nil {
+			log.Printf("WARNING: unexpected hugepages directory %q", d.Name())
+			continue
+		}
+		size := strconv.Itoa(kb) + "Ki"
+		switch {
+		case kb%(1024*1024) == 0:
+			size = strconv.Itoa(kb/(1024*1024)) + "Gi"
+		case kb%1024 == 0:
+			size = strconv.Itoa(kb/1024) + "Mi"
+		}
+
+		data, err := ioutil.ReadFile(source.SysfsDir.Path("kernel/mm/hugepages", d.Name(), "nr_hugepages"))
+		if err != nil {
+			return nil, err
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid nr_hugepages of %s: %v", d.Name(), err)
+		}
+		hugepages[size] = count
+	}
+
+	return hugepages, nil
+}
+
+// Detect the size bucket of the total memory, i.e. the largest one of the
+// given boundaries (Kubernetes quantities, e.g. "64Gi") not exceeding the
+// total memory. Zero is returned if the memory is smaller than all
+// boundaries.
+func detectSizeBucket(boundaries []string) (string, error) {
+	total, err := totalMemory()
+	if err != nil {
+		return "", err
+	}
+	return sizeutils.SizeBucket(total, boundaries)
+}
+
+// Get the total amount of installed memory. The online memory blocks are
+// counted as MemTotal of /proc/meminfo excludes the memory reserved by the
+// kernel, and would place nodes in a bucket below their installed memory.
+// MemTotal is used as a fallback if memory blocks are not available in sysfs,
+// e.g. on architectures without memory hotplug support.
+func totalMemory() (int64, error) {
+	total, err := onlineMemoryBlocksSize()
+	if err == nil {
+		return total, nil
+	}
+	log.Printf("WARNING: failed to read memory blocks, falling back to MemTotal: %v", err)
+
+	data, err := ioutil.ReadFile(meminfoProcfsPath)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid MemTotal in %s: %v", meminfoProcfsPath, err)
+			}
+			return kb * 1024, nil
+		}
+	}
+	return 0, fmt.Errorf("MemTotal not found in %s", meminfoProcfsPath)
+}
+
+// Get the total size of the online memory blocks
+func onlineMemoryBlocksSize() (int64, error) {
+	basePath := source.SysfsDir.Path("devices/system/memory")
+	data, err := ioutil.ReadFile(filepath.Join(basePath, "block_size_bytes"))
+	if err != nil {
+		return 0, err
+	}
+	// Block size is given in hex, without the 0x prefix
+	blockSize, err := strconv.ParseInt(strings.TrimSpace(string(data)), 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory block size %q: %v", strings.TrimSpace(string(data)), err)
+	}
+
+	blocks, err := filepath.Glob(filepath.Join(basePath, "memory[0-9]*"))
+	if err != nil {
+		return 0, err
+	}
+	var online int64
+	for _, b := range blocks {
+		state, err := ioutil.ReadFile(filepath.Join(b, "online"))
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(string(state)) == "1" {
+			online++
+		}
+	}
+	if online == 0 {
+		return 0, fmt.Errorf("no online memory blocks found in %s", basePath)
 	}
-	return false, nil
+	return online * blockSize, nil
 }
 
 // Detect NVDIMM devices and configuration
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
@@ -35,27 +36,58 @@ const (
 	flagLoopback
 )
 
-const sysfsBaseDir = "class/net"
+const (
+	sysfsBaseDir     = "class/net"
+	rdmaSysfsBaseDir = "class/infiniband"
+)
+
+// Configuration file options
+type Config struct {
+	SriovCounts bool `json:"sriovCounts,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		SriovCounts: false,
+	}
+}
 
 // Source implements FeatureSource.
-type Source struct{}
+type Source struct {
+	config *Config
+}
 
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "network" }
 
 // NewConfig method of the FeatureSource interface
-func (s *Source) NewConfig() source.Config { return nil }
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
 // GetConfig method of the FeatureSource interface
-func (s *Source) GetConfig() source.Config { return nil }
+func (s *Source) GetConfig() source.Config { return s.config }
 
 // SetConfig method of the FeatureSource interface
-func (s *Source) SetConfig(source.Config) {}
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
 
-// Discover returns feature names sriov-configured and sriov if SR-IOV capable NICs are present and/or SR-IOV virtual functions are configured on the node
-func (s Source) Discover() (source.Features, error) {
+// Discover returns feature names sriov-configured and sriov if SR-IOV capable NICs are present and/or SR-IOV virtual functions are configured on the node,
+// the speed of the fastest NIC and the RDMA devices present
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
+	// Total number of supported and configured virtual functions
+	totalVfs := 0
+	numVfs := 0
+	// Fastest link speed in Mb/s
+	maxSpeed := 0
+
 	netInterfaces, err := ioutil.ReadDir(source.SysfsDir.Path(sysfsBaseDir))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list network interfaces: %s", err.Error())
@@ -71,6 +103,10 @@ func (s Source) Discover() (source.Features, error) {
 		}
 
 		if flags&flagUp != 0 && flags&flagLoopback == 0 {
+			if speed, err := readIfSpeed(name); err == nil && speed > maxSpeed {
+				maxSpeed = speed
+			}
+
 			totalBytes, err := ioutil.ReadFile(source.SysfsDir.Path(sysfsBaseDir, name, "device/sriov_totalvfs"))
 			if err != nil {
 				log.Printf("SR-IOV not supported for network interface: %s: %v", name, err)
@@ -86,6 +122,7 @@ func (s Source) Discover() (source.Features, error) {
 				log.Printf("SR-IOV capability is detected on the network interface: %s", name)
 				log.Printf("%d maximum supported number of virtual functions on network interface: %s", t, name)
 				features["sriov.capable"] = true
+				totalVfs += t
 				numBytes, err := ioutil.ReadFile(source.SysfsDir.Path(sysfsBaseDir, name, "device/sriov_numvfs"))
 				if err != nil {
 					log.Printf("SR-IOV not configured for network interface: %s: %s", name, err)
@@ -100,13 +137,36 @@ func (s Source) Discover() (source.Features, error) {
 				if n > 0 {
 					log.Printf("%d virtual functions configured on network interface: %s", n, name)
 					features["sriov.configured"] = true
-					break
+					numVfs += n
 				} else if n == 0 {
 					log.Printf("SR-IOV not configured on network interface: %s", name)
 				}
 			}
 		}
 	}
+
+	if maxSpeed > 0 {
+		features["speed."+formatSpeed(maxSpeed)] = true
+	}
+
+	if detectPtp(netInterfaces) {
+		features["ptp.capable"] = true
+	}
+
+	rdma, err := detectRdma()
+	if err != nil {
+		log.Printf("ERROR: failed to detect RDMA devices: %v", err)
+	} else {
+		for k, v := range rdma {
+			features["rdma."+k] = v
+		}
+	}
+
+	if s.config.SriovCounts && totalVfs > 0 {
+		features["sriov.totalvfs"] = totalVfs
+		features["sriov.numvfs"] = numVfs
+	}
+
 	return features, nil
 }
 
@@ -122,3 +182,78 @@ func readIfFlags(name string) (uint64, error) {
 
 	return flags, nil
 }
+
+// readIfSpeed returns the link speed of an interface in Mb/s. Reading the
+// speed fails if the link is down.
+func readIfSpeed(name string) (int, error) {
+	raw, err := ioutil.ReadFile(source.SysfsDir.Path(sysfsBaseDir, name, "speed"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read speed of interface %q: %v", name, err)
+	}
+	speed, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse speed of interface %q: %v", name, err)
+	}
+	return speed, nil
+}
+
+// formatSpeed formats a link speed given in Mb/s, e.g. "100M" or "25G"
+func formatSpeed(speed int) string {
+	if speed%1000 == 0 {
+		return strconv.Itoa(speed/1000) + "G"
+	}
+	return strconv.Itoa(speed) + "M"
+}
+
+// detectPtp returns true if any of the network interfaces has a PTP hardware
+// clock, i.e. supports hardware timestamping needed for precision time
+// synchronization. The clock is registered by the driver of the NIC and
+// shows up under the device in sysfs.
+func detectPtp(netInterfaces []os.FileInfo) bool {
+	for _, netInterface := range netInterfaces {
+		clocks, err := ioutil.ReadDir(source.SysfsDir.Path(sysfsBaseDir, netInterface.Name(), "device/ptp"))
+		if err == nil && len(clocks) > 0 {
+			log.Printf("PTP hardware clock detected on network interface: %s", netInterface.Name())
+			return true
+		}
+	}
+	return false
+}
+
+// detectRdma detects the presence of RDMA devices and the transport types of
+// their ports, i.e. InfiniBand or RoCE (RDMA over Converged Ethernet)
+func detectRdma() (map[string]bool, error) {
+	features := map[string]bool{}
+
+	devs, err := ioutil.ReadDir(source.SysfsDir.Path(rdmaSysfsBaseDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return features, nil
+		}
+		return nil, err
+	}
+
+	for _, dev := range devs {
+		features["available"] = true
+
+		ports, err := ioutil.ReadDir(source.SysfsDir.Path(rdmaSysfsBaseDir, dev.Name(), "ports"))
+		if err != nil {
+			log.Printf("failed to list ports of RDMA device %q: %v", dev.Name(), err)
+			continue
+		}
+		for _, port := range ports {
+			raw, err := ioutil.ReadFile(source.SysfsDir.Path(rdmaSysfsBaseDir, dev.Name(), "ports", port.Name(), "link_layer"))
+			if err != nil {
+				log.Printf("failed to read link layer of RDMA device %q port %s: %v", dev.Name(), port.Name(), err)
+				continue
+			}
+			switch strings.TrimSpace(string(raw)) {
+			case "InfiniBand":
+				features["infiniband"] = true
+			case "Ethernet":
+				features["roce"] = true
+			}
+		}
+	}
+	return features, nil
+}
@@ -19,6 +19,7 @@ package pci
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
 	"sigs.k8s.io/node-feature-discovery/source"
@@ -106,6 +107,10 @@ func (s Source) Discover() (source.Features, error) {
 		return nil, fmt.Errorf("Failed to detect PCI devices: %s", err.Error())
 	}
 
+	// Number of configured SR-IOV VFs, summed over the devices having the
+	// same label
+	numVfs := map[string]int{}
+
 	// Iterate over all device classes
 	for class, classDevs := range devs {
 		for _, white := range s.config.DeviceClassWhitelist {
@@ -122,10 +127,18 @@ func (s Source) Discover() (source.Features, error) {
 
 					if _, ok := dev["sriov_totalvfs"]; ok {
 						features[devLabel+".sriov.capable"] = true
+
+						if n, err := strconv.Atoi(dev["sriov_numvfs"]); err == nil && n > 0 {
+							features[devLabel+".sriov.configured"] = true
+							numVfs[devLabel] += n
+						}
 					}
 				}
 			}
 		}
 	}
+	for devLabel, n := range numVfs {
+		features[devLabel+".sriov.numvfs"] = n
+	}
 	return features, nil
 }
@@ -0,0 +1,156 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package power
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+const (
+	powercapSysfsDir = "class/powercap"
+	cpuSysfsDir      = "devices/system/cpu"
+)
+
+// Implement FeatureSource interface
+type Source struct{}
+
+// Return name of the feature source
+func (s Source) Name() string { return "power" }
+
+// NewConfig method of the FeatureSource interface
+func (s *Source) NewConfig() source.Config { return nil }
+
+// GetConfig method of the FeatureSource interface
+func (s *Source) GetConfig() source.Config { return nil }
+
+// SetConfig method of the FeatureSource interface
+func (s *Source) SetConfig(source.Config) {}
+
+// Discover returns the RAPL power domains, the CPU frequency range and the
+// available energy performance preferences
+func (s Source) Discover() (source.Features, error) {
+	features := source.Features{}
+
+	for _, d := range detectRaplDomains() {
+		features["rapl."+d] = true
+	}
+
+	if min, max := detectFrequencyRange(); max > 0 {
+		features["frequency.min"] = strconv.Itoa(min)
+		features["frequency.max"] = strconv.Itoa(max)
+	}
+
+	for _, p := range detectEppProfiles() {
+		features["epp."+p] = true
+	}
+
+	return features, nil
+}
+
+// detectRaplDomains returns the names of the RAPL (Running Average Power
+// Limit) power domains, e.g. package or dram, with the domain index stripped
+func detectRaplDomains() []string {
+	zones, err := filepath.Glob(source.SysfsDir.Path(powercapSysfsDir, "intel-rapl:*"))
+	if err != nil {
+		return nil
+	}
+
+	re := regexp.MustCompile(`-\d+$`)
+	found := map[string]bool{}
+	domains := []string{}
+	for _, z := range zones {
+		name, err := readAttr(filepath.Join(z, "name"))
+		if err != nil {
+			log.Printf("failed to read the name of RAPL zone %s: %v", filepath.Base(z), err)
+			continue
+		}
+		name = re.ReplaceAllString(name, "")
+		if !found[name] {
+			found[name] = true
+			domains = append(domains, name)
+		}
+	}
+	return domains
+}
+
+// detectFrequencyRange returns the lowest and the highest hardware frequency
+// of all CPUs in MHz. Zeroes are returned if cpufreq is not available.
+func detectFrequencyRange() (int, int) {
+	cpus, err := filepath.Glob(source.SysfsDir.Path(cpuSysfsDir, "cpu[0-9]*"))
+	if err != nil {
+		return 0, 0
+	}
+
+	min, max := 0, 0
+	for _, c := range cpus {
+		cpuMin, err := readFreqMHz(filepath.Join(c, "cpufreq/cpuinfo_min_freq"))
+		if err != nil {
+			continue
+		}
+		cpuMax, err := readFreqMHz(filepath.Join(c, "cpufreq/cpuinfo_max_freq"))
+		if err != nil {
+			continue
+		}
+		if min == 0 || cpuMin < min {
+			min = cpuMin
+		}
+		if cpuMax > max {
+			max = cpuMax
+		}
+	}
+	return min, max
+}
+
+// detectEppProfiles returns the energy performance preference profiles
+// available for tuning the CPUs. They are read from the first CPU.
+func detectEppProfiles() []string {
+	profiles, err := readAttr(source.SysfsDir.Path(cpuSysfsDir, "cpu0/cpufreq/energy_performance_available_preferences"))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(profiles)
+}
+
+// readFreqMHz reads a cpufreq frequency attribute, given in kHz, and returns
+// the frequency in MHz
+func readFreqMHz(path string) (int, error) {
+	s, err := readAttr(path)
+	if err != nil {
+		return 0, err
+	}
+	khz, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return khz / 1000, nil
+}
+
+// readAttr reads a single-line attribute from sysfs
+func readAttr(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
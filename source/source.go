@@ -30,8 +30,24 @@ func (b BoolFeatureValue) String() string {
 	return "false"
 }
 
+// Feature value that is published as a node annotation instead of a label.
+// Annotations are not restricted in the format of their value, but, they are
+// subject to size limits.
+type AnnotationFeatureValue string
+
+// Feature value that is published as a node extended resource instead of a
+// label. The value is the capacity of the resource.
+type ExtendedResourceFeatureValue int64
+
 type Features map[string]FeatureValue
 
+// Taint is a node taint requested by a feature source
+type Taint struct {
+	Key    string `json:"key"`
+	Value  string `json:"value,omitempty"`
+	Effect string `json:"effect"`
+}
+
 // FeatureSource represents a source of a discovered node feature.
 type FeatureSource interface {
 	// Name returns a friendly name for this source of node feature.
@@ -52,3 +68,12 @@ type FeatureSource interface {
 
 type Config interface {
 }
+
+// TaintSource is implemented by feature sources that request node taints.
+// Taints are kept separate from the discovered features as several taints
+// (with different effects) may share the same key.
+type TaintSource interface {
+	// Taints returns the node taints requested by the features found in the
+	// last call to Discover.
+	Taints() []Taint
+}
@@ -19,33 +19,73 @@ package storage
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
 
 	"sigs.k8s.io/node-feature-discovery/source"
+	"sigs.k8s.io/node-feature-discovery/source/internal/sizeutils"
 )
 
+// Size of the sectors in which block device sizes are reported in sysfs
+const sectorSize = 512
+
+// Configuration file options
+type Config struct {
+	CapacityBuckets []string `json:"capacityBuckets,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		CapacityBuckets: []string{},
+	}
+}
+
 // Source implements FeatureSource.
-type Source struct{}
+type Source struct {
+	config *Config
+}
 
 // Name returns an identifier string for this feature source.
 func (s Source) Name() string { return "storage" }
 
 // NewConfig method of the FeatureSource interface
-func (s *Source) NewConfig() source.Config { return nil }
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
 // GetConfig method of the FeatureSource interface
-func (s *Source) GetConfig() source.Config { return nil }
+func (s *Source) GetConfig() source.Config { return s.config }
 
 // SetConfig method of the FeatureSource interface
-func (s *Source) SetConfig(source.Config) {}
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
 
-// Discover returns feature names for storage: nonrotationaldisk if any SSD drive present.
-func (s Source) Discover() (source.Features, error) {
+// Discover returns feature names for storage: nonrotationaldisk if any SSD drive present,
+// nvme.present if any NVMe drive present, the number of local block devices
+// and their total capacity.
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
+	// Number and total capacity of local block devices, i.e. devices backed
+	// by hardware
+	count := 0
+	var capacity int64
+
 	// Check if there is any non-rotational block devices attached to the node
 	blockdevices, err := ioutil.ReadDir(source.SysfsDir.Path("block"))
 	if err == nil {
 		for _, bdev := range blockdevices {
+			if strings.HasPrefix(bdev.Name(), "nvme") {
+				features["nvme.present"] = true
+			}
+
 			fname := source.SysfsDir.Path("block", bdev.Name(), "queue/rotational")
 			bytes, err := ioutil.ReadFile(fname)
 			if err != nil {
@@ -54,9 +94,43 @@ func (s Source) Discover() (source.Features, error) {
 			if bytes[0] == byte('0') {
 				// Non-rotational storage is present, add label.
 				features["nonrotationaldisk"] = true
-				break
+			}
+
+			if _, err := os.Stat(source.SysfsDir.Path("block", bdev.Name(), "device")); err != nil {
+				// Virtual device, e.g. loop or device mapper
+				continue
+			}
+			count++
+			size, err := readBlockDevSize(bdev.Name())
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				continue
+			}
+			capacity += size
+		}
+
+		features["blockdevice.count"] = count
+		if len(s.config.CapacityBuckets) > 0 {
+			bucket, err := sizeutils.SizeBucket(capacity, s.config.CapacityBuckets)
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+			} else {
+				features["capacity.bucket"] = bucket
 			}
 		}
 	}
 	return features, nil
 }
+
+// readBlockDevSize returns the size of a block device in bytes
+func readBlockDevSize(name string) (int64, error) {
+	raw, err := ioutil.ReadFile(source.SysfsDir.Path("block", name, "size"))
+	if err != nil {
+		return 0, fmt.Errorf("can't read size of block device %s: %v", name, err)
+	}
+	sectors, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size of block device %s: %v", name, err)
+	}
+	return sectors * sectorSize, nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+const dmiSysfsDir = "class/dmi/id"
+
+// Placeholder values commonly left in DMI fields by OEMs. They carry no
+// information about the hardware and are not published.
+var dmiPlaceholders = []string{
+	"default string",
+	"not applicable",
+	"not specified",
+	"none",
+	"o.e.m.",
+	"system manufacturer",
+	"system product name",
+	"system version",
+	"to be filled by o.e.m.",
+}
+
+// readDmiFields reads the given DMI fields. Fields that are not available, are
+// empty or contain a placeholder value, are omitted. Values are sanitized into
+// valid label values.
+func readDmiFields(fields []string) map[string]string {
+	values := map[string]string{}
+	for _, f := range fields {
+		data, err := ioutil.ReadFile(source.SysfsDir.Path(dmiSysfsDir, f))
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		if isDmiPlaceholder(value) {
+			continue
+		}
+		if f == "bios_date" {
+			value = formatDmiDate(value)
+		}
+		if value = sanitizeDmiValue(value); value != "" {
+			values[f] = value
+		}
+	}
+	return values
+}
+
+// isDmiPlaceholder returns true if a DMI value is a placeholder
+func isDmiPlaceholder(value string) bool {
+	for _, p := range dmiPlaceholders {
+		if strings.EqualFold(value, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDmiDate converts a DMI date in MM/DD/YYYY format into YYYYMMDD, which
+// can be compared with the Gt and Lt operators of node affinity. Other
+// formats are returned as is.
+func formatDmiDate(date string) string {
+	re := regexp.MustCompile(`^(\d{2})/(\d{2})/(\d{4})$`)
+	if m := re.FindStringSubmatch(date); m != nil {
+		return m[3] + m[1] + m[2]
+	}
+	return date
+}
+
+// sanitizeDmiValue turns a free-form DMI string into a valid label value by
+// replacing runs of invalid characters with an underscore
+func sanitizeDmiValue(value string) string {
+	re := regexp.MustCompile(`[^-A-Za-z0-9_.]+`)
+	value = re.ReplaceAllString(value, "_")
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.Trim(value, "-_.")
+}
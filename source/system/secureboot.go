@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// Name of the EFI SecureBoot variable, including the EFI global variable GUID
+const secureBootVar = "SecureBoot-8be4df61-93ca-11d2-aa0d-00e098032b8c"
+
+// detectSecureBoot returns true if the system was booted with Secure Boot
+// enabled. Non-EFI systems and firmware without Secure Boot support are
+// reported as Secure Boot disabled.
+func detectSecureBoot() (bool, error) {
+	if _, err := os.Stat(source.SysfsDir.Path("firmware/efi")); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	// In efivarfs the variable consists of 4 bytes of attributes followed by
+	// the value
+	efivars := source.SysfsDir.Path("firmware/efi/efivars")
+	if entries, err := ioutil.ReadDir(efivars); err == nil && len(entries) > 0 {
+		data, err := ioutil.ReadFile(filepath.Join(efivars, secureBootVar))
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if len(data) < 5 {
+			return false, fmt.Errorf("invalid SecureBoot variable of %d bytes", len(data))
+		}
+		return data[4] == 1, nil
+	}
+
+	// Fall back to the deprecated sysfs interface
+	vars := source.SysfsDir.Path("firmware/efi/vars")
+	if entries, err := ioutil.ReadDir(vars); err == nil && len(entries) > 0 {
+		data, err := ioutil.ReadFile(filepath.Join(vars, secureBootVar, "data"))
+		if os.IsNotExist(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if len(data) < 1 {
+			return false, fmt.Errorf("empty SecureBoot variable")
+		}
+		return data[0] == 1, nil
+	}
+
+	return false, fmt.Errorf("EFI variables are not available, efivarfs not mounted?")
+}
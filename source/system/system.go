@@ -26,33 +26,60 @@ import (
 	"sigs.k8s.io/node-feature-discovery/source"
 )
 
-var osReleaseFields = [...]string{
-	"ID",
-	"VERSION_ID",
+// Configuration file options
+type Config struct {
+	OsReleaseFields []string `json:"osReleaseFields,omitempty"`
+	DmiFields       []string `json:"dmiFields,omitempty"`
+}
+
+// newDefaultConfig returns a new config with pre-populated defaults
+func newDefaultConfig() *Config {
+	return &Config{
+		OsReleaseFields: []string{
+			"ID",
+			"VERSION_ID",
+		},
+		DmiFields: []string{
+			"bios_vendor",
+			"bios_version",
+			"bios_date",
+			"sys_vendor",
+			"product_family",
+		},
+	}
 }
 
 // Implement FeatureSource interface
-type Source struct{}
+type Source struct {
+	config *Config
+}
 
 func (s Source) Name() string { return "system" }
 
 // NewConfig method of the FeatureSource interface
-func (s *Source) NewConfig() source.Config { return nil }
+func (s *Source) NewConfig() source.Config { return newDefaultConfig() }
 
 // GetConfig method of the FeatureSource interface
-func (s *Source) GetConfig() source.Config { return nil }
+func (s *Source) GetConfig() source.Config { return s.config }
 
 // SetConfig method of the FeatureSource interface
-func (s *Source) SetConfig(source.Config) {}
+func (s *Source) SetConfig(conf source.Config) {
+	switch v := conf.(type) {
+	case *Config:
+		s.config = v
+	default:
+		log.Printf("PANIC: invalid config type: %T", conf)
+	}
+}
 
-func (s Source) Discover() (source.Features, error) {
+func (s *Source) Discover() (source.Features, error) {
 	features := source.Features{}
 
 	release, err := parseOSRelease()
 	if err != nil {
 		log.Printf("ERROR: failed to get os-release: %s", err)
 	} else {
-		for _, key := range osReleaseFields {
+		for _, key := range s.config.OsReleaseFields {
 			if value, exists := release[key]; exists {
 				feature := "os_release." + key
 				features[feature] = value
@@ -68,6 +95,24 @@ func (s Source) Discover() (source.Features, error) {
 			}
 		}
 	}
+
+	for k, v := range readDmiFields(s.config.DmiFields) {
+		features["dmi."+k] = v
+	}
+
+	secureBoot, err := detectSecureBoot()
+	if err != nil {
+		log.Printf("ERROR: failed to detect Secure Boot status: %s", err)
+	} else if secureBoot {
+		features["secureboot.enabled"] = true
+	}
+
+	if present, version := detectTpm(); present {
+		features["tpm.present"] = true
+		if version != "" {
+			features["tpm.version"] = version
+		}
+	}
 	return features, nil
 }
 
@@ -0,0 +1,60 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// detectTpm returns true if a TPM device is present, and the version of the
+// TPM specification it implements, if it can be determined
+func detectTpm() (bool, string) {
+	devices, err := ioutil.ReadDir(source.SysfsDir.Path("class/tpm"))
+	if err != nil || len(devices) == 0 {
+		return false, ""
+	}
+	devPath := filepath.Join(source.SysfsDir.Path("class/tpm"), devices[0].Name())
+
+	// Recent kernels report the major version directly
+	if data, err := ioutil.ReadFile(filepath.Join(devPath, "tpm_version_major")); err == nil {
+		switch strings.TrimSpace(string(data)) {
+		case "1":
+			return true, "1.2"
+		case "2":
+			return true, "2.0"
+		}
+	}
+
+	// TPM 1.2 devices have the caps attribute
+	if data, err := ioutil.ReadFile(filepath.Join(devPath, "caps")); err == nil {
+		re := regexp.MustCompile(`TCG version: (\d+\.\d+)`)
+		if m := re.FindStringSubmatch(string(data)); m != nil {
+			return true, m[1]
+		}
+	}
+	// The in-kernel resource manager is only available for TPM 2.0
+	if _, err := os.Stat(source.SysfsDir.Path("class/tpmrm")); err == nil {
+		return true, "2.0"
+	}
+	return true, ""
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package virt
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/node-feature-discovery/source"
+)
+
+// Hypervisors recognized from the DMI system vendor and product name
+var dmiHypervisors = []struct {
+	match string
+	name  string
+}{
+	{"QEMU", "kvm"},
+	{"KVM", "kvm"},
+	{"Amazon EC2", "kvm"},
+	{"Google", "kvm"},
+	{"VMware", "vmware"},
+	{"Microsoft Corporation", "hyperv"},
+	{"Xen", "xen"},
+	{"innotek GmbH", "virtualbox"},
+	{"VirtualBox", "virtualbox"},
+}
+
+// Implement FeatureSource interface
+type Source struct{}
+
+// Return name of the feature source
+func (s Source) Name() string { return "virt" }
+
+// NewConfig method of the FeatureSource interface
+func (s *Source) NewConfig() source.Config { return nil }
+
+// GetConfig method of the FeatureSource interface
+func (s *Source) GetConfig() source.Config { return nil }
+
+// SetConfig method of the FeatureSource interface
+func (s *Source) SetConfig(source.Config) {}
+
+// Discover features
+func (s Source) Discover() (source.Features, error) {
+	features := source.Features{}
+
+	// /dev/kvm is a misc device registered by the kvm module
+	if _, err := os.Stat(source.SysfsDir.Path("devices/virtual/misc/kvm")); err == nil {
+		features["kvm.present"] = true
+	}
+
+	if nestedEnabled() {
+		features["nested.enabled"] = true
+	}
+
+	hypervisor, err := detectHypervisor()
+	if err != nil {
+		log.Printf("ERROR: failed to detect hypervisor: %s", err)
+	} else if hypervisor != "" {
+		features["guest.hypervisor"] = hypervisor
+	}
+
+	return features, nil
+}
+
+// nestedEnabled returns true if nested virtualization is enabled in the kvm
+// module of the CPU vendor
+func nestedEnabled() bool {
+	for _, m := range []string{"kvm_intel", "kvm_amd"} {
+		data, err := ioutil.ReadFile(source.SysfsDir.Path("module", m, "parameters/nested"))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(string(data)) {
+		case "Y", "1":
+			return true
+		}
+	}
+	return false
+}
+
+// detectHypervisor returns the name of the hypervisor if the node is a
+// virtual machine, and an empty string otherwise
+func detectHypervisor() (string, error) {
+	// Xen exposes the hypervisor type in sysfs, also in the privileged
+	// control domain (dom0) which is not a guest but the host
+	if data, err := ioutil.ReadFile(source.SysfsDir.Path("hypervisor/type")); err == nil {
+		if t := strings.TrimSpace(string(data)); t != "" {
+			if t == "xen" && xenControlDomain() {
+				return "", nil
+			}
+			return t, nil
+		}
+	}
+
+	guest, err := hypervisorFlag()
+	if err != nil || !guest {
+		return "", err
+	}
+
+	dmi := ""
+	for _, f := range []string{"sys_vendor", "product_name"} {
+		if data, err := ioutil.ReadFile(source.SysfsDir.Path("class/dmi/id", f)); err == nil {
+			dmi += strings.TrimSpace(string(data)) + " "
+		}
+	}
+	for _, h := range dmiHypervisors {
+		if strings.Contains(dmi, h.match) {
+			return h.name, nil
+		}
+	}
+	return "other", nil
+}
+
+// xenControlDomain returns true if the node is the Xen control domain (dom0),
+// as indicated by the "control_d" capability
+func xenControlDomain() bool {
+	data, err := ioutil.ReadFile("/proc/xen/capabilities")
+	if err != nil {
+		return false
+	}
+	for _, c := range strings.Split(strings.TrimSpace(string(data)), ",") {
+		if c == "control_d" {
+			return true
+		}
+	}
+	return false
+}
+
+// hypervisorFlag returns true if the hypervisor CPU flag, indicating that
+// the system is running under a hypervisor, is set
+func hypervisorFlag() (bool, error) {
+	f, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "flags") {
+			continue
+		}
+		for _, flag := range strings.Fields(line[strings.Index(line, ":")+1:]) {
+			if flag == "hypervisor" {
+				return true, nil
+			}
+		}
+		// All CPUs have the same flags
+		return false, nil
+	}
+	return false, s.Err()
+}